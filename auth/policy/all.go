@@ -49,3 +49,7 @@ func (a *allPolicy) CanRename(user *domain.Meta, meta *domain.Meta) bool {
 func (a *allPolicy) CanDelete(user *domain.Meta, meta *domain.Meta) bool {
 	return true
 }
+
+func (a *allPolicy) CanTrash(user *domain.Meta, meta *domain.Meta) bool {
+	return true
+}
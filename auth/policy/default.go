@@ -73,7 +73,7 @@ func (d *defaultPolicy) CanWrite(user *domain.Meta, oldMeta, newMeta *domain.Met
 	if !d.CanRead(user, oldMeta) {
 		return false
 	}
-	if user == nil {
+	if user == nil || !keepsMaintained(user, oldMeta, newMeta) {
 		return false
 	}
 	if role, ok := oldMeta.Get(domain.MetaKeyRole); ok && role == domain.MetaValueRoleUser {
@@ -93,6 +93,34 @@ func (d *defaultPolicy) CanWrite(user *domain.Meta, oldMeta, newMeta *domain.Met
 	return d.CanCreate(user, newMeta)
 }
 
+// keepsMaintained returns true, if the new meta data does not change the
+// values that are maintained by the zettelstore: the creation time must stay
+// the same, the editor must be the current user, and the modification time
+// must not go back.
+func keepsMaintained(user *domain.Meta, oldMeta, newMeta *domain.Meta) bool {
+	if oldMeta.GetDefault(domain.MetaKeyCreated, "") != newMeta.GetDefault(domain.MetaKeyCreated, "") {
+		return false
+	}
+	if editor, ok := newMeta.Get(domain.MetaKeyEditor); ok &&
+		editor != user.Zid.Format() && editor != oldMeta.GetDefault(domain.MetaKeyEditor, "") {
+		return false
+	}
+	newModified, ok := newMeta.Get(domain.MetaKeyModified)
+	if !ok {
+		return true
+	}
+	newTime, ok := domain.TimeValue(newModified)
+	if !ok {
+		return false
+	}
+	if oldModified, ok := oldMeta.Get(domain.MetaKeyModified); ok {
+		if oldTime, ok := domain.TimeValue(oldModified); ok && newTime.Before(oldTime) {
+			return false
+		}
+	}
+	return true
+}
+
 func (d *defaultPolicy) CanRename(user *domain.Meta, meta *domain.Meta) bool {
 	return false
 }
@@ -100,3 +128,15 @@ func (d *defaultPolicy) CanRename(user *domain.Meta, meta *domain.Meta) bool {
 func (d *defaultPolicy) CanDelete(user *domain.Meta, meta *domain.Meta) bool {
 	return false
 }
+
+func (d *defaultPolicy) CanTrash(user *domain.Meta, meta *domain.Meta) bool {
+	// Trashed zettel can be restored, so writers are allowed to delete them.
+	// Zettel of users are never trashed, only the owner may delete them.
+	if user == nil || config.GetUserRole(user) == config.UserRoleReader {
+		return false
+	}
+	if role, ok := meta.Get(domain.MetaKeyRole); ok && role == domain.MetaValueRoleUser {
+		return false
+	}
+	return d.CanRead(user, meta)
+}
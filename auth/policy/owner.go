@@ -93,3 +93,13 @@ func (o *ownerPolicy) CanDelete(user *domain.Meta, meta *domain.Meta) bool {
 	}
 	return o.base.CanDelete(user, meta)
 }
+
+func (o *ownerPolicy) CanTrash(user *domain.Meta, meta *domain.Meta) bool {
+	if o.readonly || meta == nil {
+		return false
+	}
+	if o.canDo(user) {
+		return true
+	}
+	return o.base.CanTrash(user, meta)
+}
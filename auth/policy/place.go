@@ -22,10 +22,10 @@ package policy
 
 import (
 	"context"
+	"strconv"
 
 	"zettelstore.de/z/domain"
 	"zettelstore.de/z/place"
-	"zettelstore.de/z/web/session"
 )
 
 // pol implements a policy place.
@@ -72,7 +72,7 @@ func (pp *polPlace) CanCreateZettel(ctx context.Context) bool {
 }
 
 func (pp *polPlace) CreateZettel(ctx context.Context, zettel domain.Zettel) (domain.ZettelID, error) {
-	user := session.GetUser(ctx)
+	user := place.GetUser(ctx)
 	if pp.policy.CanCreate(user, zettel.Meta) {
 		return pp.place.CreateZettel(ctx, zettel)
 	}
@@ -84,8 +84,9 @@ func (pp *polPlace) GetZettel(ctx context.Context, zid domain.ZettelID) (domain.
 	if err != nil {
 		return domain.Zettel{}, err
 	}
-	user := session.GetUser(ctx)
+	user := place.GetUser(ctx)
 	if pp.policy.CanRead(user, zettel.Meta) {
+		zettel.Meta = pp.filterLinks(ctx, user, zettel.Meta, nil)
 		return zettel, nil
 	}
 	return domain.Zettel{}, place.NewErrNotAuthorized("GetZettel", user, zid)
@@ -97,28 +98,104 @@ func (pp *polPlace) GetMeta(ctx context.Context, zid domain.ZettelID) (*domain.M
 	if err != nil {
 		return nil, err
 	}
-	user := session.GetUser(ctx)
+	user := place.GetUser(ctx)
 	if pp.policy.CanRead(user, meta) {
-		return meta, nil
+		return pp.filterLinks(ctx, user, meta, nil), nil
 	}
 	return nil, place.NewErrNotAuthorized("GetMeta", user, zid)
 }
 
 // SelectMeta returns all zettel meta data that match the selection
 // criteria. The result is ordered by descending zettel id.
+//
+// Since the filter and the sorter may refer to linked zettel and their
+// counts, they must be applied after the links were filtered for the user.
+// Otherwise, the selected zettel or their order would reveal links to
+// zettel the user is not allowed to read.
 func (pp *polPlace) SelectMeta(ctx context.Context, f *place.Filter, s *place.Sorter) ([]*domain.Meta, error) {
-	metaList, err := pp.place.SelectMeta(ctx, f, s)
+	metaList, err := pp.place.SelectMeta(ctx, nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	user := session.GetUser(ctx)
+	user := place.GetUser(ctx)
+	filterFunc := place.CreateFilterFunc(f)
+	readable := make(map[domain.ZettelID]bool)
 	result := make([]*domain.Meta, 0, len(metaList))
 	for _, meta := range metaList {
-		if pp.policy.CanRead(user, meta) {
+		if !pp.policy.CanRead(user, meta) {
+			continue
+		}
+		if meta = pp.filterLinks(ctx, user, meta, readable); filterFunc(meta) {
 			result = append(result, meta)
 		}
 	}
-	return result, nil
+	return place.ApplySorter(result, s), nil
+}
+
+// linkKeys maps the computed meta keys that list linked zettel to the key of
+// their count. An empty count key means that there is no count.
+var linkKeys = map[string]string{
+	domain.MetaKeyForward:     domain.MetaKeyForwardCount,
+	domain.MetaKeyBackward:    domain.MetaKeyBackwardCount,
+	domain.MetaKeyBrokenLinks: "",
+}
+
+// filterLinks removes all linked zettel from the computed meta values, that
+// the user is not allowed to read. Otherwise, their zettel identifier would be
+// revealed. The map readable caches the check, it may be nil.
+func (pp *polPlace) filterLinks(
+	ctx context.Context, user *domain.Meta, meta *domain.Meta, readable map[domain.ZettelID]bool) *domain.Meta {
+	if readable == nil {
+		readable = make(map[domain.ZettelID]bool)
+	}
+	result := meta
+	for key, countKey := range linkKeys {
+		values, ok := meta.GetList(key)
+		if !ok {
+			continue
+		}
+		filtered := make([]string, 0, len(values))
+		for _, val := range values {
+			if zid, err := domain.ParseZettelID(val); err == nil && pp.canReadZid(ctx, user, zid, readable) {
+				filtered = append(filtered, val)
+			}
+		}
+		if len(filtered) == len(values) {
+			continue
+		}
+		if result == meta {
+			result = meta.Clone()
+		}
+		if len(filtered) > 0 {
+			result.SetList(key, filtered)
+		} else {
+			result.Delete(key)
+		}
+		if _, ok := result.Get(countKey); ok {
+			result.Set(countKey, strconv.Itoa(len(filtered)))
+		}
+	}
+	return result
+}
+
+// canReadZid returns true, if the user is allowed to read the zettel with the
+// given zettel identifier. A zettel that does not exist reveals nothing and
+// is therefore readable.
+func (pp *polPlace) canReadZid(
+	ctx context.Context, user *domain.Meta, zid domain.ZettelID, readable map[domain.ZettelID]bool) bool {
+	if ok, found := readable[zid]; found {
+		return ok
+	}
+	meta, err := pp.place.GetMeta(ctx, zid)
+	var ok bool
+	switch err.(type) {
+	case nil:
+		ok = pp.policy.CanRead(user, meta)
+	case *place.ErrUnknownID:
+		ok = true
+	}
+	readable[zid] = ok
+	return ok
 }
 
 func (pp *polPlace) CanUpdateZettel(ctx context.Context, zettel domain.Zettel) bool {
@@ -126,8 +203,41 @@ func (pp *polPlace) CanUpdateZettel(ctx context.Context, zettel domain.Zettel) b
 }
 
 func (pp *polPlace) UpdateZettel(ctx context.Context, zettel domain.Zettel) error {
+	if err := pp.checkWrite(ctx, zettel); err != nil {
+		return err
+	}
+	return pp.place.UpdateZettel(ctx, zettel)
+}
+
+// GetVersionedZettel retrieves a zettel, together with the version token of
+// its current state.
+func (pp *polPlace) GetVersionedZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, string, error) {
+	zettel, version, err := place.GetVersionedZettel(ctx, pp.place, zid)
+	if err != nil {
+		return domain.Zettel{}, "", err
+	}
+	user := place.GetUser(ctx)
+	if pp.policy.CanRead(user, zettel.Meta) {
+		zettel.Meta = pp.filterLinks(ctx, user, zettel.Meta, nil)
+		return zettel, version, nil
+	}
+	return domain.Zettel{}, "", place.NewErrNotAuthorized("GetZettel", user, zid)
+}
+
+// UpdateVersionedZettel updates a zettel, if it was not changed since the
+// given version was retrieved.
+func (pp *polPlace) UpdateVersionedZettel(ctx context.Context, zettel domain.Zettel, version string) error {
+	if err := pp.checkWrite(ctx, zettel); err != nil {
+		return err
+	}
+	return place.UpdateVersionedZettel(ctx, pp.place, zettel, version)
+}
+
+// checkWrite returns an error, if the current user is not allowed to update
+// the existing zettel with the given one.
+func (pp *polPlace) checkWrite(ctx context.Context, zettel domain.Zettel) error {
 	zid := zettel.Meta.Zid
-	user := session.GetUser(ctx)
+	user := place.GetUser(ctx)
 	if !zid.IsValid() {
 		return &place.ErrInvalidID{Zid: zid}
 	}
@@ -137,7 +247,7 @@ func (pp *polPlace) UpdateZettel(ctx context.Context, zettel domain.Zettel) erro
 		return err
 	}
 	if pp.policy.CanWrite(user, oldMeta, zettel.Meta) {
-		return pp.place.UpdateZettel(ctx, zettel)
+		return nil
 	}
 	return place.NewErrNotAuthorized("Write", user, zid)
 }
@@ -152,7 +262,7 @@ func (pp *polPlace) RenameZettel(ctx context.Context, curZid, newZid domain.Zett
 	if err != nil {
 		return err
 	}
-	user := session.GetUser(ctx)
+	user := place.GetUser(ctx)
 	if pp.policy.CanRename(user, meta) {
 		return pp.place.RenameZettel(ctx, curZid, newZid)
 	}
@@ -169,8 +279,9 @@ func (pp *polPlace) DeleteZettel(ctx context.Context, zid domain.ZettelID) error
 	if err != nil {
 		return err
 	}
-	user := session.GetUser(ctx)
-	if pp.policy.CanDelete(user, meta) {
+	user := place.GetUser(ctx)
+	if pp.policy.CanDelete(user, meta) ||
+		(pp.policy.CanTrash(user, meta) && place.CanTrashZettel(ctx, pp.place, zid)) {
 		return pp.place.DeleteZettel(ctx, zid)
 	}
 	return place.NewErrNotAuthorized("Delete", user, zid)
@@ -179,9 +290,122 @@ func (pp *polPlace) DeleteZettel(ctx context.Context, zid domain.ZettelID) error
 // Reload clears all caches, reloads all internal data to reflect changes
 // that were possibly undetected.
 func (pp *polPlace) Reload(ctx context.Context) error {
-	user := session.GetUser(ctx)
+	user := place.GetUser(ctx)
 	if pp.policy.CanReload(user) {
 		return pp.place.Reload(ctx)
 	}
 	return place.NewErrNotAuthorized("Reload", user, domain.InvalidZettelID)
 }
+
+// HasHistory returns true, if the underlying place stores prior versions.
+func (pp *polPlace) HasHistory() bool { return place.HasHistory(pp.place) }
+
+// GetHistory returns all stored prior versions of a zettel, newest first.
+func (pp *polPlace) GetHistory(ctx context.Context, zid domain.ZettelID) ([]place.ZettelVersion, error) {
+	h, ok := pp.place.(place.Historian)
+	if !ok {
+		return nil, nil
+	}
+	if err := pp.checkRead(ctx, "GetHistory", zid); err != nil {
+		return nil, err
+	}
+	return h.GetHistory(ctx, zid)
+}
+
+// GetZettelVersion retrieves a prior version of a zettel.
+func (pp *polPlace) GetZettelVersion(ctx context.Context, zid domain.ZettelID, version string) (domain.Zettel, error) {
+	h, ok := pp.place.(place.Historian)
+	if !ok {
+		return domain.Zettel{}, &place.ErrUnknownID{Zid: zid}
+	}
+	if err := pp.checkRead(ctx, "GetZettelVersion", zid); err != nil {
+		return domain.Zettel{}, err
+	}
+	return h.GetZettelVersion(ctx, zid, version)
+}
+
+// checkRead returns an error, if the current user is not allowed to read the
+// current version of the given zettel.
+func (pp *polPlace) checkRead(ctx context.Context, op string, zid domain.ZettelID) error {
+	meta, err := pp.place.GetMeta(ctx, zid)
+	if err != nil {
+		return err
+	}
+	if user := place.GetUser(ctx); !pp.policy.CanRead(user, meta) {
+		return place.NewErrNotAuthorized(op, user, zid)
+	}
+	return nil
+}
+
+// HasTrash returns true, if the underlying place has a trash.
+func (pp *polPlace) HasTrash() bool { return place.HasTrash(pp.place) }
+
+// CanTrashZettel returns true, if deleting the zettel moves it into the
+// trash of the underlying place.
+func (pp *polPlace) CanTrashZettel(ctx context.Context, zid domain.ZettelID) bool {
+	return place.CanTrashZettel(ctx, pp.place, zid)
+}
+
+// GetTrash returns all deleted zettel that are still in the trash, most
+// recently deleted first.
+func (pp *polPlace) GetTrash(ctx context.Context) ([]place.TrashEntry, error) {
+	t, ok := pp.place.(place.Trash)
+	if !ok {
+		return nil, nil
+	}
+	entries, err := t.GetTrash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user := place.GetUser(ctx)
+	result := make([]place.TrashEntry, 0, len(entries))
+	for _, entry := range entries {
+		if pp.policy.CanRead(user, entry.Meta) {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// RestoreZettel moves a deleted zettel from the trash back to its original
+// zettel id.
+func (pp *polPlace) RestoreZettel(ctx context.Context, zid domain.ZettelID) error {
+	t, ok := pp.place.(place.Trash)
+	if !ok {
+		return &place.ErrUnknownID{Zid: zid}
+	}
+	entries, err := t.GetTrash(ctx)
+	if err != nil {
+		return err
+	}
+	user := place.GetUser(ctx)
+	for _, entry := range entries {
+		if entry.Meta.Zid == zid {
+			if pp.policy.CanCreate(user, entry.Meta) {
+				return t.RestoreZettel(ctx, zid)
+			}
+			return place.NewErrNotAuthorized("Restore", user, zid)
+		}
+	}
+	return &place.ErrUnknownID{Zid: zid}
+}
+
+// HasJournal returns true, if the underlying place records all changes.
+func (pp *polPlace) HasJournal() bool { return place.HasJournal(pp.place) }
+
+// GetJournal returns all recorded changes of the given zettel, newest first.
+// If the zettel id is invalid, the changes of all zettel are returned.
+//
+// The journal reveals the changes of all zettel, including the changing
+// users. Therefore, only users that are allowed to reload the place may read
+// it.
+func (pp *polPlace) GetJournal(ctx context.Context, zid domain.ZettelID) ([]place.JournalEntry, error) {
+	j, ok := pp.place.(place.Journal)
+	if !ok {
+		return nil, nil
+	}
+	if user := place.GetUser(ctx); !pp.policy.CanReload(user) {
+		return nil, place.NewErrNotAuthorized("GetJournal", user, zid)
+	}
+	return j.GetJournal(ctx, zid)
+}
@@ -0,0 +1,208 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package policy provides some interfaces and implementation for authorizsation policies.
+package policy
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+	_ "zettelstore.de/z/place/memplace"
+)
+
+// secretPolicy allows to read all zettel, except those with a title "Secret".
+type secretPolicy struct{ allPolicy }
+
+func (p *secretPolicy) CanRead(user *domain.Meta, meta *domain.Meta) bool {
+	return meta.GetDefault(domain.MetaKeyTitle, "") != "Secret"
+}
+
+func TestFilterLinks(t *testing.T) {
+	ctx := context.Background()
+	mp, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mp.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer mp.Stop(ctx)
+	create := func(title string, forward ...string) domain.ZettelID {
+		meta := domain.NewMeta(domain.InvalidZettelID)
+		meta.Set(domain.MetaKeyTitle, title)
+		if len(forward) > 0 {
+			meta.SetList(domain.MetaKeyForward, forward)
+			meta.SetList(domain.MetaKeyBrokenLinks, forward)
+			meta.Set(domain.MetaKeyForwardCount, "3")
+		}
+		zid, err := mp.CreateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return zid
+	}
+	public := create("Public")
+	secret := create("Secret")
+	const missing = "19000101000000"
+	zid := create("Links", public.Format(), secret.Format(), missing)
+
+	pp := NewPlace(mp, &secretPolicy{}, nil)
+	meta, err := pp.GetMeta(ctx, zid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := public.Format() + " " + missing
+	if got, _ := meta.Get(domain.MetaKeyForward); got != exp {
+		t.Errorf("forward: expected %q, but got %q", exp, got)
+	}
+	if got, _ := meta.Get(domain.MetaKeyBrokenLinks); got != exp {
+		t.Errorf("broken-links: expected %q, but got %q", exp, got)
+	}
+	if got, _ := meta.Get(domain.MetaKeyForwardCount); got != "2" {
+		t.Errorf("forward-count: expected %q, but got %q", "2", got)
+	}
+
+	metaList, err := pp.SelectMeta(ctx, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range metaList {
+		if m.Zid == secret {
+			t.Errorf("Secret zettel %v must not be selected", secret)
+		}
+		if m.Zid == zid {
+			if got, _ := m.Get(domain.MetaKeyForward); got != exp {
+				t.Errorf("forward of selected meta: expected %q, but got %q", exp, got)
+			}
+		}
+	}
+}
+
+func TestSelectMetaBacklink(t *testing.T) {
+	ctx := context.Background()
+	mp, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mp.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer mp.Stop(ctx)
+	create := func(title string, forward ...string) domain.ZettelID {
+		meta := domain.NewMeta(domain.InvalidZettelID)
+		meta.Set(domain.MetaKeyTitle, title)
+		if len(forward) > 0 {
+			meta.SetList(domain.MetaKeyForward, forward)
+		}
+		zid, err := mp.CreateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return zid
+	}
+	public := create("Public")
+	secret := create("Secret")
+	create("Links secret", secret.Format())
+	linksPublic := create("Links public", public.Format())
+
+	pp := NewPlace(mp, &secretPolicy{}, nil)
+	backlink := func(zid domain.ZettelID) *place.Filter {
+		return &place.Filter{Expr: place.FilterExpr{domain.MetaKeyForward: {zid.Format()}}}
+	}
+	metaList, err := pp.SelectMeta(ctx, backlink(secret), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metaList) != 0 {
+		t.Errorf("Backlinks of secret zettel %v must not be revealed, but got %v", secret, metaList[0].Zid)
+	}
+	metaList, err = pp.SelectMeta(ctx, backlink(public), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metaList) != 1 || metaList[0].Zid != linksPublic {
+		t.Errorf("Expected backlink %v of public zettel %v, but got %v", linksPublic, public, metaList)
+	}
+}
+
+func TestSelectMetaLinkCounts(t *testing.T) {
+	ctx := context.Background()
+	mp, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mp.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer mp.Stop(ctx)
+	create := func(title string, links ...string) domain.ZettelID {
+		meta := domain.NewMeta(domain.InvalidZettelID)
+		meta.Set(domain.MetaKeyTitle, title)
+		if len(links) > 0 {
+			count := strconv.Itoa(len(links))
+			meta.SetList(domain.MetaKeyForward, links)
+			meta.Set(domain.MetaKeyForwardCount, count)
+			meta.SetList(domain.MetaKeyBackward, links)
+			meta.Set(domain.MetaKeyBackwardCount, count)
+			meta.SetList(domain.MetaKeyBrokenLinks, links)
+		}
+		zid, err := mp.CreateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return zid
+	}
+	public := create("Public")
+	other := create("Other")
+	secret := create("Secret")
+	secret2 := create("Secret")
+	create("Many secret links", public.Format(), secret.Format(), secret2.Format())
+	few := create("Few public links", public.Format(), other.Format())
+
+	pp := NewPlace(mp, &secretPolicy{}, nil)
+	for _, key := range []string{domain.MetaKeyForwardCount, domain.MetaKeyBackwardCount} {
+		metaList, err := pp.SelectMeta(ctx, nil, &place.Sorter{Order: key, Descending: true, Limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(metaList) != 1 || metaList[0].Zid != few {
+			t.Errorf("Sorting by %v: expected %v first, but got %v", key, few, metaList)
+		}
+		filter := &place.Filter{Expr: place.FilterExpr{key: {">2"}}}
+		metaList, err = pp.SelectMeta(ctx, filter, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(metaList) != 0 {
+			t.Errorf("Filter %v>2 must not select %v", key, metaList[0].Zid)
+		}
+	}
+	filter := &place.Filter{Expr: place.FilterExpr{domain.MetaKeyBrokenLinks: {secret.Format()}}}
+	metaList, err := pp.SelectMeta(ctx, filter, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metaList) != 0 {
+		t.Errorf("Broken links to secret zettel %v must not select %v", secret, metaList[0].Zid)
+	}
+}
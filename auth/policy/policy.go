@@ -44,6 +44,9 @@ type Policy interface {
 
 	// User is allowed to delete zettel
 	CanDelete(user *domain.Meta, meta *domain.Meta) bool
+
+	// User is allowed to delete zettel, which will be moved into the trash
+	CanTrash(user *domain.Meta, meta *domain.Meta) bool
 }
 
 // NewPolicy creates a new policy object to check access autheorization.
@@ -0,0 +1,112 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/index"
+	"zettelstore.de/z/place"
+)
+
+// ---------- Subcommand: check ----------------------------------------------
+
+func flgCheck(fs *flag.FlagSet) {
+	fs.String("c", defConfigfile, "configuration file")
+	fs.String("d", "", "zettel directory")
+	fs.Bool("fix", false, "repair problems that can be fixed safely")
+}
+
+func cmdCheck(cfg *domain.Meta) (int, error) {
+	p, exitCode, err := setupPlaces(cfg)
+	if p == nil {
+		return exitCode, err
+	}
+	ctx := context.Background()
+	defer p.Stop(ctx)
+
+	fix := cfg.GetBool("fix")
+	remaining := 0
+	report := func(location string, problems []place.Problem) {
+		if len(problems) == 0 {
+			return
+		}
+		fmt.Println(location)
+		for _, pr := range problems {
+			where := pr.Path
+			if where == "" {
+				where = pr.Zid.Format()
+			}
+			if pr.Fixed {
+				fmt.Printf("  %v: %v (fixed)\n", where, pr.Message)
+			} else {
+				fmt.Printf("  %v: %v\n", where, pr.Message)
+				remaining++
+			}
+		}
+	}
+	for q := p; q != nil; q = q.Next() {
+		if c, ok := q.(place.Checker); ok {
+			problems, err := c.Check(ctx, fix)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to check place %v\n", q.Location())
+				return 2, err
+			}
+			report(q.Location(), problems)
+		}
+	}
+	problems, err := checkLinks(ctx, p)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to check links")
+		return 2, err
+	}
+	report("Links", problems)
+
+	if remaining > 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// checkLinks returns a problem for every reference to a zettel that does not
+// exist. The references are taken from the index of all zettel.
+func checkLinks(ctx context.Context, p place.Place) ([]place.Problem, error) {
+	metaList, err := p.SelectMeta(ctx, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	idx := index.NewIndexer(p)
+	idx.Wait()
+	var problems []place.Problem
+	for i := len(metaList) - 1; i >= 0; i-- {
+		zid := metaList[i].Zid
+		for _, broken := range idx.BrokenLinks(zid) {
+			problems = append(problems, place.Problem{
+				Zid:     zid,
+				Message: "Broken link to zettel " + broken.Format(),
+			})
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,98 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"zettelstore.de/z/config"
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/journal"
+)
+
+// ---------- Subcommand: journal --------------------------------------------
+
+func cmdJournal(cfg *domain.Meta) (int, error) {
+	path, ok := cfg.Get("journal-file")
+	if !ok || path == "" {
+		fmt.Fprintln(os.Stderr, "Journal file missing")
+		return 2, nil
+	}
+	entries, err := journal.ReadFile(path)
+	if err != nil {
+		return 2, err
+	}
+	if sid, ok := cfg.Get("arg-1"); ok {
+		zid, err := domain.ParseZettelID(sid)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Given zettel identification is not valid: %q\n", sid)
+			return 2, err
+		}
+		entries = journal.Filter(entries, zid)
+	}
+
+	if uri, ok := cfg.Get("replay-uri"); ok && uri != "" {
+		return replayJournal(cfg, uri, entries)
+	}
+	for _, entry := range entries {
+		fmt.Printf("%v\t%v\t%v", entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Op, entry.Zid.Format())
+		if entry.NewZid.IsValid() {
+			fmt.Printf("\t%v", entry.NewZid.Format())
+		}
+		if entry.User.IsValid() {
+			fmt.Printf("\tuser:%v", entry.User.Format())
+		}
+		if entry.Hash != "" {
+			fmt.Printf("\thash:%v", entry.Hash)
+		}
+		fmt.Println()
+	}
+	return 0, nil
+}
+
+// replayJournal applies the journal entries to the place with the given URI.
+func replayJournal(cfg *domain.Meta, uri string, entries []journal.Entry) (int, error) {
+	if err := setupPlaceKey(cfg, []string{uri}); err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to get passphrase for encrypted place")
+		return 2, err
+	}
+	p, err := connectPlaces([]string{uri, "globals:"})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to connect to place %q\n", uri)
+		return 2, err
+	}
+	ctx := context.Background()
+	if err := p.Start(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to start place %q\n", uri)
+		return 2, err
+	}
+	config.SetupConfiguration(p)
+	err = journal.Replay(ctx, entries, p)
+	if err1 := p.Stop(ctx); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return 1, err
+	}
+	fmt.Printf("%d changes replayed into %v\n", len(entries), p.Location())
+	return 0, nil
+}
@@ -20,15 +20,21 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 
 	"zettelstore.de/z/auth/policy"
 	"zettelstore.de/z/config"
 	"zettelstore.de/z/domain"
+	"zettelstore.de/z/index"
+	"zettelstore.de/z/journal"
 	"zettelstore.de/z/place"
 	"zettelstore.de/z/usecase"
 	"zettelstore.de/z/web/adapter"
@@ -64,11 +70,26 @@ func runFunc(cfg *domain.Meta) (int, error) {
 }
 
 func setupPlaces(cfg *domain.Meta) (place.Place, int, error) {
-	p, err := connectPlaces(getPlaceURIs(cfg))
+	placeURIs := getPlaceURIs(cfg)
+	journalPath, _ := cfg.Get("journal-file")
+	if journalPath != "" && hasEncryptedPlace(placeURIs) {
+		// The journal stores the full zettel data in clear text, which would
+		// reveal the content of encrypted places.
+		fmt.Fprintln(os.Stderr, "A journal file cannot be used together with encrypted places")
+		return nil, 2, errJournalEncrypt
+	}
+	if err := setupPlaceKey(cfg, placeURIs); err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to get passphrase for encrypted places")
+		return nil, 2, err
+	}
+	p, err := connectPlaces(placeURIs)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Unable to connect to specified places")
 		return nil, 2, err
 	}
+	if journalPath != "" {
+		p = journal.NewPlace(p, journalPath)
+	}
 	if err := p.Start(context.Background()); err != nil {
 		fmt.Fprintln(os.Stderr, "Unable to start zettel store")
 		return nil, 2, err
@@ -97,6 +118,46 @@ func getPlaceURIs(cfg *domain.Meta) []string {
 	return result
 }
 
+// setupPlaceKey retrieves the passphrase for encrypted places. It is read
+// from the file named by the startup configuration key "place-key-file". If
+// there is no such file, but some place is encrypted, the passphrase is read
+// from the terminal.
+func setupPlaceKey(cfg *domain.Meta, placeURIs []string) error {
+	if path, ok := cfg.Get("place-key-file"); ok && path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		config.SetupPlaceKey(bytes.TrimSpace(data))
+		return nil
+	}
+	if hasEncryptedPlace(placeURIs) {
+		passphrase, err := getPassword("Passphrase")
+		if err != nil {
+			return err
+		}
+		config.SetupPlaceKey([]byte(passphrase))
+	}
+	return nil
+}
+
+// hasEncryptedPlace returns true, if some of the given places stores its
+// files encrypted.
+func hasEncryptedPlace(placeURIs []string) bool {
+	for _, uri := range placeURIs {
+		u, err := url.Parse(uri)
+		if err != nil {
+			continue
+		}
+		if encrypt := u.Query().Get("encrypt"); encrypt != "" && domain.BoolValue(encrypt) {
+			return true
+		}
+	}
+	return false
+}
+
+var errJournalEncrypt = errors.New("journal file not allowed for encrypted places")
+
 func connectPlaces(placeURIs []string) (place.Place, error) {
 	if len(placeURIs) == 0 {
 		return nil, nil
@@ -116,11 +177,12 @@ func wrapPolicyPlace(p place.Place, pol policy.Policy) place.Place {
 	return policy.NewPlace(p, pol, nil)
 }
 func setupRouting(up place.Place, readonly bool) http.Handler {
-	pp := up
+	idx := index.NewIndexer(up)
+	pp := index.NewPlace(up, idx)
 	var pol policy.Policy
 	if config.WithAuth() || readonly {
 		pol = policy.NewPolicy("default")
-		pp = wrapPolicyPlace(up, pol)
+		pp = wrapPolicyPlace(pp, pol)
 	} else {
 		pol = policy.NewPolicy("all")
 	}
@@ -128,6 +190,7 @@ func setupRouting(up place.Place, readonly bool) http.Handler {
 
 	ucGetMeta := usecase.NewGetMeta(pp)
 	ucGetZettel := usecase.NewGetZettel(pp)
+	ucGetSchema := usecase.NewGetSchema(up)
 	listHTMLMetaHandler := adapter.MakeListHTMLMetaHandler(te, usecase.NewListMeta(pp))
 	getHTMLZettelHandler := adapter.MakeGetHTMLZettelHandler(te, ucGetZettel, ucGetMeta)
 
@@ -138,28 +201,57 @@ func setupRouting(up place.Place, readonly bool) http.Handler {
 	router.AddListRoute('a', http.MethodPut, adapter.MakeRenewAuthHandler())
 	router.AddZettelRoute('a', http.MethodGet, adapter.MakeGetLogoutHandler())
 	router.AddListRoute('c', http.MethodGet, adapter.MakeReloadHandler(usecase.NewReload(pp)))
+	if t, ok := pp.(place.Trash); ok && t.HasTrash() {
+		router.AddListRoute('d', http.MethodGet, adapter.MakeGetTrashHandler(te, usecase.NewGetTrash(t)))
+		if !readonly {
+			router.AddListRoute('d', http.MethodPost, adapter.MakePostRestoreHandler(usecase.NewRestoreZettel(t)))
+		}
+	}
 	if !readonly {
 		router.AddZettelRoute('d', http.MethodGet, adapter.MakeGetDeleteZettelHandler(te, ucGetZettel))
 		router.AddZettelRoute('d', http.MethodPost, adapter.MakePostDeleteZettelHandler(usecase.NewDeleteZettel(pp)))
-		router.AddZettelRoute('e', http.MethodGet, adapter.MakeEditGetZettelHandler(te, ucGetZettel))
-		router.AddZettelRoute('e', http.MethodPost, adapter.MakeEditSetZettelHandler(usecase.NewUpdateZettel(pp)))
+		router.AddZettelRoute('e', http.MethodGet, adapter.MakeEditGetZettelHandler(te, ucGetZettel, ucGetSchema))
+		router.AddZettelRoute('e', http.MethodPost, adapter.MakeEditSetZettelHandler(
+			te, ucGetZettel, ucGetSchema, usecase.NewUpdateZettel(pp, ucGetSchema)))
 	}
+	router.AddListRoute('e', http.MethodGet, adapter.MakeGetEventsHandler(adapter.NewEventHub(up, pol)))
 	router.AddListRoute('h', http.MethodGet, listHTMLMetaHandler)
 	router.AddZettelRoute('h', http.MethodGet, getHTMLZettelHandler)
 	router.AddZettelRoute('i', http.MethodGet, adapter.MakeGetInfoHandler(te, ucGetZettel, ucGetMeta))
+	if j, ok := pp.(place.Journal); ok && j.HasJournal() {
+		getJournalHandler := adapter.MakeGetJournalHandler(te, usecase.NewGetJournal(j))
+		router.AddListRoute('j', http.MethodGet, getJournalHandler)
+		router.AddZettelRoute('j', http.MethodGet, getJournalHandler)
+	}
 	if !readonly {
-		router.AddZettelRoute('n', http.MethodGet, adapter.MakeGetNewZettelHandler(te, ucGetZettel))
-		router.AddZettelRoute('n', http.MethodPost, adapter.MakePostNewZettelHandler(usecase.NewNewZettel(pp)))
+		router.AddZettelRoute('n', http.MethodGet, adapter.MakeGetNewZettelHandler(te, ucGetZettel, ucGetSchema))
+		router.AddZettelRoute('n', http.MethodPost, adapter.MakePostNewZettelHandler(
+			te, ucGetSchema, usecase.NewNewZettel(pp, ucGetSchema)))
 	}
 	router.AddListRoute('r', http.MethodGet, adapter.MakeListRoleHandler(te, usecase.NewListRole(pp)))
 	if !readonly {
-		router.AddZettelRoute('r', http.MethodGet, adapter.MakeGetRenameZettelHandler(te, ucGetMeta))
-		router.AddZettelRoute('r', http.MethodPost, adapter.MakePostRenameZettelHandler(usecase.NewRenameZettel(pp)))
+		ucRename := usecase.NewRenameZettel(pp, idx, usecase.NewUpdateZettel(pp, ucGetSchema))
+		router.AddZettelRoute('r', http.MethodGet, adapter.MakeGetRenameZettelHandler(te, ucRename))
+		router.AddZettelRoute('r', http.MethodPost, adapter.MakePostRenameZettelHandler(te, ucRename))
 	}
 	router.AddListRoute('t', http.MethodGet, adapter.MakeListTagsHandler(te, usecase.NewListTags(pp)))
-	router.AddListRoute('s', http.MethodGet, adapter.MakeSearchHandler(te, usecase.NewSearch(pp)))
+	router.AddListRoute('s', http.MethodGet, adapter.MakeSearchHandler(te, usecase.NewSearch(pp, idx)))
+	if h, ok := pp.(place.Historian); ok && h.HasHistory() {
+		ucGetZettelVersion := usecase.NewGetZettelVersion(h)
+		router.AddZettelRoute('v', http.MethodGet, adapter.MakeGetHistoryHandler(
+			te, ucGetZettel, usecase.NewGetHistory(h), ucGetZettelVersion))
+		if !readonly {
+			router.AddZettelRoute('v', http.MethodPost, adapter.MakePostRestoreZettelHandler(
+				ucGetZettelVersion, usecase.NewUpdateZettel(pp, ucGetSchema)))
+		}
+	}
 	router.AddListRoute('z', http.MethodGet, adapter.MakeListMetaHandler(te, usecase.NewListMeta(pp)))
 	router.AddZettelRoute('z', http.MethodGet, adapter.MakeGetZettelHandler(te, ucGetZettel, ucGetMeta))
+	if !readonly {
+		router.AddListRoute('z', http.MethodPost, adapter.MakePostCreateZettelHandler(usecase.NewNewZettel(pp, ucGetSchema)))
+		router.AddZettelRoute('z', http.MethodPut, adapter.MakePutUpdateZettelHandler(usecase.NewUpdateZettel(pp, ucGetSchema)))
+		router.AddZettelRoute('z', http.MethodDelete, adapter.MakeDeleteZettelHandler(usecase.NewDeleteZettel(pp)))
+	}
 	return session.NewHandler(router, usecase.NewGetUserByZid(up))
 }
 
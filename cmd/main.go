@@ -73,6 +73,20 @@ func init() {
 		Name: "password",
 		Func: cmdPassword,
 	})
+	RegisterCommand(Command{
+		Name:  "check",
+		Func:  cmdCheck,
+		Flags: flgCheck,
+	})
+	RegisterCommand(Command{
+		Name: "journal",
+		Func: cmdJournal,
+		Flags: func(fs *flag.FlagSet) {
+			fs.String("c", defConfigfile, "configuration file")
+			fs.String("j", "", "journal file")
+			fs.String("replay", "", "place URI to replay the journal into")
+		},
+	})
 }
 
 func fmtVersion() {
@@ -115,6 +129,12 @@ func getConfig(fs *flag.FlagSet) (cfg *domain.Meta) {
 			cfg.Set("verbose", flg.Value.String())
 		case "t":
 			cfg.Set("target-format", flg.Value.String())
+		case "j":
+			cfg.Set("journal-file", flg.Value.String())
+		case "replay":
+			cfg.Set("replay-uri", flg.Value.String())
+		case "fix":
+			cfg.Set("fix", flg.Value.String())
 		}
 	})
 
@@ -36,4 +36,7 @@ import (
 	_ "zettelstore.de/z/place/constplace"  // Allow to use global internal place.
 	_ "zettelstore.de/z/place/dirplace"    // Allow to use directory place.
 	_ "zettelstore.de/z/place/memplace"    // Allow to use memory place.
+	_ "zettelstore.de/z/place/mirrorplace" // Allow to use mirror place.
+	_ "zettelstore.de/z/place/remoteplace" // Allow to use remote Zettelstore place.
+	_ "zettelstore.de/z/place/zipplace"    // Allow to use zip archive place.
 )
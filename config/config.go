@@ -21,7 +21,14 @@
 package config
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
 
 	"zettelstore.de/z/domain"
 	"zettelstore.de/z/place"
@@ -33,14 +40,31 @@ import (
 var configStock stock.Stock
 
 // SetupConfiguration enables the configuration data.
-func SetupConfiguration(place place.Place) {
+func SetupConfiguration(p place.Place) {
 	if configStock != nil {
 		panic("configStock already set")
 	}
-	configStock = stock.NewStock(place)
+	configStock = stock.NewStock(p)
 	if err := configStock.Subscribe(domain.ConfigurationID); err != nil {
 		panic(err)
 	}
+	domain.SetKeyTypeFunc(getKeyType)
+
+	// The observer is only called by the stock, one change after the other.
+	generation := KeyTypesGeneration()
+	configStock.RegisterChangeObserver(func(ci place.ChangeInfo) {
+		if ci.Zid != domain.ConfigurationID {
+			return
+		}
+		if gen := KeyTypesGeneration(); gen != generation {
+			generation = gen
+
+			// All meta data must be parsed again with the changed key types.
+			if err := p.Reload(context.Background()); err != nil {
+				log.Println("Unable to reload places after change of key types:", err)
+			}
+		}
+	})
 }
 
 // getConfigurationMeta returns the meta data of the configuration zettel.
@@ -172,3 +196,85 @@ func GetIconMaterial() string {
 		URLPrefix(),
 		domain.MaterialIconID.Format())
 }
+
+// KeyTypePrefix is the prefix of all keys of the configuration zettel, which
+// declare the type of the key given after the prefix, e.g. "key-type-project".
+const KeyTypePrefix = "key-type-"
+
+// keyTypes caches the key types declared in the configuration zettel. The
+// cache is rebuilt, when the stock delivers new meta data.
+var keyTypes struct {
+	mx         sync.Mutex
+	config     *domain.Meta
+	types      map[string]byte
+	generation string
+}
+
+// currentKeyTypes returns the key types declared in the configuration
+// zettel, together with their generation.
+func currentKeyTypes() (map[string]byte, string) {
+	var config *domain.Meta
+	if configStock != nil {
+		config = getConfigurationMeta()
+	}
+	keyTypes.mx.Lock()
+	defer keyTypes.mx.Unlock()
+	if config != keyTypes.config || keyTypes.types == nil {
+		keyTypes.config = config
+		keyTypes.types = calcKeyTypes(config)
+		keyTypes.generation = calcGeneration(keyTypes.types)
+	}
+	return keyTypes.types, keyTypes.generation
+}
+
+// getKeyType returns the type of the given key, as declared in the
+// configuration zettel.
+func getKeyType(key string) (byte, bool) {
+	types, _ := currentKeyTypes()
+	t, ok := types[key]
+	return t, ok
+}
+
+// KeyTypesGeneration returns a value that changes, whenever the key types
+// declared in the configuration zettel change. Meta data that was parsed with
+// key types of another generation must be parsed again. If no key types are
+// declared, the empty string is returned.
+func KeyTypesGeneration() string {
+	_, generation := currentKeyTypes()
+	return generation
+}
+
+func calcGeneration(types map[string]byte) string {
+	if len(types) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(types))
+	for key := range types {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s:%c\n", key, types[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// calcKeyTypes returns the types of all keys that are declared by "key-type-"
+// entries of the configuration zettel. Entries for predefined keys and entries
+// with an unknown type are ignored.
+func calcKeyTypes(config *domain.Meta) map[string]byte {
+	result := make(map[string]byte)
+	if config != nil {
+		for _, p := range config.Pairs() {
+			key := strings.TrimPrefix(p.Key, KeyTypePrefix)
+			if key == p.Key || !domain.KeyIsValid(key) || domain.IsPredefinedKey(key) {
+				continue
+			}
+			if t, ok := domain.TypeFromName(strings.TrimSpace(p.Value)); ok {
+				result[key] = t
+			}
+		}
+	}
+	return result
+}
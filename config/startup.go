@@ -38,6 +38,7 @@ var startupConfig struct {
 	secret        []byte
 	htmlLifetime  time.Duration
 	apiLifetime   time.Duration
+	placeKey      []byte
 }
 
 // SetupStartup initializes the startup data.
@@ -126,3 +127,11 @@ func Secret() []byte { return startupConfig.secret }
 func TokenLifetime() (htmlLifetime, apiLifetime time.Duration) {
 	return startupConfig.htmlLifetime, startupConfig.apiLifetime
 }
+
+// SetupPlaceKey sets the passphrase of all encrypted places. It must be
+// called before the places are started.
+func SetupPlaceKey(passphrase []byte) { startupConfig.placeKey = passphrase }
+
+// PlaceKey returns the passphrase of all encrypted places, or nil if none was
+// given.
+func PlaceKey() []byte { return startupConfig.placeKey }
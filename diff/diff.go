@@ -0,0 +1,125 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package diff computes the differences between two texts.
+package diff
+
+import "strings"
+
+// Op specifies how a line was changed.
+type Op int
+
+// Constants for Op
+const (
+	OpEqual  Op = iota // Line is in both texts
+	OpInsert           // Line is only in the second text
+	OpDelete           // Line is only in the first text
+)
+
+// Line is one line of the difference between two texts.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Lines returns the line-based difference between two texts.
+func Lines(a, b string) []Line {
+	return Compute(splitLines(a), splitLines(b))
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Compute returns a shortest sequence of edits to transform the first list
+// of lines into the second one. It uses the algorithm of Eugene W. Myers,
+// "An O(ND) Difference Algorithm and Its Variations".
+func Compute(a, b []string) []Line {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+	offset := max
+	v := make([]int, 2*max+2)
+	// trace[d] holds v[-d..d] before step d, which are all values needed to
+	// backtrack. This needs O(D*D) memory instead of O((N+M)*D).
+	var trace [][]int
+	found := 0
+loop:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = d
+				break loop
+			}
+		}
+	}
+
+	result := make([]Line, 0, max)
+	x, y := n, m
+	for d := found; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[d+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			result = append(result, Line{OpEqual, a[x]})
+		}
+		if x == prevX {
+			y--
+			result = append(result, Line{OpInsert, b[y]})
+		} else {
+			x--
+			result = append(result, Line{OpDelete, a[x]})
+		}
+	}
+	for x > 0 {
+		x--
+		result = append(result, Line{OpEqual, a[x]})
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package diff_test provides some tests for computing differences.
+package diff_test
+
+import (
+	"strings"
+	"testing"
+
+	"zettelstore.de/z/diff"
+)
+
+func encode(lines []diff.Line) string {
+	var sb strings.Builder
+	for _, l := range lines {
+		switch l.Op {
+		case diff.OpEqual:
+			sb.WriteByte('=')
+		case diff.OpInsert:
+			sb.WriteByte('+')
+		case diff.OpDelete:
+			sb.WriteByte('-')
+		}
+		sb.WriteString(l.Text)
+		sb.WriteByte(' ')
+	}
+	return sb.String()
+}
+
+func TestLines(t *testing.T) {
+	testcases := []struct {
+		a, b string
+		exp  string
+	}{
+		{"", "", ""},
+		{"a\n", "", "-a "},
+		{"", "a\nb", "+a +b "},
+		{"a\nb\nc\n", "a\nb\nc\n", "=a =b =c "},
+		{"a\nb\nc", "a\nc", "=a -b =c "},
+		{"a\nc", "a\nb\nc", "=a +b =c "},
+		{"a\nb\nc", "a\nx\nc", "=a -b +x =c "},
+		{"a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc", "-a -b =c +b =a =b -b =a +c "},
+	}
+	for i, tc := range testcases {
+		got := encode(diff.Lines(tc.a, tc.b))
+		if got != tc.exp {
+			t.Errorf("TC=%d: a=%q, b=%q\nexp=%q\ngot=%q", i, tc.a, tc.b, tc.exp, got)
+		}
+	}
+}
+
+func TestLinesRestore(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive"
+	b := "zero\none\nthree\nfour\nsix\nfive"
+	var olds, news []string
+	for _, l := range diff.Lines(a, b) {
+		if l.Op != diff.OpInsert {
+			olds = append(olds, l.Text)
+		}
+		if l.Op != diff.OpDelete {
+			news = append(news, l.Text)
+		}
+	}
+	if got := strings.Join(olds, "\n"); got != a {
+		t.Errorf("Old text not restored: %q", got)
+	}
+	if got := strings.Join(news, "\n"); got != b {
+		t.Errorf("New text not restored: %q", got)
+	}
+}
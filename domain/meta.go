@@ -25,6 +25,7 @@ import (
 	"io"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"zettelstore.de/z/input"
@@ -68,33 +69,51 @@ func KeyIsValid(key string) bool {
 // Predefined keys.
 const (
 	MetaKeyID               = "id"
+	MetaKeyBackward         = "backward"
+	MetaKeyBackwardCount    = "backward-count"
+	MetaKeyBrokenLinks      = "broken-links"
 	MetaKeyTitle            = "title"
 	MetaKeyTags             = "tags"
 	MetaKeySyntax           = "syntax"
 	MetaKeyRole             = "role"
 	MetaKeyCopyright        = "copyright"
 	MetaKeyCred             = "cred"
+	MetaKeyContentLength    = "content-length"
+	MetaKeyCreated          = "created"
 	MetaKeyDefaultCopyright = "default-copyright"
 	MetaKeyDefaultLang      = "default-lang"
 	MetaKeyDefaultLicense   = "default-license"
 	MetaKeyDefaultRole      = "default-role"
 	MetaKeyDefaultSyntax    = "default-syntax"
 	MetaKeyDefaultTitle     = "default-title"
+	MetaKeyDue              = "due"
+	MetaKeyEditor           = "editor"
+	MetaKeyFolder           = "folder"
+	MetaKeyForward          = "forward"
+	MetaKeyForwardCount     = "forward-count"
 	MetaKeyIconMaterial     = "icon-material"
 	MetaKeyIdent            = "ident"
 	MetaKeyLang             = "lang"
 	MetaKeyLicense          = "license"
+	MetaKeyModified         = "modified"
+	MetaKeyPriority         = "priority"
+	MetaKeyPublished        = "published"
+	MetaKeySchemaAllowed    = "schema-allowed"
+	MetaKeySchemaRequired   = "schema-required"
+	MetaKeySchemaRole       = "schema-role"
 	MetaKeySiteName         = "site-name"
 	MetaKeyStart            = "start"
 	MetaKeyURL              = "url"
 	MetaKeyUserRole         = "user-role"
 	MetaKeyVisibility       = "visibility"
+	MetaKeyWordCount        = "word-count"
 	MetaKeyYAMLHeader       = "yaml-header"
 	MetaKeyZettelFileSyntax = "zettel-file-syntax"
 )
 
 // Important values for some keys.
 const (
+	MetaValueRoleSchema       = "schema"
 	MetaValueRoleUser         = "user"
 	MetaValueVisibilityOwner  = "owner"
 	MetaValueVisibilityLogin  = "login"
@@ -103,40 +122,96 @@ const (
 
 // Supported key types.
 const (
-	MetaTypeBool    = 'b'
-	MetaTypeCred    = 'c'
-	MetaTypeEmpty   = 'e'
-	MetaTypeID      = 'i'
-	MetaTypeString  = 's'
-	MetaTypeTagSet  = 'T'
-	MetaTypeURL     = 'u'
-	MetaTypeUnknown = '\000'
-	MetaTypeWord    = 'w'
-	MetaTypeWordSet = 'W'
+	MetaTypeBool        = 'b'
+	MetaTypeCred        = 'c'
+	MetaTypeEmpty       = 'e'
+	MetaTypeID          = 'i'
+	MetaTypeZettelIDSet = 'I'
+	MetaTypeNumber      = 'n'
+	MetaTypeString      = 's'
+	MetaTypeTimestamp   = 't'
+	MetaTypeTagSet      = 'T'
+	MetaTypeURL         = 'u'
+	MetaTypeUnknown     = '\000'
+	MetaTypeWord        = 'w'
+	MetaTypeWordSet     = 'W'
 )
 
+var typeNames = map[byte]string{
+	MetaTypeBool:        "bool",
+	MetaTypeCred:        "credential",
+	MetaTypeEmpty:       "empty",
+	MetaTypeID:          "id",
+	MetaTypeZettelIDSet: "id-set",
+	MetaTypeNumber:      "number",
+	MetaTypeString:      "string",
+	MetaTypeTimestamp:   "timestamp",
+	MetaTypeTagSet:      "tag-set",
+	MetaTypeURL:         "url",
+	MetaTypeWord:        "word",
+	MetaTypeWordSet:     "word-set",
+}
+
+// TypeName returns the name of the given key type, e.g. "timestamp" for
+// MetaTypeTimestamp. The name of an unknown type is "unknown".
+func TypeName(keyType byte) string {
+	if name, ok := typeNames[keyType]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// TypeFromName returns the key type with the given name. The bool value
+// signals, whether the name denotes a key type.
+func TypeFromName(name string) (byte, bool) {
+	name = strings.ToLower(name)
+	for keyType, typeName := range typeNames {
+		if typeName == name {
+			return keyType, true
+		}
+	}
+	return MetaTypeUnknown, false
+}
+
 var keyTypeMap = map[string]byte{
 	MetaKeyID:               MetaTypeID,
+	MetaKeyBackward:         MetaTypeZettelIDSet,
+	MetaKeyBackwardCount:    MetaTypeNumber,
+	MetaKeyBrokenLinks:      MetaTypeZettelIDSet,
 	MetaKeyTitle:            MetaTypeString,
 	MetaKeyTags:             MetaTypeTagSet,
 	MetaKeySyntax:           MetaTypeWord,
 	MetaKeyRole:             MetaTypeWord,
 	MetaKeyCopyright:        MetaTypeString,
+	MetaKeyContentLength:    MetaTypeNumber,
 	MetaKeyCred:             MetaTypeCred,
+	MetaKeyCreated:          MetaTypeTimestamp,
 	MetaKeyDefaultCopyright: MetaTypeString,
 	MetaKeyDefaultLicense:   MetaTypeEmpty,
 	MetaKeyDefaultLang:      MetaTypeWord,
 	MetaKeyDefaultRole:      MetaTypeWord,
 	MetaKeyDefaultSyntax:    MetaTypeWord,
 	MetaKeyDefaultTitle:     MetaTypeString,
+	MetaKeyDue:              MetaTypeTimestamp,
+	MetaKeyEditor:           MetaTypeID,
+	MetaKeyFolder:           MetaTypeString,
+	MetaKeyForward:          MetaTypeZettelIDSet,
+	MetaKeyForwardCount:     MetaTypeNumber,
 	MetaKeyIdent:            MetaTypeWord,
 	MetaKeyLang:             MetaTypeWord,
 	MetaKeyLicense:          MetaTypeEmpty,
+	MetaKeyModified:         MetaTypeTimestamp,
+	MetaKeyPriority:         MetaTypeNumber,
+	MetaKeyPublished:        MetaTypeTimestamp,
+	MetaKeySchemaAllowed:    MetaTypeWordSet,
+	MetaKeySchemaRequired:   MetaTypeWordSet,
+	MetaKeySchemaRole:       MetaTypeWord,
 	MetaKeySiteName:         MetaTypeString,
 	MetaKeyStart:            MetaTypeID,
 	MetaKeyURL:              MetaTypeURL,
 	MetaKeyUserRole:         MetaTypeWord,
 	MetaKeyVisibility:       MetaTypeWord,
+	MetaKeyWordCount:        MetaTypeNumber,
 	MetaKeyYAMLHeader:       MetaTypeBool,
 	MetaKeyZettelFileSyntax: MetaTypeWordSet,
 }
@@ -153,9 +228,50 @@ func KeyType(key string) byte {
 	if t, ok := keyTypeMap[key]; ok {
 		return t
 	}
+	if keyTypeFunc != nil {
+		if t, ok := keyTypeFunc(key); ok {
+			return t
+		}
+	}
 	return MetaTypeUnknown
 }
 
+// IsPredefinedKey returns true, if the given key has a predefined type.
+func IsPredefinedKey(key string) bool {
+	_, ok := keyTypeMap[key]
+	return ok
+}
+
+// keyTypeFunc returns the type of a key that is not predefined.
+var keyTypeFunc func(key string) (byte, bool)
+
+// SetKeyTypeFunc registers a function that returns the type of keys, which
+// are not predefined. The bool value of the function signals, whether a type
+// is known for the key. The types of predefined keys cannot be changed. The
+// function must be registered before the zettelstore starts to work.
+func SetKeyTypeFunc(f func(key string) (byte, bool)) {
+	keyTypeFunc = f
+}
+
+// computedKeys contains all keys, whose values are computed by the
+// zettelstore. They are never stored.
+var computedKeys = map[string]bool{
+	MetaKeyBackward:      true,
+	MetaKeyBackwardCount: true,
+	MetaKeyBrokenLinks:   true,
+	MetaKeyContentLength: true,
+	MetaKeyFolder:        true,
+	MetaKeyForward:       true,
+	MetaKeyForwardCount:  true,
+	MetaKeyWordCount:     true,
+}
+
+// IsComputedKey returns true, if the value of the given key is computed and
+// therefore must not be stored.
+func IsComputedKey(key string) bool {
+	return computedKeys[key]
+}
+
 // BoolValue returns the value interpreted as a bool.
 func BoolValue(value string) bool {
 	if len(value) > 0 {
@@ -299,10 +415,13 @@ func (m *Meta) doPairs(first bool) []MetaPair {
 	return result
 }
 
-// Write writes a zettel meta to a writer.
+// Write writes a zettel meta to a writer. Computed values are not written.
 func (m *Meta) Write(w io.Writer) (int, error) {
 	var buf bytes.Buffer
 	for _, p := range m.Pairs() {
+		if IsComputedKey(p.Key) {
+			continue
+		}
 		buf.WriteString(p.Key)
 		buf.WriteString(": ")
 		buf.WriteString(p.Value)
@@ -501,6 +620,10 @@ func addToMeta(m *Meta, key, val string) {
 		// Empty key and 'id' key will be ignored
 		return
 	}
+	if IsComputedKey(key) {
+		// Computed values are never stored, stale values will be ignored
+		return
+	}
 
 	switch KeyType(key) {
 	case MetaTypeString:
@@ -517,8 +640,19 @@ func addToMeta(m *Meta, key, val string) {
 		if _, err := ParseZettelID(val); err == nil {
 			m.Set(key, val)
 		}
-	//case MetaContextKey:
-	//	addSet(m, key, v, func(s string) bool { return IsValidID(s) })
+	case MetaTypeZettelIDSet:
+		addSet(m, key, v, func(s string) bool {
+			_, err := ParseZettelID(s)
+			return err == nil
+		})
+	case MetaTypeNumber:
+		if n, ok := NumberValue(v); ok {
+			m.Set(key, strconv.FormatInt(n, 10))
+		}
+	case MetaTypeTimestamp:
+		if ts, ok := NormalizeTimestamp(v); ok {
+			m.Set(key, ts)
+		}
 	case MetaTypeEmpty:
 		fallthrough
 	default:
@@ -77,6 +77,11 @@ func TestWriteMeta(t *testing.T) {
 	m.Set("user", "zettel")
 	m.Set("auth", "basic")
 	assertWriteMeta(t, m, "title: TITLE\nauth: basic\nuser: zettel\n")
+
+	m = newMeta("TITLE", nil, "")
+	m.Set(MetaKeyBackward, "12345678901234")
+	m.Set(MetaKeyForward, "12345678901234")
+	assertWriteMeta(t, m, "title: TITLE\n")
 }
 
 func TestTitleHeader(t *testing.T) {
@@ -301,3 +306,29 @@ func equalPairs(one, two []MetaPair) bool {
 	}
 	return true
 }
+
+func TestKeyTypeFunc(t *testing.T) {
+	defer SetKeyTypeFunc(nil)
+	SetKeyTypeFunc(func(key string) (byte, bool) {
+		switch key {
+		case "project":
+			return MetaTypeWord, true
+		case "title":
+			return MetaTypeNumber, true
+		}
+		return MetaTypeUnknown, false
+	})
+	if kt := KeyType("project"); kt != MetaTypeWord {
+		t.Errorf("Expected type %q for key project, but got %q", MetaTypeWord, kt)
+	}
+	if kt := KeyType("title"); kt != MetaTypeString {
+		t.Errorf("Predefined type of key title changed to %q", kt)
+	}
+	if kt := KeyType("status"); kt != MetaTypeUnknown {
+		t.Errorf("Expected unknown type for key status, but got %q", kt)
+	}
+	m := parseMetaStr("project: ZettelStore\n")
+	if got, _ := m.Get("project"); got != "zettelstore" {
+		t.Errorf("Expected word value %q, but got %q", "zettelstore", got)
+	}
+}
@@ -0,0 +1,268 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package domain provides domain specific types, constants, and functions.
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Prefixes of meta keys of a schema zettel, that specify the type and the
+// allowed values of the key given after the prefix.
+const (
+	SchemaTypePrefix   = "schema-type-"
+	SchemaValuesPrefix = "schema-values-"
+)
+
+// Schema describes the meta data of all zettel with a specific role. It is
+// specified by one or more zettel with role "schema".
+type Schema struct {
+	Role     string
+	Required []string            // Keys that must have a non-empty value.
+	Allowed  map[string]bool     // If not nil, only these and predefined keys are allowed.
+	Types    map[string]byte     // Types of keys, overriding their predefined type.
+	Values   map[string][]string // Allowed values of keys.
+}
+
+// NewSchema creates a schema for the given role from the meta data of all
+// schema zettel for this role.
+func NewSchema(role string, metas []*Meta) *Schema {
+	s := &Schema{
+		Role:   role,
+		Types:  make(map[string]byte),
+		Values: make(map[string][]string),
+	}
+	required := make(map[string]bool)
+	for _, m := range metas {
+		for _, key := range m.GetListOrNil(MetaKeySchemaRequired) {
+			if !required[key] {
+				required[key] = true
+				s.Required = append(s.Required, key)
+			}
+		}
+		if allowed, ok := m.GetList(MetaKeySchemaAllowed); ok {
+			if s.Allowed == nil {
+				s.Allowed = make(map[string]bool, len(allowed))
+			}
+			for _, key := range allowed {
+				s.Allowed[key] = true
+			}
+		}
+		for _, p := range m.Pairs() {
+			if key := strings.TrimPrefix(p.Key, SchemaTypePrefix); key != p.Key {
+				if keyType, ok := TypeFromName(strings.TrimSpace(p.Value)); ok {
+					s.Types[key] = keyType
+				}
+			} else if key := strings.TrimPrefix(p.Key, SchemaValuesPrefix); key != p.Key {
+				s.Values[key] = append(s.Values[key], ListFromValue(p.Value)...)
+			}
+		}
+	}
+	if s.Allowed != nil {
+		for _, key := range s.Keys() {
+			s.Allowed[key] = true
+		}
+	}
+	return s
+}
+
+// Keys returns all keys that are mentioned in the schema, sorted.
+func (s *Schema) Keys() []string {
+	set := make(map[string]bool, len(s.Required)+len(s.Allowed)+len(s.Types)+len(s.Values))
+	for _, key := range s.Required {
+		set[key] = true
+	}
+	for key := range s.Allowed {
+		set[key] = true
+	}
+	for key := range s.Types {
+		set[key] = true
+	}
+	for key := range s.Values {
+		set[key] = true
+	}
+	result := make([]string, 0, len(set))
+	for key := range set {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// IsRequired returns true, if the given key must have a value.
+func (s *Schema) IsRequired(key string) bool {
+	for _, k := range s.Required {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyType returns the type of the given key, according to the schema.
+func (s *Schema) KeyType(key string) byte {
+	if keyType, ok := s.Types[key]; ok {
+		return keyType
+	}
+	return KeyType(key)
+}
+
+// SchemaProblem describes a violation of a schema by a specific meta key.
+type SchemaProblem struct {
+	Key     string
+	Message string
+}
+
+func (sp SchemaProblem) String() string { return sp.Key + ": " + sp.Message }
+
+// Validate checks the given meta data against the schema and returns all
+// problems found.
+func (s *Schema) Validate(m *Meta) []SchemaProblem {
+	var problems []SchemaProblem
+	for _, key := range s.Required {
+		if value, ok := m.Get(key); !ok || value == "" {
+			problems = append(problems, SchemaProblem{key, "required key is missing"})
+		}
+	}
+	for _, p := range m.Pairs() {
+		if IsComputedKey(p.Key) {
+			continue
+		}
+		if !s.isKnownKey(p.Key) {
+			if s.Allowed != nil {
+				similar := s.similarKey(p.Key, 2)
+				msg := "key is not allowed"
+				if similar != "" {
+					msg = fmt.Sprintf("key is not allowed, did you mean %q?", similar)
+				}
+				problems = append(problems, SchemaProblem{p.Key, msg})
+				continue
+			}
+			if similar := s.similarKey(p.Key, 1); similar != "" {
+				problems = append(problems, SchemaProblem{
+					p.Key, fmt.Sprintf("unknown key, did you mean %q?", similar)})
+				continue
+			}
+		}
+		keyType := s.KeyType(p.Key)
+		if p.Value != "" && !ValidValue(keyType, p.Value) {
+			problems = append(problems, SchemaProblem{
+				p.Key, fmt.Sprintf("value %q is not a valid %s", p.Value, TypeName(keyType))})
+			continue
+		}
+		if values, ok := s.Values[p.Key]; ok {
+			elems := []string{p.Value}
+			if isSetType(keyType) {
+				elems = ListFromValue(p.Value)
+			}
+			for _, elem := range elems {
+				if !containsString(values, elem) {
+					problems = append(problems, SchemaProblem{
+						p.Key, fmt.Sprintf("value %q is not one of: %s", elem, strings.Join(values, ", "))})
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// isKnownKey returns true, if the key is mentioned in the schema or if it is a
+// predefined key.
+func (s *Schema) isKnownKey(key string) bool {
+	if KeyType(key) != MetaTypeUnknown || s.Allowed[key] || s.IsRequired(key) {
+		return true
+	}
+	_, hasType := s.Types[key]
+	_, hasValues := s.Values[key]
+	return hasType || hasValues
+}
+
+func isSetType(keyType byte) bool {
+	switch keyType {
+	case MetaTypeZettelIDSet, MetaTypeTagSet, MetaTypeWordSet:
+		return true
+	}
+	return false
+}
+
+func containsString(sl []string, s string) bool {
+	for _, e := range sl {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// similarKey returns a key of the schema or a predefined key, that differs
+// from the given key by at most maxDist edits. If there is none, the empty
+// string is returned.
+func (s *Schema) similarKey(key string, maxDist int) string {
+	candidates := s.Keys()
+	for k := range keyTypeMap {
+		candidates = append(candidates, k)
+	}
+	best, bestDist := "", maxDist+1
+	for _, c := range candidates {
+		if d := editDistance(key, c); d < bestDist || (d == bestDist && c < best) {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance of the two strings, where a
+// transposition of two adjacent characters counts as one edit.
+func editDistance(s, t string) int {
+	a, b := []rune(s), []rune(t)
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func minInt(first int, rest ...int) int {
+	result := first
+	for _, i := range rest {
+		if i < result {
+			result = i
+		}
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package domain provides domain specific types, constants, and functions.
+package domain
+
+import (
+	"testing"
+)
+
+func newTestSchema() *Schema {
+	m := parseMetaStr("role: schema\nschema-role: task\nschema-required: due\n" +
+		"schema-allowed: status effort\nschema-values-status: open closed\nschema-type-effort: number\n")
+	return NewSchema("task", []*Meta{m})
+}
+
+func TestSchemaKeys(t *testing.T) {
+	s := newTestSchema()
+	exp := []string{"due", "effort", "status"}
+	got := s.Keys()
+	if len(got) != len(exp) {
+		t.Fatalf("Expected keys %v, but got %v", exp, got)
+	}
+	for i, key := range exp {
+		if got[i] != key {
+			t.Errorf("Expected keys %v, but got %v", exp, got)
+			break
+		}
+	}
+	if !s.IsRequired("due") || s.IsRequired("status") {
+		t.Error("Wrong required keys")
+	}
+	if kt := s.KeyType("effort"); kt != MetaTypeNumber {
+		t.Errorf("Expected type of effort to be number, but got %q", kt)
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	testcases := []struct {
+		meta string
+		exp  []SchemaProblem
+	}{
+		{"role: task\ndue: 2021-03-04\nstatus: open\neffort: 3\n", nil},
+		{"role: task\nstatus: open\n", []SchemaProblem{{"due", "required key is missing"}}},
+		{"role: task\ndue: 2021\nstatus: pending\n",
+			[]SchemaProblem{{"status", "value \"pending\" is not one of: open, closed"}}},
+		{"role: task\ndue: 2021\neffort: much\n",
+			[]SchemaProblem{{"effort", "value \"much\" is not a valid number"}}},
+		{"role: task\ndue: 2021\ntgas: #a\n",
+			[]SchemaProblem{{"tgas", "key is not allowed, did you mean \"tags\"?"}}},
+		{"role: task\ndue: 2021\ncolor: red\n", []SchemaProblem{{"color", "key is not allowed"}}},
+	}
+	s := newTestSchema()
+	for i, tc := range testcases {
+		got := s.Validate(parseMetaStr(tc.meta))
+		if len(got) != len(tc.exp) {
+			t.Errorf("TC=%d: expected %v, but got %v", i, tc.exp, got)
+			continue
+		}
+		for j, p := range tc.exp {
+			if got[j] != p {
+				t.Errorf("TC=%d: expected %v, but got %v", i, tc.exp, got)
+				break
+			}
+		}
+	}
+}
+
+func TestSchemaOpenValidate(t *testing.T) {
+	s := NewSchema("zettel", []*Meta{parseMetaStr("schema-role: zettel\nschema-required: title\n")})
+	if got := s.Validate(parseMetaStr("title: T\ncolor: red\n")); len(got) != 0 {
+		t.Errorf("Expected no problems, but got %v", got)
+	}
+	got := s.Validate(parseMetaStr("title: T\ntgas: #a\n"))
+	if len(got) != 1 || got[0].Message != "unknown key, did you mean \"tags\"?" {
+		t.Errorf("Expected typo to be found, but got %v", got)
+	}
+}
@@ -0,0 +1,147 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package domain provides domain specific types, constants, and functions.
+package domain
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimestampLayout is the layout of a timestamp value, as used by the time
+// package. A timestamp value may be shorter, as long as it ends after a
+// complete year, month, day, hour, or minute. All timestamp values are in
+// UTC, so that they are independent of the zone of the server.
+const TimestampLayout = "20060102150405"
+
+// NormalizeTimestamp returns the given value in the format of a timestamp
+// value. Besides the layout of a timestamp value, date and time values in
+// ISO 8601 format ("2006-01-02 15:04:05" or "2006-01-02T15:04") are
+// accepted. The bool value signals, whether the value is a valid timestamp.
+func NormalizeTimestamp(value string) (string, bool) {
+	ts := strings.Map(func(r rune) rune {
+		switch r {
+		case '-', ':', 'T', ' ':
+			return -1
+		}
+		return r
+	}, value)
+	if _, ok := TimeValue(ts); !ok {
+		return "", false
+	}
+	return ts, true
+}
+
+// TimeValue returns the value of a timestamp as a time in UTC. Missing parts
+// of the timestamp are assumed to be at their start, e.g. "2021" is the first
+// of January 2021, midnight. The bool value signals, whether the value was a
+// valid timestamp.
+func TimeValue(value string) (time.Time, bool) {
+	switch len(value) {
+	case 4, 6, 8, 10, 12, 14:
+	default:
+		return time.Time{}, false
+	}
+	for _, ch := range value {
+		if ch < '0' || '9' < ch {
+			return time.Time{}, false
+		}
+	}
+	t, err := time.Parse(TimestampLayout[:len(value)], value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// TimestampValue returns the given time as a timestamp value in UTC.
+func TimestampValue(t time.Time) string {
+	return t.UTC().Format(TimestampLayout)
+}
+
+// FormatTimestamp returns the timestamp value in ISO 8601 format, with the
+// same precision as the timestamp value. An invalid value is returned as is.
+func FormatTimestamp(value string) string {
+	t, ok := TimeValue(value)
+	if !ok {
+		return value
+	}
+	switch len(value) {
+	case 4:
+		return t.Format("2006")
+	case 6:
+		return t.Format("2006-01")
+	case 8:
+		return t.Format("2006-01-02")
+	case 10, 12:
+		return t.Format("2006-01-02T15:04")
+	}
+	return t.Format("2006-01-02T15:04:05")
+}
+
+// NumberValue returns the value interpreted as a number. The bool value
+// signals, whether the value was a valid number.
+func NumberValue(value string) (int64, bool) {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// ValidValue returns true, if the given value is valid for a key of the given
+// type.
+func ValidValue(keyType byte, value string) bool {
+	switch keyType {
+	case MetaTypeBool, MetaTypeWordSet:
+		return value != ""
+	case MetaTypeID:
+		_, err := ParseZettelID(value)
+		return err == nil
+	case MetaTypeZettelIDSet:
+		for _, elem := range ListFromValue(value) {
+			if _, err := ParseZettelID(elem); err != nil {
+				return false
+			}
+		}
+		return value != ""
+	case MetaTypeNumber:
+		_, ok := NumberValue(value)
+		return ok
+	case MetaTypeTimestamp:
+		_, ok := NormalizeTimestamp(value)
+		return ok
+	case MetaTypeTagSet:
+		for _, elem := range ListFromValue(value) {
+			if len(elem) < 2 || elem[0] != '#' {
+				return false
+			}
+		}
+		return value != ""
+	case MetaTypeURL:
+		u, err := url.Parse(value)
+		return err == nil && u.String() != ""
+	case MetaTypeWord:
+		return len(ListFromValue(value)) == 1
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package domain provides domain specific types, constants, and functions.
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeTimestamp(t *testing.T) {
+	testcases := []struct {
+		value string
+		exp   string
+		ok    bool
+	}{
+		{"2021", "2021", true},
+		{"202103", "202103", true},
+		{"2021-03-04", "20210304", true},
+		{"2021-03-04 12:34", "202103041234", true},
+		{"2021-03-04T12:34:56", "20210304123456", true},
+		{"", "", false},
+		{"21", "", false},
+		{"20211", "", false},
+		{"202113", "", false},
+		{"2021-02-30", "", false},
+		{"tomorrow", "", false},
+	}
+	for i, tc := range testcases {
+		got, ok := NormalizeTimestamp(tc.value)
+		if ok != tc.ok || got != tc.exp {
+			t.Errorf("TC=%d, value=%q: exp=%q/%v, got=%q/%v", i, tc.value, tc.exp, tc.ok, got, ok)
+		}
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	testcases := []struct {
+		value string
+		exp   string
+	}{
+		{"2021", "2021"},
+		{"202103", "2021-03"},
+		{"20210304", "2021-03-04"},
+		{"2021030412", "2021-03-04T12:00"},
+		{"20210304123456", "2021-03-04T12:34:56"},
+		{"invalid", "invalid"},
+	}
+	for i, tc := range testcases {
+		if got := FormatTimestamp(tc.value); got != tc.exp {
+			t.Errorf("TC=%d, value=%q: exp=%q, got=%q", i, tc.value, tc.exp, got)
+		}
+	}
+}
+
+func TestTimestampValue(t *testing.T) {
+	ts := time.Date(2021, 3, 4, 1, 30, 0, 0, time.FixedZone("CET", 3600))
+	if got, exp := TimestampValue(ts), "20210304003000"; got != exp {
+		t.Errorf("exp=%q, got=%q", exp, got)
+	}
+	if got, ok := TimeValue(TimestampValue(ts)); !ok || !got.Equal(ts) {
+		t.Errorf("Time %v expected, but got %v", ts, got)
+	}
+}
+
+func TestTypedHeader(t *testing.T) {
+	defer SetKeyTypeFunc(nil)
+	SetKeyTypeFunc(func(key string) (byte, bool) {
+		if key == "precursor" {
+			return MetaTypeZettelIDSet, true
+		}
+		return MetaTypeUnknown, false
+	})
+	m := parseMetaStr("created: 2021-03-04 12:34\npriority: 007\ndue: next week\n" +
+		"precursor: 20210304123456 abc 20210304123457\nforward: 20210304123456\n")
+	if got, ok := m.Get(MetaKeyCreated); !ok || got != "202103041234" {
+		t.Errorf("created: expected %q, got %q/%v", "202103041234", got, ok)
+	}
+	if got, ok := m.Get(MetaKeyPriority); !ok || got != "7" {
+		t.Errorf("priority: expected %q, got %q/%v", "7", got, ok)
+	}
+	if got, ok := m.Get(MetaKeyDue); ok {
+		t.Errorf("due: expected no value, got %q", got)
+	}
+	if got, ok := m.Get("precursor"); !ok || got != "20210304123456 20210304123457" {
+		t.Errorf("precursor: expected %q, got %q/%v", "20210304123456 20210304123457", got, ok)
+	}
+	if got, ok := m.Get(MetaKeyForward); ok {
+		t.Errorf("forward: computed value must be ignored, got %q", got)
+	}
+}
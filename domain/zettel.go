@@ -88,20 +88,23 @@ func NewZettelID(withSeconds bool) ZettelID {
 
 // Some important ZettelIDs
 const (
-	ConfigurationID  = ZettelID(1)
-	BaseTemplateID   = ZettelID(10100)
-	LoginTemplateID  = ZettelID(10200)
-	ListTemplateID   = ZettelID(10300)
-	DetailTemplateID = ZettelID(10401)
-	InfoTemplateID   = ZettelID(10402)
-	FormTemplateID   = ZettelID(10403)
-	RenameTemplateID = ZettelID(10404)
-	DeleteTemplateID = ZettelID(10405)
-	RolesTemplateID  = ZettelID(10500)
-	TagsTemplateID   = ZettelID(10600)
-	BaseCSSID        = ZettelID(20001)
-	MaterialIconID   = ZettelID(30001)
-	TemplateZettelID = ZettelID(40001)
+	ConfigurationID   = ZettelID(1)
+	BaseTemplateID    = ZettelID(10100)
+	LoginTemplateID   = ZettelID(10200)
+	ListTemplateID    = ZettelID(10300)
+	DetailTemplateID  = ZettelID(10401)
+	InfoTemplateID    = ZettelID(10402)
+	FormTemplateID    = ZettelID(10403)
+	RenameTemplateID  = ZettelID(10404)
+	DeleteTemplateID  = ZettelID(10405)
+	HistoryTemplateID = ZettelID(10406)
+	RolesTemplateID   = ZettelID(10500)
+	TagsTemplateID    = ZettelID(10600)
+	TrashTemplateID   = ZettelID(10700)
+	JournalTemplateID = ZettelID(10800)
+	BaseCSSID         = ZettelID(20001)
+	MaterialIconID    = ZettelID(30001)
+	TemplateZettelID  = ZettelID(40001)
 )
 
 // Content -------------------------------------------------------------------
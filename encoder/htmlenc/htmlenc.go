@@ -185,6 +185,8 @@ func (v *visitor) acceptMeta(meta *domain.Meta, withTitle bool) {
 				v.b.WriteString("\">")
 			} else if key, ok := mapMetaKey[pair.Key]; ok {
 				v.writeMeta("", key, pair.Value)
+			} else if meta.Type(pair.Key) == domain.MetaTypeTimestamp {
+				v.writeMeta("zs-", pair.Key, domain.FormatTimestamp(pair.Value))
 			} else {
 				v.writeMeta("zs-", pair.Key, pair.Value)
 			}
@@ -583,7 +583,8 @@ func (v *detailVisitor) writeMeta(meta *domain.Meta, withTitle bool) {
 		}
 		v.b.Write(Escape(p.Key))
 		v.b.WriteString("\":")
-		if unicode.IsUpper(rune(meta.Type(p.Key))) {
+		keyType := meta.Type(p.Key)
+		if unicode.IsUpper(rune(keyType)) {
 			v.b.WriteByte('[')
 			for i, val := range domain.ListFromValue(p.Value) {
 				if i > 0 {
@@ -594,6 +595,8 @@ func (v *detailVisitor) writeMeta(meta *domain.Meta, withTitle bool) {
 				v.b.WriteByte('"')
 			}
 			v.b.WriteByte(']')
+		} else if n, ok := domain.NumberValue(p.Value); ok && keyType == domain.MetaTypeNumber {
+			v.b.WriteString(strconv.FormatInt(n, 10))
 		} else {
 			v.b.WriteByte('"')
 			v.b.Write(Escape(p.Value))
@@ -23,6 +23,7 @@ package jsonenc
 import (
 	"bytes"
 	"io"
+	"strconv"
 
 	"zettelstore.de/z/ast"
 	"zettelstore.de/z/domain"
@@ -89,6 +90,11 @@ func writeMeta(b *encoder.BufWriter, meta *domain.Meta) {
 			first = false
 		}
 		b.Write(Escape(p.Key))
+		if n, ok := domain.NumberValue(p.Value); ok && meta.Type(p.Key) == domain.MetaTypeNumber {
+			b.WriteString("\":")
+			b.WriteString(strconv.FormatInt(n, 10))
+			continue
+		}
 		b.WriteString("\":\"")
 		b.Write(Escape(p.Value))
 		b.WriteByte('"')
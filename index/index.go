@@ -0,0 +1,266 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package index allows to search for zettel without reading every zettel from a place.
+package index
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	"zettelstore.de/z/ast"
+	"zettelstore.de/z/collect"
+	"zettelstore.de/z/config"
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/encoder"
+	"zettelstore.de/z/input"
+	"zettelstore.de/z/parser"
+	"zettelstore.de/z/place"
+)
+
+// Port is the interface used by an indexer to read zettel.
+type Port interface {
+	// RegisterChangeObserver registers an observer that will be notified
+	// if all or one zettel are found to be changed.
+	RegisterChangeObserver(ob place.ObserverFunc)
+
+	// GetZettel retrieves a specific zettel.
+	GetZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, error)
+
+	// SelectMeta returns all zettel meta data that match the selection
+	// criteria. The result is ordered by descending zettel id.
+	SelectMeta(ctx context.Context, f *place.Filter, s *place.Sorter) ([]*domain.Meta, error)
+}
+
+// Indexer maintains the indexes of all zettel of a place. It is kept up to
+// date by observing all changes of the place.
+type Indexer struct {
+	port      Port
+	signal    chan struct{}
+	ready     chan struct{} // closed, after the index was built first
+	readyOnce sync.Once
+
+	mxPending sync.Mutex
+	reload    bool
+	pending   map[domain.ZettelID]bool
+
+	mx    sync.RWMutex
+	text  *textIndex
+	links *linkIndex
+	stats map[domain.ZettelID]Stats
+}
+
+// Stats contains statistical data about the content of a zettel.
+type Stats struct {
+	WordCount     int // Number of words of the content
+	ContentLength int // Number of bytes of the content
+}
+
+// NewIndexer creates a new indexer for the given place. The indexes are built
+// in the background, so they may be incomplete for a short time.
+func NewIndexer(port Port) *Indexer {
+	idx := &Indexer{
+		port:    port,
+		signal:  make(chan struct{}, 1),
+		ready:   make(chan struct{}),
+		pending: make(map[domain.ZettelID]bool),
+		text:    newTextIndex(),
+		links:   newLinkIndex(),
+		stats:   make(map[domain.ZettelID]Stats),
+	}
+	port.RegisterChangeObserver(idx.observe)
+	idx.observe(place.ChangeInfo{Reason: place.OnReload, Zid: domain.InvalidZettelID})
+	go idx.worker()
+	return idx
+}
+
+// observe records all changes of the place. They are processed later by the
+// worker, so that the place is not blocked.
+func (idx *Indexer) observe(ci place.ChangeInfo) {
+	idx.mxPending.Lock()
+	if ci.Reason == place.OnReload {
+		idx.reload = true
+		idx.pending = make(map[domain.ZettelID]bool)
+	} else if !idx.reload {
+		idx.pending[ci.Zid] = true
+		if ci.Reason == place.OnRename {
+			idx.pending[ci.NewZid] = true
+		}
+	}
+	idx.mxPending.Unlock()
+	select {
+	case idx.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (idx *Indexer) worker() {
+	for range idx.signal {
+		idx.mxPending.Lock()
+		reload, pending := idx.reload, idx.pending
+		idx.reload = false
+		idx.pending = make(map[domain.ZettelID]bool)
+		idx.mxPending.Unlock()
+
+		ctx := context.Background()
+		if reload {
+			idx.reloadAll(ctx)
+		}
+		for zid := range pending {
+			idx.updateZettel(ctx, zid)
+		}
+		idx.readyOnce.Do(func() { close(idx.ready) })
+	}
+}
+
+// Wait blocks until the indexes of all zettel were built for the first time.
+func (idx *Indexer) Wait() {
+	<-idx.ready
+}
+
+func (idx *Indexer) reloadAll(ctx context.Context) {
+	metaList, err := idx.port.SelectMeta(ctx, nil, nil)
+	if err != nil {
+		log.Println("Unable to build index:", err)
+		return
+	}
+	text := newTextIndex()
+	links := newLinkIndex()
+	stats := make(map[domain.ZettelID]Stats, len(metaList))
+	for _, meta := range metaList {
+		zettel, err := idx.port.GetZettel(ctx, meta.Zid)
+		if err != nil {
+			continue
+		}
+		words, refs, st := collectZettel(zettel)
+		text.add(meta.Zid, words)
+		links.add(meta.Zid, refs)
+		stats[meta.Zid] = st
+	}
+	idx.mx.Lock()
+	idx.text = text
+	idx.links = links
+	idx.stats = stats
+	idx.mx.Unlock()
+}
+
+func (idx *Indexer) updateZettel(ctx context.Context, zid domain.ZettelID) {
+	zettel, err := idx.port.GetZettel(ctx, zid)
+	if err != nil {
+		idx.mx.Lock()
+		idx.text.remove(zid)
+		idx.links.remove(zid)
+		delete(idx.stats, zid)
+		idx.mx.Unlock()
+		return
+	}
+	words, refs, st := collectZettel(zettel)
+	idx.mx.Lock()
+	idx.text.remove(zid)
+	idx.text.add(zid, words)
+	idx.links.remove(zid)
+	idx.links.add(zid, refs)
+	idx.stats[zid] = st
+	idx.mx.Unlock()
+}
+
+// collectZettel returns all words of the title and the content of a zettel,
+// in the order of their occurrence, all zettel referenced by it, and some
+// statistics about its content.
+func collectZettel(zettel domain.Zettel) ([]string, []domain.ZettelID, Stats) {
+	meta := zettel.Meta
+	title, _ := meta.Get(domain.MetaKeyTitle)
+	content := zettel.Content.AsString()
+	z := &ast.Zettel{
+		Zid:   meta.Zid,
+		Title: parser.ParseTitle(title),
+		Ast:   parser.ParseBlocks(input.NewInput(content), meta, config.GetSyntax(meta)),
+	}
+
+	var sbTitle, sbContent strings.Builder
+	if enc := encoder.Create("text"); enc != nil {
+		enc.WriteInlines(&sbTitle, z.Title)
+		enc.WriteBlocks(&sbContent, z.Ast)
+	} else {
+		sbTitle.WriteString(title)
+		sbContent.WriteString(content)
+	}
+	contentWords := tokenize(sbContent.String())
+	stats := Stats{WordCount: len(contentWords), ContentLength: len(content)}
+
+	links, images := collect.References(z)
+	refs := make([]domain.ZettelID, 0, len(links)+len(images))
+	for _, ref := range append(links, images...) {
+		if !ref.IsZettel() {
+			continue
+		}
+		if zid, err := domain.ParseZettelID(ref.Value); err == nil {
+			refs = append(refs, zid)
+		}
+	}
+	words := append(tokenize(sbTitle.String()), contentWords...)
+	return words, sortedRefs(meta.Zid, refs), stats
+}
+
+// SearchText returns all zettel that contain the words of the given query,
+// ranked by their relevance.
+func (idx *Indexer) SearchText(query string) []Hit {
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+	return idx.text.search(query)
+}
+
+// Forward returns all zettel that are referenced by the given zettel.
+func (idx *Indexer) Forward(zid domain.ZettelID) []domain.ZettelID {
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+	return idx.links.references(zid)
+}
+
+// Backward returns all zettel that reference the given zettel.
+func (idx *Indexer) Backward(zid domain.ZettelID) []domain.ZettelID {
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+	return idx.links.referencedBy(zid)
+}
+
+// BrokenLinks returns all zettel that are referenced by the given zettel, but
+// do not exist.
+func (idx *Indexer) BrokenLinks(zid domain.ZettelID) []domain.ZettelID {
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+	var result []domain.ZettelID
+	for _, ref := range idx.links.references(zid) {
+		if _, ok := idx.stats[ref]; !ok {
+			result = append(result, ref)
+		}
+	}
+	return result
+}
+
+// GetStats returns the statistics of the given zettel. If the zettel is not
+// indexed yet, false is returned.
+func (idx *Indexer) GetStats(zid domain.ZettelID) (Stats, bool) {
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+	st, ok := idx.stats[zid]
+	return st, ok
+}
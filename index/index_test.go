@@ -0,0 +1,96 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package index allows to search for zettel without reading every zettel from a place.
+package index
+
+import (
+	"context"
+	"testing"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+
+	_ "zettelstore.de/z/encoder/textenc"
+	_ "zettelstore.de/z/parser/zettelmark"
+)
+
+func TestCollectZettel(t *testing.T) {
+	meta := domain.NewMeta(1)
+	meta.Set(domain.MetaKeyTitle, "Some Title")
+	meta.Set(domain.MetaKeySyntax, "zmk")
+	content := "Link to [[zettel 3|00000000000003]] and [[00000000000002]], again [[00000000000003]]."
+	words, refs, stats := collectZettel(domain.Zettel{Meta: meta, Content: domain.NewContent(content)})
+	if len(words) == 0 || words[0] != "some" || words[1] != "title" {
+		t.Errorf("Title words missing: %v", words)
+	}
+	assertZids(t, "refs", refs, []domain.ZettelID{2, 3})
+	if exp := len(words) - 2; stats.WordCount != exp {
+		t.Errorf("WordCount: exp=%d, got=%d", exp, stats.WordCount)
+	}
+	if stats.ContentLength != len(content) {
+		t.Errorf("ContentLength: exp=%d, got=%d", len(content), stats.ContentLength)
+	}
+}
+
+func TestBrokenLinks(t *testing.T) {
+	idx := &Indexer{
+		text:  newTextIndex(),
+		links: newLinkIndex(),
+		stats: map[domain.ZettelID]Stats{1: {}, 3: {}},
+	}
+	idx.links.add(1, sortedRefs(1, []domain.ZettelID{2, 3, 4}))
+	assertZids(t, "broken 1", idx.BrokenLinks(1), []domain.ZettelID{2, 4})
+	assertZids(t, "broken 3", idx.BrokenLinks(3), nil)
+	if _, ok := idx.GetStats(2); ok {
+		t.Error("Zettel 2 must not have statistics")
+	}
+}
+
+type testPort map[domain.ZettelID]string
+
+func (tp testPort) RegisterChangeObserver(ob place.ObserverFunc) {}
+
+func (tp testPort) GetZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, error) {
+	content, ok := tp[zid]
+	if !ok {
+		return domain.Zettel{}, &place.ErrUnknownID{Zid: zid}
+	}
+	meta := domain.NewMeta(zid)
+	meta.Set(domain.MetaKeySyntax, "zmk")
+	return domain.Zettel{Meta: meta, Content: domain.NewContent(content)}, nil
+}
+
+func (tp testPort) SelectMeta(ctx context.Context, f *place.Filter, s *place.Sorter) ([]*domain.Meta, error) {
+	result := make([]*domain.Meta, 0, len(tp))
+	for zid := range tp {
+		result = append(result, domain.NewMeta(zid))
+	}
+	return result, nil
+}
+
+func TestWait(t *testing.T) {
+	idx := NewIndexer(testPort{
+		1: "Link to [[00000000000002]] and [[00000000000003]].",
+		3: "Link to [[00000000000001]].",
+	})
+	idx.Wait()
+	assertZids(t, "broken 1", idx.BrokenLinks(1), []domain.ZettelID{2})
+	assertZids(t, "backward 1", idx.Backward(1), []domain.ZettelID{3})
+}
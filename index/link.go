@@ -0,0 +1,113 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package index allows to search for zettel without reading every zettel from a place.
+package index
+
+import (
+	"sort"
+
+	"zettelstore.de/z/domain"
+)
+
+// linkIndex stores the references of all zettel to other zettel, in both
+// directions.
+type linkIndex struct {
+	forward  map[domain.ZettelID][]domain.ZettelID
+	backward map[domain.ZettelID]map[domain.ZettelID]bool
+}
+
+func newLinkIndex() *linkIndex {
+	return &linkIndex{
+		forward:  make(map[domain.ZettelID][]domain.ZettelID),
+		backward: make(map[domain.ZettelID]map[domain.ZettelID]bool),
+	}
+}
+
+// add stores the references of the given zettel. It must not be already
+// stored.
+func (li *linkIndex) add(zid domain.ZettelID, refs []domain.ZettelID) {
+	if len(refs) == 0 {
+		return
+	}
+	li.forward[zid] = refs
+	for _, ref := range refs {
+		back, ok := li.backward[ref]
+		if !ok {
+			back = make(map[domain.ZettelID]bool)
+			li.backward[ref] = back
+		}
+		back[zid] = true
+	}
+}
+
+// remove deletes all references of the given zettel.
+func (li *linkIndex) remove(zid domain.ZettelID) {
+	for _, ref := range li.forward[zid] {
+		if back, ok := li.backward[ref]; ok {
+			delete(back, zid)
+			if len(back) == 0 {
+				delete(li.backward, ref)
+			}
+		}
+	}
+	delete(li.forward, zid)
+}
+
+// references returns all zettel referenced by the given zettel, ordered by
+// their zettel identifier.
+func (li *linkIndex) references(zid domain.ZettelID) []domain.ZettelID {
+	refs := li.forward[zid]
+	if len(refs) == 0 {
+		return nil
+	}
+	result := make([]domain.ZettelID, len(refs))
+	copy(result, refs)
+	return result
+}
+
+// referencedBy returns all zettel that reference the given zettel, ordered
+// by their zettel identifier.
+func (li *linkIndex) referencedBy(zid domain.ZettelID) []domain.ZettelID {
+	back := li.backward[zid]
+	if len(back) == 0 {
+		return nil
+	}
+	result := make([]domain.ZettelID, 0, len(back))
+	for ref := range back {
+		result = append(result, ref)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// sortedRefs removes duplicates and the zettel itself from the given
+// references and sorts them.
+func sortedRefs(zid domain.ZettelID, refs []domain.ZettelID) []domain.ZettelID {
+	seen := make(map[domain.ZettelID]bool, len(refs))
+	result := make([]domain.ZettelID, 0, len(refs))
+	for _, ref := range refs {
+		if ref != zid && !seen[ref] {
+			seen[ref] = true
+			result = append(result, ref)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
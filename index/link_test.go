@@ -0,0 +1,59 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package index allows to search for zettel without reading every zettel from a place.
+package index
+
+import (
+	"testing"
+
+	"zettelstore.de/z/domain"
+)
+
+func assertZids(t *testing.T, what string, got, exp []domain.ZettelID) {
+	t.Helper()
+	if len(got) != len(exp) {
+		t.Errorf("%s: exp=%v, got=%v", what, exp, got)
+		return
+	}
+	for i := range got {
+		if got[i] != exp[i] {
+			t.Errorf("%s: exp=%v, got=%v", what, exp, got)
+			return
+		}
+	}
+}
+
+func TestLinkIndex(t *testing.T) {
+	li := newLinkIndex()
+	li.add(1, sortedRefs(1, []domain.ZettelID{3, 2, 1, 3}))
+	li.add(2, sortedRefs(2, []domain.ZettelID{3}))
+	assertZids(t, "forward 1", li.references(1), []domain.ZettelID{2, 3})
+	assertZids(t, "backward 3", li.referencedBy(3), []domain.ZettelID{1, 2})
+	assertZids(t, "backward 2", li.referencedBy(2), []domain.ZettelID{1})
+	assertZids(t, "backward 1", li.referencedBy(1), nil)
+
+	li.remove(1)
+	assertZids(t, "forward 1 after remove", li.references(1), nil)
+	assertZids(t, "backward 3 after remove", li.referencedBy(3), []domain.ZettelID{2})
+	assertZids(t, "backward 2 after remove", li.referencedBy(2), nil)
+	if len(li.backward) != 1 {
+		t.Errorf("Backward index not cleaned up: %v", li.backward)
+	}
+}
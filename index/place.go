@@ -0,0 +1,272 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package index allows to search for zettel without reading every zettel from a place.
+package index
+
+import (
+	"context"
+	"strconv"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+// idxPlace is a place that adds the computed values of the indexer to the
+// meta data of all zettel.
+type idxPlace struct {
+	place place.Place
+	idx   *Indexer
+}
+
+// NewPlace creates a new place that adds the computed values of the given
+// indexer to all zettel of the given place. Computed values of zettel to be
+// stored are ignored.
+func NewPlace(p place.Place, idx *Indexer) place.Place {
+	return &idxPlace{place: p, idx: idx}
+}
+
+func (ip *idxPlace) Next() place.Place { return ip.place.Next() }
+
+func (ip *idxPlace) Location() string {
+	return ip.place.Location()
+}
+
+// Start the place. Now all other functions of the place are allowed.
+// Starting an already started place is not allowed.
+func (ip *idxPlace) Start(ctx context.Context) error {
+	return ip.place.Start(ctx)
+}
+
+// Stop the started place. Now only the Start() function is allowed.
+func (ip *idxPlace) Stop(ctx context.Context) error {
+	return ip.place.Stop(ctx)
+}
+
+// RegisterChangeObserver registers an observer that will be notified
+// if a zettel was found to be changed.
+func (ip *idxPlace) RegisterChangeObserver(f place.ObserverFunc) {
+	ip.place.RegisterChangeObserver(f)
+}
+
+func (ip *idxPlace) CanCreateZettel(ctx context.Context) bool {
+	return ip.place.CanCreateZettel(ctx)
+}
+
+func (ip *idxPlace) CreateZettel(ctx context.Context, zettel domain.Zettel) (domain.ZettelID, error) {
+	return ip.place.CreateZettel(ctx, stripComputed(zettel))
+}
+
+func (ip *idxPlace) GetZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, error) {
+	zettel, err := ip.place.GetZettel(ctx, zid)
+	if err != nil {
+		return domain.Zettel{}, err
+	}
+	zettel.Meta = ip.enrich(zettel.Meta)
+	return zettel, nil
+}
+
+// GetMeta retrieves just the meta data of a specific zettel.
+func (ip *idxPlace) GetMeta(ctx context.Context, zid domain.ZettelID) (*domain.Meta, error) {
+	meta, err := ip.place.GetMeta(ctx, zid)
+	if err != nil {
+		return nil, err
+	}
+	return ip.enrich(meta), nil
+}
+
+// SelectMeta returns all zettel meta data that match the selection
+// criteria. The result is ordered by descending zettel id.
+//
+// Since the filter may refer to computed values, it must be applied after
+// the meta data was enriched.
+func (ip *idxPlace) SelectMeta(ctx context.Context, f *place.Filter, s *place.Sorter) ([]*domain.Meta, error) {
+	metaList, err := ip.place.SelectMeta(ctx, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	filterFunc := place.CreateFilterFunc(f)
+	result := make([]*domain.Meta, 0, len(metaList))
+	for _, meta := range metaList {
+		meta = ip.enrich(meta)
+		if filterFunc(meta) {
+			result = append(result, meta)
+		}
+	}
+	return place.ApplySorter(result, s), nil
+}
+
+func (ip *idxPlace) CanUpdateZettel(ctx context.Context, zettel domain.Zettel) bool {
+	return ip.place.CanUpdateZettel(ctx, zettel)
+}
+
+func (ip *idxPlace) UpdateZettel(ctx context.Context, zettel domain.Zettel) error {
+	return ip.place.UpdateZettel(ctx, stripComputed(zettel))
+}
+
+// GetVersionedZettel retrieves a zettel, together with the version token of
+// its current state.
+func (ip *idxPlace) GetVersionedZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, string, error) {
+	zettel, version, err := place.GetVersionedZettel(ctx, ip.place, zid)
+	if err != nil {
+		return domain.Zettel{}, "", err
+	}
+	zettel.Meta = ip.enrich(zettel.Meta)
+	return zettel, version, nil
+}
+
+// UpdateVersionedZettel updates a zettel, if it was not changed since the
+// given version was retrieved.
+func (ip *idxPlace) UpdateVersionedZettel(ctx context.Context, zettel domain.Zettel, version string) error {
+	return place.UpdateVersionedZettel(ctx, ip.place, stripComputed(zettel), version)
+}
+
+func (ip *idxPlace) CanRenameZettel(ctx context.Context, zid domain.ZettelID) bool {
+	return ip.place.CanRenameZettel(ctx, zid)
+}
+
+// Rename changes the current zid to a new zid.
+func (ip *idxPlace) RenameZettel(ctx context.Context, curZid, newZid domain.ZettelID) error {
+	return ip.place.RenameZettel(ctx, curZid, newZid)
+}
+
+func (ip *idxPlace) CanDeleteZettel(ctx context.Context, zid domain.ZettelID) bool {
+	return ip.place.CanDeleteZettel(ctx, zid)
+}
+
+// DeleteZettel removes the zettel from the place.
+func (ip *idxPlace) DeleteZettel(ctx context.Context, zid domain.ZettelID) error {
+	return ip.place.DeleteZettel(ctx, zid)
+}
+
+// Reload clears all caches, reloads all internal data to reflect changes
+// that were possibly undetected.
+func (ip *idxPlace) Reload(ctx context.Context) error {
+	return ip.place.Reload(ctx)
+}
+
+// HasHistory returns true, if the underlying place stores prior versions.
+func (ip *idxPlace) HasHistory() bool { return place.HasHistory(ip.place) }
+
+// GetHistory returns all stored prior versions of a zettel, newest first.
+func (ip *idxPlace) GetHistory(ctx context.Context, zid domain.ZettelID) ([]place.ZettelVersion, error) {
+	if h, ok := ip.place.(place.Historian); ok {
+		return h.GetHistory(ctx, zid)
+	}
+	return nil, nil
+}
+
+// GetZettelVersion retrieves a prior version of a zettel.
+func (ip *idxPlace) GetZettelVersion(ctx context.Context, zid domain.ZettelID, version string) (domain.Zettel, error) {
+	if h, ok := ip.place.(place.Historian); ok {
+		return h.GetZettelVersion(ctx, zid, version)
+	}
+	return domain.Zettel{}, &place.ErrUnknownID{Zid: zid}
+}
+
+// HasTrash returns true, if the underlying place has a trash.
+func (ip *idxPlace) HasTrash() bool { return place.HasTrash(ip.place) }
+
+// CanTrashZettel returns true, if deleting the zettel moves it into the
+// trash of the underlying place.
+func (ip *idxPlace) CanTrashZettel(ctx context.Context, zid domain.ZettelID) bool {
+	return place.CanTrashZettel(ctx, ip.place, zid)
+}
+
+// GetTrash returns all deleted zettel that are still in the trash, most
+// recently deleted first.
+func (ip *idxPlace) GetTrash(ctx context.Context) ([]place.TrashEntry, error) {
+	if t, ok := ip.place.(place.Trash); ok {
+		return t.GetTrash(ctx)
+	}
+	return nil, nil
+}
+
+// RestoreZettel moves a deleted zettel from the trash back to its original
+// zettel id.
+func (ip *idxPlace) RestoreZettel(ctx context.Context, zid domain.ZettelID) error {
+	if t, ok := ip.place.(place.Trash); ok {
+		return t.RestoreZettel(ctx, zid)
+	}
+	return &place.ErrUnknownID{Zid: zid}
+}
+
+// HasJournal returns true, if the underlying place records all changes.
+func (ip *idxPlace) HasJournal() bool { return place.HasJournal(ip.place) }
+
+// GetJournal returns all recorded changes of the given zettel, newest first.
+// If the zettel id is invalid, the changes of all zettel are returned.
+func (ip *idxPlace) GetJournal(ctx context.Context, zid domain.ZettelID) ([]place.JournalEntry, error) {
+	if j, ok := ip.place.(place.Journal); ok {
+		return j.GetJournal(ctx, zid)
+	}
+	return nil, nil
+}
+
+// enrich returns the meta data together with the computed values.
+func (ip *idxPlace) enrich(meta *domain.Meta) *domain.Meta {
+	forward := ip.idx.Forward(meta.Zid)
+	backward := ip.idx.Backward(meta.Zid)
+	stats, indexed := ip.idx.GetStats(meta.Zid)
+	if !indexed && len(forward) == 0 && len(backward) == 0 {
+		return meta
+	}
+	result := meta.Clone()
+	if len(forward) > 0 {
+		result.SetList(domain.MetaKeyForward, formatZids(forward))
+	}
+	if len(backward) > 0 {
+		result.SetList(domain.MetaKeyBackward, formatZids(backward))
+	}
+	if indexed {
+		if broken := ip.idx.BrokenLinks(meta.Zid); len(broken) > 0 {
+			result.SetList(domain.MetaKeyBrokenLinks, formatZids(broken))
+		}
+		result.Set(domain.MetaKeyForwardCount, strconv.Itoa(len(forward)))
+		result.Set(domain.MetaKeyBackwardCount, strconv.Itoa(len(backward)))
+		result.Set(domain.MetaKeyWordCount, strconv.Itoa(stats.WordCount))
+		result.Set(domain.MetaKeyContentLength, strconv.Itoa(stats.ContentLength))
+	}
+	return result
+}
+
+// stripComputed returns the zettel without any computed values.
+func stripComputed(zettel domain.Zettel) domain.Zettel {
+	var meta *domain.Meta
+	for _, p := range zettel.Meta.Pairs() {
+		if domain.IsComputedKey(p.Key) {
+			if meta == nil {
+				meta = zettel.Meta.Clone()
+			}
+			meta.Delete(p.Key)
+		}
+	}
+	if meta != nil {
+		zettel.Meta = meta
+	}
+	return zettel
+}
+
+func formatZids(zids []domain.ZettelID) []string {
+	result := make([]string, len(zids))
+	for i, zid := range zids {
+		result[i] = zid.Format()
+	}
+	return result
+}
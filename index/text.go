@@ -0,0 +1,245 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package index allows to search for zettel without reading every zettel from a place.
+package index
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"zettelstore.de/z/domain"
+)
+
+// Hit is a zettel found by a full-text search, together with its relevance.
+type Hit struct {
+	Zid   domain.ZettelID
+	Score float64
+}
+
+// Parameters of the BM25 ranking function.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type textDoc struct {
+	length int      // Number of words
+	words  []string // Distinct words
+}
+
+// textIndex is an inverted index that maps a word to the positions of its
+// occurrences within each zettel.
+type textIndex struct {
+	docs     map[domain.ZettelID]textDoc
+	postings map[string]map[domain.ZettelID][]int
+	totalLen int
+}
+
+func newTextIndex() *textIndex {
+	return &textIndex{
+		docs:     make(map[domain.ZettelID]textDoc),
+		postings: make(map[string]map[domain.ZettelID][]int),
+	}
+}
+
+func (ti *textIndex) add(zid domain.ZettelID, words []string) {
+	distinct := make([]string, 0, len(words))
+	for pos, word := range words {
+		posting, ok := ti.postings[word]
+		if !ok {
+			posting = make(map[domain.ZettelID][]int)
+			ti.postings[word] = posting
+		}
+		positions, ok := posting[zid]
+		if !ok {
+			distinct = append(distinct, word)
+		}
+		posting[zid] = append(positions, pos)
+	}
+	ti.docs[zid] = textDoc{length: len(words), words: distinct}
+	ti.totalLen += len(words)
+}
+
+func (ti *textIndex) remove(zid domain.ZettelID) {
+	doc, ok := ti.docs[zid]
+	if !ok {
+		return
+	}
+	for _, word := range doc.words {
+		posting := ti.postings[word]
+		delete(posting, zid)
+		if len(posting) == 0 {
+			delete(ti.postings, word)
+		}
+	}
+	delete(ti.docs, zid)
+	ti.totalLen -= doc.length
+}
+
+// queryTerm is one part of a full-text query: a word, a prefix of a word,
+// or a phrase of several words.
+type queryTerm struct {
+	words  []string
+	prefix bool
+}
+
+// parseTextQuery splits a query into its terms. Words enclosed in double quotes
+// form a phrase, a word ending with "*" matches all words with this prefix.
+func parseTextQuery(query string) []queryTerm {
+	var result []queryTerm
+	for len(query) > 0 {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+		if len(query) == 0 {
+			break
+		}
+		var part string
+		if query[0] == '"' {
+			query = query[1:]
+			if pos := strings.IndexByte(query, '"'); pos >= 0 {
+				part, query = query[:pos], query[pos+1:]
+			} else {
+				part, query = query, ""
+			}
+		} else if pos := strings.IndexFunc(query, unicode.IsSpace); pos >= 0 {
+			part, query = query[:pos], query[pos:]
+		} else {
+			part, query = query, ""
+		}
+		prefix := strings.HasSuffix(part, "*")
+		words := tokenize(part)
+		if len(words) == 0 {
+			continue
+		}
+		result = append(result, queryTerm{words: words, prefix: prefix && len(words) == 1})
+	}
+	return result
+}
+
+// search returns all zettel that match all terms of the query, ordered by
+// descending relevance.
+func (ti *textIndex) search(query string) []Hit {
+	terms := parseTextQuery(query)
+	if len(terms) == 0 || len(ti.docs) == 0 {
+		return nil
+	}
+	var scores map[domain.ZettelID]float64
+	for _, term := range terms {
+		termScores := ti.scoreTerm(term)
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for zid, score := range scores {
+			if termScore, ok := termScores[zid]; ok {
+				scores[zid] = score + termScore
+			} else {
+				delete(scores, zid)
+			}
+		}
+	}
+	result := make([]Hit, 0, len(scores))
+	for zid, score := range scores {
+		result = append(result, Hit{Zid: zid, Score: score})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score == result[j].Score {
+			return result[i].Zid > result[j].Zid
+		}
+		return result[i].Score > result[j].Score
+	})
+	return result
+}
+
+func (ti *textIndex) scoreTerm(term queryTerm) map[domain.ZettelID]float64 {
+	scores := make(map[domain.ZettelID]float64)
+	if len(term.words) > 1 {
+		ti.scoreFrequencies(scores, ti.phraseFrequencies(term.words))
+		return scores
+	}
+	word := term.words[0]
+	if !term.prefix {
+		ti.scoreFrequencies(scores, ti.postings[word])
+		return scores
+	}
+	for w, posting := range ti.postings {
+		if strings.HasPrefix(w, word) {
+			ti.scoreFrequencies(scores, posting)
+		}
+	}
+	return scores
+}
+
+// phraseFrequencies returns the positions of all occurrences of the phrase.
+func (ti *textIndex) phraseFrequencies(words []string) map[domain.ZettelID][]int {
+	result := make(map[domain.ZettelID][]int)
+	first := ti.postings[words[0]]
+	for zid, positions := range first {
+		var found []int
+		for _, pos := range positions {
+			if ti.phraseAt(zid, words[1:], pos+1) {
+				found = append(found, pos)
+			}
+		}
+		if len(found) > 0 {
+			result[zid] = found
+		}
+	}
+	return result
+}
+
+func (ti *textIndex) phraseAt(zid domain.ZettelID, words []string, pos int) bool {
+	for i, word := range words {
+		positions := ti.postings[word][zid]
+		j := sort.SearchInts(positions, pos+i)
+		if j >= len(positions) || positions[j] != pos+i {
+			return false
+		}
+	}
+	return true
+}
+
+// scoreFrequencies adds the BM25 score of a term to the given scores.
+func (ti *textIndex) scoreFrequencies(scores map[domain.ZettelID]float64, posting map[domain.ZettelID][]int) {
+	if len(posting) == 0 {
+		return
+	}
+	n := float64(len(ti.docs))
+	df := float64(len(posting))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+	avgLen := float64(ti.totalLen) / n
+	for zid, positions := range posting {
+		tf := float64(len(positions))
+		docLen := float64(ti.docs[zid].length)
+		scores[zid] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*docLen/avgLen))
+	}
+}
+
+// tokenize splits the text into lower-case words.
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, field := range fields {
+		fields[i] = strings.ToLower(field)
+	}
+	return fields
+}
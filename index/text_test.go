@@ -0,0 +1,118 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package index allows to search for zettel without reading every zettel from a place.
+package index
+
+import (
+	"testing"
+
+	"zettelstore.de/z/domain"
+)
+
+func TestTokenize(t *testing.T) {
+	testcases := []struct {
+		text string
+		exp  []string
+	}{
+		{"", []string{}},
+		{"  ", []string{}},
+		{"Hello, World!", []string{"hello", "world"}},
+		{"zettel-store 2020", []string{"zettel", "store", "2020"}},
+		{"Überprüfung", []string{"überprüfung"}},
+	}
+	for i, tc := range testcases {
+		got := tokenize(tc.text)
+		if len(got) != len(tc.exp) {
+			t.Errorf("TC=%d, text=%q, exp=%v, got=%v", i, tc.text, tc.exp, got)
+			continue
+		}
+		for j := range got {
+			if got[j] != tc.exp[j] {
+				t.Errorf("TC=%d, text=%q, exp=%v, got=%v", i, tc.text, tc.exp, got)
+				break
+			}
+		}
+	}
+}
+
+func newTestTextIndex() *textIndex {
+	ti := newTextIndex()
+	ti.add(1, tokenize("The quick brown fox jumps over the lazy dog"))
+	ti.add(2, tokenize("A quick zettel about foxes and dogs"))
+	ti.add(3, tokenize("Brown bread and brown butter"))
+	ti.add(4, tokenize("Nothing to see here"))
+	return ti
+}
+
+func hitZids(hits []Hit) []domain.ZettelID {
+	result := make([]domain.ZettelID, 0, len(hits))
+	for _, hit := range hits {
+		result = append(result, hit.Zid)
+	}
+	return result
+}
+
+func TestTextSearch(t *testing.T) {
+	ti := newTestTextIndex()
+	testcases := []struct {
+		query string
+		exp   []domain.ZettelID
+	}{
+		{"", []domain.ZettelID{}},
+		{"cat", []domain.ZettelID{}},
+		{"brown", []domain.ZettelID{3, 1}},
+		{"Quick", []domain.ZettelID{2, 1}},
+		{"quick brown", []domain.ZettelID{1}},
+		{"fox*", []domain.ZettelID{2, 1}},
+		{"\"brown fox\"", []domain.ZettelID{1}},
+		{"\"fox brown\"", []domain.ZettelID{}},
+		{"\"brown butter\" bread", []domain.ZettelID{3}},
+	}
+	for i, tc := range testcases {
+		got := hitZids(ti.search(tc.query))
+		if len(got) != len(tc.exp) {
+			t.Errorf("TC=%d, query=%q, exp=%v, got=%v", i, tc.query, tc.exp, got)
+			continue
+		}
+		for j := range got {
+			if got[j] != tc.exp[j] {
+				t.Errorf("TC=%d, query=%q, exp=%v, got=%v", i, tc.query, tc.exp, got)
+				break
+			}
+		}
+	}
+}
+
+func TestTextRemove(t *testing.T) {
+	ti := newTestTextIndex()
+	ti.remove(3)
+	if got := hitZids(ti.search("brown")); len(got) != 1 || got[0] != 1 {
+		t.Errorf("Zettel 3 should be removed, but got %v", got)
+	}
+	if _, ok := ti.postings["bread"]; ok {
+		t.Error("Word \"bread\" should be removed from index")
+	}
+	ti.remove(1)
+	ti.remove(2)
+	ti.remove(4)
+	if len(ti.postings) != 0 || ti.totalLen != 0 {
+		t.Errorf("Index should be empty, but got %v / %d", ti.postings, ti.totalLen)
+	}
+}
@@ -0,0 +1,231 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package journal records all changes of zettel in an append-only file.
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+// Entry is a recorded change of a zettel, together with the zettel data that
+// is needed to replay the change.
+type Entry struct {
+	place.JournalEntry
+	Zettel domain.Zettel // Only for OnCreate and OnUpdate, Meta is nil otherwise
+}
+
+// record is the stored form of an entry. Every record is written as a JSON
+// object on a line of its own.
+type record struct {
+	Time     string            `json:"time"`
+	User     string            `json:"user,omitempty"`
+	Op       string            `json:"op"`
+	Zid      string            `json:"id"`
+	NewZid   string            `json:"newid,omitempty"`
+	Hash     string            `json:"hash,omitempty"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Encoding string            `json:"encoding,omitempty"`
+	Content  string            `json:"content,omitempty"`
+}
+
+// ops maps the textual representation of a change reason to its value.
+var ops = map[string]place.ChangeReason{
+	place.OnCreate.String(): place.OnCreate,
+	place.OnUpdate.String(): place.OnUpdate,
+	place.OnDelete.String(): place.OnDelete,
+	place.OnRename.String(): place.OnRename,
+}
+
+// ContentHash returns the hash value of a content, as it is stored in the
+// journal.
+func ContentHash(content domain.Content) string {
+	sum := sha256.Sum256(content.AsBytes())
+	return hex.EncodeToString(sum[:])
+}
+
+func encodeEntry(entry *Entry) ([]byte, error) {
+	rec := record{
+		Time: entry.Time.UTC().Format(time.RFC3339Nano),
+		Op:   entry.Op.String(),
+		Zid:  entry.Zid.Format(),
+		Hash: entry.Hash,
+	}
+	if entry.User.IsValid() {
+		rec.User = entry.User.Format()
+	}
+	if entry.NewZid.IsValid() {
+		rec.NewZid = entry.NewZid.Format()
+	}
+	if meta := entry.Zettel.Meta; meta != nil {
+		rec.Meta = make(map[string]string)
+		for _, p := range meta.Pairs() {
+			// Credentials must never be readable from the journal.
+			if !domain.IsComputedKey(p.Key) && domain.KeyType(p.Key) != domain.MetaTypeCred {
+				rec.Meta[p.Key] = p.Value
+			}
+		}
+		if content := entry.Zettel.Content.AsString(); utf8.ValidString(content) {
+			rec.Content = content
+		} else {
+			rec.Encoding = "base64"
+			rec.Content = base64.StdEncoding.EncodeToString(entry.Zettel.Content.AsBytes())
+		}
+	}
+	data, err := json.Marshal(&rec)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func decodeEntry(line []byte) (Entry, error) {
+	var rec record
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return Entry{}, err
+	}
+	var entry Entry
+	var err error
+	if entry.Time, err = time.Parse(time.RFC3339Nano, rec.Time); err != nil {
+		return Entry{}, err
+	}
+	var ok bool
+	if entry.Op, ok = ops[rec.Op]; !ok {
+		return Entry{}, fmt.Errorf("unknown operation %q", rec.Op)
+	}
+	if entry.Zid, err = domain.ParseZettelID(rec.Zid); err != nil {
+		return Entry{}, err
+	}
+	if rec.User != "" {
+		if entry.User, err = domain.ParseZettelID(rec.User); err != nil {
+			return Entry{}, err
+		}
+	}
+	if rec.NewZid != "" {
+		if entry.NewZid, err = domain.ParseZettelID(rec.NewZid); err != nil {
+			return Entry{}, err
+		}
+	}
+	entry.Hash = rec.Hash
+	if rec.Meta != nil {
+		meta := domain.NewMeta(entry.Zid)
+		for key, value := range rec.Meta {
+			meta.Set(key, value)
+		}
+		meta.Freeze()
+		content := rec.Content
+		if rec.Encoding == "base64" {
+			data, err := base64.StdEncoding.DecodeString(content)
+			if err != nil {
+				return Entry{}, err
+			}
+			content = string(data)
+		}
+		entry.Zettel = domain.Zettel{Meta: meta, Content: domain.NewContent(content)}
+	}
+	return entry, nil
+}
+
+// ReadFile returns all entries of a journal file, oldest first. An
+// incomplete last entry, caused by a crash while it was written, is ignored.
+func ReadFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readEntries(f, path)
+}
+
+// Filter returns all entries that refer to the given zettel, either as the
+// changed zettel or as the new zettel id of a rename.
+func Filter(entries []Entry, zid domain.ZettelID) []Entry {
+	result := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Zid == zid || entry.NewZid == zid {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+func readEntries(r io.Reader, path string) ([]Entry, error) {
+	var result []Entry
+	rd := bufio.NewReader(r)
+	for lineNo := 1; ; lineNo++ {
+		line, err := rd.ReadBytes('\n')
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		entry, err := decodeEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+		result = append(result, entry)
+	}
+}
+
+// truncateIncomplete removes an incomplete last entry of a journal file.
+func truncateIncomplete(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := fi.Size()
+	if size == 0 {
+		return nil
+	}
+	buf := make([]byte, 4096)
+	for pos := size; pos > 0; {
+		n := int64(len(buf))
+		if n > pos {
+			n = pos
+		}
+		pos -= n
+		if _, err := f.ReadAt(buf[:n], pos); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			if end := pos + int64(i) + 1; end < size {
+				return f.Truncate(end)
+			}
+			return nil
+		}
+	}
+	return f.Truncate(0)
+}
@@ -0,0 +1,261 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package journal records all changes of zettel in an append-only file.
+package journal
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+	_ "zettelstore.de/z/place/memplace"
+)
+
+func startPlace(t *testing.T, ctx context.Context, p place.Place) {
+	t.Helper()
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newZettel(title, content string) domain.Zettel {
+	meta := domain.NewMeta(domain.InvalidZettelID)
+	meta.Set(domain.MetaKeyTitle, title)
+	return domain.Zettel{Meta: meta, Content: domain.NewContent(content)}
+}
+
+func TestJournalReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal")
+
+	ctx := context.Background()
+	mp, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPlace(mp, path)
+	startPlace(t, ctx, p)
+	zid1, err := p.CreateZettel(ctx, newZettel("One", "Content one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zid2, err := p.CreateZettel(ctx, newZettel("Two", "\xff\xfe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zettel := newZettel("One changed", "Changed content")
+	zettel.Meta.Zid = zid1
+	if err := p.UpdateZettel(ctx, zettel); err != nil {
+		t.Fatal(err)
+	}
+	zid3 := zid2 + 1
+	if err := p.RenameZettel(ctx, zid2, zid3); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.DeleteZettel(ctx, zid1); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.DeleteZettel(ctx, zid1); err == nil {
+		t.Error("Deletion of unknown zettel must fail")
+	}
+
+	entries, err := p.(place.Journal).GetJournal(ctx, domain.InvalidZettelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []place.ChangeReason{place.OnDelete, place.OnRename, place.OnUpdate, place.OnCreate, place.OnCreate}
+	if len(entries) != len(exp) {
+		t.Fatalf("%d entries expected, but got %d: %v", len(exp), len(entries), entries)
+	}
+	for i, entry := range entries {
+		if entry.Op != exp[i] {
+			t.Errorf("%d: operation %v expected, but got %v", i, exp[i], entry.Op)
+		}
+	}
+	if entries[2].Hash != ContentHash("Changed content") {
+		t.Errorf("Wrong content hash %q", entries[2].Hash)
+	}
+	if entries, err = p.(place.Journal).GetJournal(ctx, zid3); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 1 || entries[0].Op != place.OnRename {
+		t.Errorf("Only rename of zettel %v expected, but got %v", zid3, entries)
+	}
+	if err := p.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startPlace(t, ctx, target)
+	defer target.Stop(ctx)
+	all, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := Replay(ctx, all, target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := target.GetZettel(ctx, zid1); err == nil {
+		t.Errorf("Zettel %v must be deleted", zid1)
+	}
+	if z, err := target.GetZettel(ctx, zid3); err != nil {
+		t.Error(err)
+	} else if z.Content.AsString() != "\xff\xfe" {
+		t.Errorf("Content of zettel %v not replayed: %q", zid3, z.Content.AsString())
+	}
+}
+
+func TestIncompleteEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal")
+	line := `{"time":"2021-01-01T00:00:00Z","op":"delete","id":"20210101000001"}` + "\n"
+	if err := ioutil.WriteFile(path, []byte(line+`{"time":"2021`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("One entry expected, but got %v", entries)
+	}
+
+	ctx := context.Background()
+	mp, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPlace(mp, path)
+	startPlace(t, ctx, p)
+	p.Stop(ctx)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != line {
+		t.Errorf("Incomplete entry not removed: %q", data)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal")
+
+	ctx := context.Background()
+	mp, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPlace(mp, path)
+	if !place.HasJournal(p) {
+		t.Error("Journal place must have a journal")
+	}
+	startPlace(t, ctx, p)
+	zettel := newZettel("User", "")
+	zettel.Meta.Set(domain.MetaKeyCred, "secret-hash")
+	zid, err := p.CreateZettel(ctx, zettel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("secret-hash")) {
+		t.Errorf("Credential must not be recorded: %q", data)
+	}
+
+	target, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startPlace(t, ctx, target)
+	defer target.Stop(ctx)
+	stored := newZettel("Old user", "")
+	stored.Meta.Zid = zid
+	stored.Meta.Set(domain.MetaKeyCred, "stored-hash")
+	if err := target.UpdateZettel(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Replay(ctx, entries, target); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := target.GetMeta(ctx, zid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := meta.GetDefault(domain.MetaKeyTitle, ""); got != "User" {
+		t.Errorf("Title %q expected, but got %q", "User", got)
+	}
+	if got := meta.GetDefault(domain.MetaKeyCred, ""); got != "stored-hash" {
+		t.Errorf("Stored credential expected, but got %q", got)
+	}
+}
+
+func TestRecordFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	mp, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPlace(mp, filepath.Join(dir, "journal"))
+	startPlace(t, ctx, p)
+	defer p.Stop(ctx)
+
+	// Writing to the closed file fails.
+	p.(*jPlace).file.Close()
+	if _, err := p.CreateZettel(ctx, newZettel("One", "")); err != nil {
+		t.Errorf("Change must not fail, if it cannot be recorded: %v", err)
+	}
+}
@@ -0,0 +1,322 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package journal records all changes of zettel in an append-only file.
+package journal
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+// jPlace is a place that appends every successful change of a zettel to a
+// journal file. The journal is not a write-ahead log: an entry is appended
+// after the change was done, because only then the zettel id of a created
+// zettel is known and failed changes are not recorded. A change that cannot
+// be appended is logged, but the change itself is not undone.
+type jPlace struct {
+	place place.Place
+	path  string
+	mx    sync.Mutex
+	file  *os.File
+}
+
+// NewPlace creates a new place that records all changes of the given place
+// in the journal file with the given path. Since the journal contains the
+// full zettel data, it is created readable by the owner only.
+func NewPlace(p place.Place, path string) place.Place {
+	return &jPlace{place: p, path: path}
+}
+
+func (jp *jPlace) Next() place.Place { return jp.place.Next() }
+
+func (jp *jPlace) Location() string {
+	return jp.place.Location()
+}
+
+// Start the place. Now all other functions of the place are allowed.
+// Starting an already started place is not allowed.
+func (jp *jPlace) Start(ctx context.Context) error {
+	f, err := os.OpenFile(jp.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if err := truncateIncomplete(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := jp.place.Start(ctx); err != nil {
+		f.Close()
+		return err
+	}
+	jp.mx.Lock()
+	jp.file = f
+	jp.mx.Unlock()
+	return nil
+}
+
+// Stop the started place. Now only the Start() function is allowed.
+func (jp *jPlace) Stop(ctx context.Context) error {
+	err := jp.place.Stop(ctx)
+	jp.mx.Lock()
+	defer jp.mx.Unlock()
+	if jp.file != nil {
+		if err1 := jp.file.Close(); err == nil {
+			err = err1
+		}
+		jp.file = nil
+	}
+	return err
+}
+
+// RegisterChangeObserver registers an observer that will be notified
+// if a zettel was found to be changed.
+func (jp *jPlace) RegisterChangeObserver(f place.ObserverFunc) {
+	jp.place.RegisterChangeObserver(f)
+}
+
+func (jp *jPlace) CanCreateZettel(ctx context.Context) bool {
+	return jp.place.CanCreateZettel(ctx)
+}
+
+func (jp *jPlace) CreateZettel(ctx context.Context, zettel domain.Zettel) (domain.ZettelID, error) {
+	zid, err := jp.place.CreateZettel(ctx, zettel)
+	if err != nil {
+		return zid, err
+	}
+	meta := zettel.Meta.Clone()
+	meta.Zid = zid
+	zettel.Meta = meta
+	jp.record(ctx, place.OnCreate, zid, domain.InvalidZettelID, zettel)
+	return zid, nil
+}
+
+func (jp *jPlace) GetZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, error) {
+	return jp.place.GetZettel(ctx, zid)
+}
+
+// GetMeta retrieves just the meta data of a specific zettel.
+func (jp *jPlace) GetMeta(ctx context.Context, zid domain.ZettelID) (*domain.Meta, error) {
+	return jp.place.GetMeta(ctx, zid)
+}
+
+// SelectMeta returns all zettel meta data that match the selection
+// criteria. The result is ordered by descending zettel id.
+func (jp *jPlace) SelectMeta(ctx context.Context, f *place.Filter, s *place.Sorter) ([]*domain.Meta, error) {
+	return jp.place.SelectMeta(ctx, f, s)
+}
+
+func (jp *jPlace) CanUpdateZettel(ctx context.Context, zettel domain.Zettel) bool {
+	return jp.place.CanUpdateZettel(ctx, zettel)
+}
+
+func (jp *jPlace) UpdateZettel(ctx context.Context, zettel domain.Zettel) error {
+	if err := jp.place.UpdateZettel(ctx, zettel); err != nil {
+		return err
+	}
+	jp.record(ctx, place.OnUpdate, zettel.Meta.Zid, domain.InvalidZettelID, zettel)
+	return nil
+}
+
+// GetVersionedZettel retrieves a zettel, together with the version token of
+// its current state.
+func (jp *jPlace) GetVersionedZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, string, error) {
+	return place.GetVersionedZettel(ctx, jp.place, zid)
+}
+
+// UpdateVersionedZettel updates a zettel, if it was not changed since the
+// given version was retrieved.
+func (jp *jPlace) UpdateVersionedZettel(ctx context.Context, zettel domain.Zettel, version string) error {
+	if err := place.UpdateVersionedZettel(ctx, jp.place, zettel, version); err != nil {
+		return err
+	}
+	jp.record(ctx, place.OnUpdate, zettel.Meta.Zid, domain.InvalidZettelID, zettel)
+	return nil
+}
+
+func (jp *jPlace) CanRenameZettel(ctx context.Context, zid domain.ZettelID) bool {
+	return jp.place.CanRenameZettel(ctx, zid)
+}
+
+// Rename changes the current zid to a new zid.
+func (jp *jPlace) RenameZettel(ctx context.Context, curZid, newZid domain.ZettelID) error {
+	if err := jp.place.RenameZettel(ctx, curZid, newZid); err != nil {
+		return err
+	}
+	jp.record(ctx, place.OnRename, curZid, newZid, domain.Zettel{})
+	return nil
+}
+
+func (jp *jPlace) CanDeleteZettel(ctx context.Context, zid domain.ZettelID) bool {
+	return jp.place.CanDeleteZettel(ctx, zid)
+}
+
+// DeleteZettel removes the zettel from the place.
+func (jp *jPlace) DeleteZettel(ctx context.Context, zid domain.ZettelID) error {
+	if err := jp.place.DeleteZettel(ctx, zid); err != nil {
+		return err
+	}
+	jp.record(ctx, place.OnDelete, zid, domain.InvalidZettelID, domain.Zettel{})
+	return nil
+}
+
+// Reload clears all caches, reloads all internal data to reflect changes
+// that were possibly undetected.
+func (jp *jPlace) Reload(ctx context.Context) error {
+	return jp.place.Reload(ctx)
+}
+
+// HasHistory returns true, if the underlying place stores prior versions.
+func (jp *jPlace) HasHistory() bool { return place.HasHistory(jp.place) }
+
+// GetHistory returns all stored prior versions of a zettel, newest first.
+func (jp *jPlace) GetHistory(ctx context.Context, zid domain.ZettelID) ([]place.ZettelVersion, error) {
+	if h, ok := jp.place.(place.Historian); ok {
+		return h.GetHistory(ctx, zid)
+	}
+	return nil, nil
+}
+
+// GetZettelVersion retrieves a prior version of a zettel.
+func (jp *jPlace) GetZettelVersion(ctx context.Context, zid domain.ZettelID, version string) (domain.Zettel, error) {
+	if h, ok := jp.place.(place.Historian); ok {
+		return h.GetZettelVersion(ctx, zid, version)
+	}
+	return domain.Zettel{}, &place.ErrUnknownID{Zid: zid}
+}
+
+// HasTrash returns true, if the underlying place has a trash.
+func (jp *jPlace) HasTrash() bool { return place.HasTrash(jp.place) }
+
+// CanTrashZettel returns true, if deleting the zettel moves it into the
+// trash of the underlying place.
+func (jp *jPlace) CanTrashZettel(ctx context.Context, zid domain.ZettelID) bool {
+	return place.CanTrashZettel(ctx, jp.place, zid)
+}
+
+// GetTrash returns all deleted zettel that are still in the trash, most
+// recently deleted first.
+func (jp *jPlace) GetTrash(ctx context.Context) ([]place.TrashEntry, error) {
+	if t, ok := jp.place.(place.Trash); ok {
+		return t.GetTrash(ctx)
+	}
+	return nil, nil
+}
+
+// RestoreZettel moves a deleted zettel from the trash back to its original
+// zettel id. It is recorded as the creation of that zettel.
+func (jp *jPlace) RestoreZettel(ctx context.Context, zid domain.ZettelID) error {
+	t, ok := jp.place.(place.Trash)
+	if !ok {
+		return &place.ErrUnknownID{Zid: zid}
+	}
+	if err := t.RestoreZettel(ctx, zid); err != nil {
+		return err
+	}
+	zettel, err := jp.place.GetZettel(ctx, zid)
+	if err != nil {
+		return err
+	}
+	jp.record(ctx, place.OnCreate, zid, domain.InvalidZettelID, zettel)
+	return nil
+}
+
+// Check returns all problems found in the place. Changes made to fix them
+// are not recorded.
+func (jp *jPlace) Check(ctx context.Context, fix bool) ([]place.Problem, error) {
+	if c, ok := jp.place.(place.Checker); ok {
+		return c.Check(ctx, fix)
+	}
+	return nil, nil
+}
+
+// HasJournal returns true, because all changes are recorded.
+func (jp *jPlace) HasJournal() bool { return true }
+
+// GetJournal returns all recorded changes of the given zettel, newest first.
+// If the zettel id is invalid, the changes of all zettel are returned.
+func (jp *jPlace) GetJournal(ctx context.Context, zid domain.ZettelID) ([]place.JournalEntry, error) {
+	jp.mx.Lock()
+	if jp.file == nil {
+		jp.mx.Unlock()
+		return nil, place.ErrStopped
+	}
+	entries, err := ReadFile(jp.path)
+	jp.mx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if zid.IsValid() {
+		entries = Filter(entries, zid)
+	}
+	result := make([]place.JournalEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		result = append(result, entries[i].JournalEntry)
+	}
+	return result, nil
+}
+
+// record appends an entry for a successful change to the journal file. The
+// file is synced, before the change is reported to the caller. Since the
+// change is already done, an error is only logged.
+func (jp *jPlace) record(
+	ctx context.Context, op place.ChangeReason, zid, newZid domain.ZettelID, zettel domain.Zettel) {
+	if err := jp.append(ctx, op, zid, newZid, zettel); err != nil {
+		log.Printf("Unable to record %v of zettel %v in journal: %v", op, zid.Format(), err)
+	}
+}
+
+func (jp *jPlace) append(
+	ctx context.Context, op place.ChangeReason, zid, newZid domain.ZettelID, zettel domain.Zettel) error {
+	entry := Entry{
+		JournalEntry: place.JournalEntry{
+			Time:   time.Now(),
+			User:   domain.InvalidZettelID,
+			Op:     op,
+			Zid:    zid,
+			NewZid: newZid,
+		},
+		Zettel: zettel,
+	}
+	if user := place.GetUser(ctx); user != nil {
+		entry.User = user.Zid
+	}
+	if zettel.Meta != nil {
+		entry.Hash = ContentHash(zettel.Content)
+	}
+	data, err := encodeEntry(&entry)
+	if err != nil {
+		return err
+	}
+	jp.mx.Lock()
+	defer jp.mx.Unlock()
+	if jp.file == nil {
+		return place.ErrStopped
+	}
+	if _, err := jp.file.Write(data); err != nil {
+		return err
+	}
+	return jp.file.Sync()
+}
@@ -0,0 +1,127 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package journal records all changes of zettel in an append-only file.
+package journal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+// Replay applies the changes of the given entries to a place, in the order
+// of the entries. Created zettel keep their zettel id. Changes of zettel
+// that are already deleted or renamed in the place are ignored, and a rename
+// replaces an already existing zettel. Therefore, a journal can be replayed
+// into a place that already contains some of the changes. Credentials are not
+// recorded, the credentials of existing zettel are retained.
+func Replay(ctx context.Context, entries []Entry, p place.Place) error {
+	for _, entry := range entries {
+		var err error
+		switch entry.Op {
+		case place.OnCreate, place.OnUpdate:
+			if entry.Zettel.Meta == nil {
+				err = fmt.Errorf("zettel data missing")
+			} else if hash := ContentHash(entry.Zettel.Content); hash != entry.Hash {
+				err = fmt.Errorf("content hash %v expected, but got %v", entry.Hash, hash)
+			} else {
+				err = p.UpdateZettel(ctx, keepCredentials(ctx, p, entry.Zettel))
+			}
+		case place.OnDelete:
+			if err = checkExists(ctx, p, entry.Zid); err == nil {
+				err = p.DeleteZettel(ctx, entry.Zid)
+			}
+		case place.OnRename:
+			if err = checkExists(ctx, p, entry.Zid); err == nil {
+				err = replayRename(ctx, p, entry.Zid, entry.NewZid)
+			}
+		}
+		if err == errSkip {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to replay %v of zettel %v from %v: %v",
+				entry.Op, entry.Zid.Format(), entry.Time.Format("2006-01-02 15:04:05"), err)
+		}
+	}
+	return nil
+}
+
+// errSkip signals that a change must not be applied.
+var errSkip = errors.New("skip")
+
+// checkExists returns errSkip, if the zettel is not stored in the place.
+func checkExists(ctx context.Context, p place.Place, zid domain.ZettelID) error {
+	_, err := p.GetMeta(ctx, zid)
+	if _, ok := err.(*place.ErrUnknownID); ok {
+		return errSkip
+	}
+	return err
+}
+
+// replayRename renames a zettel. If there is already a zettel with the new
+// zettel id, it is replaced.
+func replayRename(ctx context.Context, p place.Place, curZid, newZid domain.ZettelID) error {
+	if err := checkExists(ctx, p, newZid); err == errSkip {
+		return p.RenameZettel(ctx, curZid, newZid)
+	} else if err != nil {
+		return err
+	}
+	zettel, err := p.GetZettel(ctx, curZid)
+	if err != nil {
+		return err
+	}
+	meta := zettel.Meta.Clone()
+	meta.Zid = newZid
+	zettel.Meta = meta
+	if err := p.UpdateZettel(ctx, zettel); err != nil {
+		return err
+	}
+	return p.DeleteZettel(ctx, curZid)
+}
+
+// keepCredentials returns the zettel with all credentials of the zettel that
+// is already stored in the place.
+func keepCredentials(ctx context.Context, p place.Place, zettel domain.Zettel) domain.Zettel {
+	stored, err := p.GetMeta(ctx, zettel.Meta.Zid)
+	if err != nil {
+		return zettel
+	}
+	var meta *domain.Meta
+	for _, pair := range stored.Pairs() {
+		if domain.KeyType(pair.Key) != domain.MetaTypeCred {
+			continue
+		}
+		if _, ok := zettel.Meta.Get(pair.Key); ok {
+			continue
+		}
+		if meta == nil {
+			meta = zettel.Meta.Clone()
+		}
+		meta.Set(pair.Key, pair.Value)
+	}
+	if meta != nil {
+		zettel.Meta = meta
+	}
+	return zettel
+}
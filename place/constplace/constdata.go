@@ -71,6 +71,9 @@ var constZettelMap = map[domain.ZettelID]constZettel{
 <a href="{{urlList 'h'}}">List Zettel</a>
 <a href="{{urlList 'r'}}?_format=html">List Roles</a>
 <a href="{{urlList 't'}}?_format=html">List Tags</a>
+{{- if HasTrash}}
+<a href="{{urlList 'd'}}">Trash</a>
+{{- end}}
 </nav>
 </div>
 {{- if CanCreate .User }}
@@ -88,6 +91,9 @@ var constZettelMap = map[domain.ZettelID]constZettel{
 {{- end}}
 {{- if CanReload .User}}
 <a href="{{urlList 'c'}}?_format=html">Reload</a>
+{{- if HasJournal}}
+<a href="{{urlList 'j'}}">Journal</a>
+{{- end}}
 {{- end}}
 </nav>
 </div>
@@ -148,6 +154,12 @@ var constZettelMap = map[domain.ZettelID]constZettel{
 		domain.NewContent(
 			`{{define "content"}}
 <h1>{{.Title}}</h1>
+{{- with .Error}}
+<div class="zs-indication zs-error">{{.}}</div>
+{{- end}}
+{{- with .SortLinks}}
+<p class="zs-meta">Sort:{{range $i, $l := .}}{{if $i}} &#183;{{end}} {{if $l.Active}}{{$l.Text}}{{else}}<a href="{{$l.URL}}">{{$l.Text}}</a>{{end}}{{end}}</p>
+{{- end}}
 <ul>
 {{range .Metas}}<li><a href="{{urlZettel 'h' .Meta.Zid}}">{{.Title}}</a><span class="zs-meta">{{range .Meta.GetTags}} <a href="{{urlList 'h'}}?tags={{.}}">{{.}}</a>{{end}}</span></li>{{end}}
 </ul>
@@ -202,7 +214,15 @@ var constZettelMap = map[domain.ZettelID]constZettel{
 {{ if CanCreate .User}} &#183; <a href="{{urlZettel 'n' .Meta.Zid}}">Clone</a>{{ end}}
 {{ if CanRename .User .Meta}}&#183; <a href="{{urlZettel 'r' .Meta.Zid}}">Rename</a>{{end}}
 {{ if CanDelete .User .Meta}}&#183; <a href="{{urlZettel 'd' .Meta.Zid}}">Delete</a>{{end}}
+{{ if HasHistory}}&#183; <a href="{{urlZettel 'v' .Meta.Zid}}">History</a>{{end}}
 </header>
+{{- if or .Created .Modified}}
+<p class="zs-meta">
+{{- with .Created}}Created {{.}}{{end}}
+{{- with .Modified}}{{if $.Created}} &#183; {{end}}Modified {{.}}{{end}}
+{{- with .Editor}} by {{if .Found}}<a href="{{urlZettel 'h' .Zid}}">{{.Title}}</a>{{else}}{{.Zid.Format}}{{end}}{{end}}
+</p>
+{{- end}}
 <h2>Interpreted Meta Data</h2>
 <table>
 {{- range .Meta.Pairs}}
@@ -224,6 +244,12 @@ var constZettelMap = map[domain.ZettelID]constZettel{
 </ul>
 {{end}}
 {{end}}
+{{if .BackLinks}}
+<h2>Incoming Links</h2>
+<ul>
+{{range .BackLinks}}<li><a href="{{urlZettel 'h' .Zid}}">{{.Title}}</a></li>{{end}}
+</ul>
+{{end}}
 <h2>Parts and format</h3>
 <table>
 {{range $p := .Parts}}
@@ -246,7 +272,13 @@ var constZettelMap = map[domain.ZettelID]constZettel{
 <header>
 <h1>{{.Title}}</h1>
 </header>
+{{- if .Conflict}}
+<div class="zs-indication zs-error">This zettel was changed in the meantime. Please merge your changes with the current version shown below, then submit again.</div>
+{{- end}}
 <form method="POST">
+{{- with .Version}}
+<input type="hidden" name="version" value="{{.}}">
+{{- end}}
 <div>
 <label for="title">Title</label>
 <input class="zs-input" type="text" id="title" name="title" placeholder="Title.." value="{{.Meta.GetTitle ""}}">
@@ -265,11 +297,24 @@ var constZettelMap = map[domain.ZettelID]constZettel{
 </div>
 <div>
 <label for="meta">Meta</label>
+{{- with .Problems}}
+<ul class="zs-indication zs-error">
+{{- range .}}
+<li><code>{{.Key}}</code>: {{.Message}}</li>
+{{- end}}
+</ul>
+{{- end}}
 <textarea class="zs-input" id="meta" name="meta" rows="4" placeholder="key: value">
 {{- range .Meta.PairsRest}}
 {{.Key}}: {{.Value}}
 {{- end -}}
 </textarea>
+{{- with .Suggestions}}
+<p class="zs-meta">Suggested:
+{{- range $i, $s := .}}{{if $i}},{{end}} <code>{{$s.Key}}</code>
+{{- if or $s.Required $s.Type $s.Values}} ({{if $s.Required}}required{{if or $s.Type $s.Values}}, {{end}}{{end}}{{$s.Type}}{{if and $s.Type $s.Values}}: {{end}}{{join $s.Values}}){{end}}
+{{- end}}</p>
+{{- end}}
 </div>
 <div>
 <label for="content">Content</label>
@@ -279,6 +324,23 @@ var constZettelMap = map[domain.ZettelID]constZettel{
 </div>
 <input class="zs-button" type="submit" value="Submit">
 </form>
+{{- if .Conflict}}
+<h2>Current Version</h2>
+<div>
+<label for="current-meta">Meta</label>
+<textarea class="zs-input" id="current-meta" rows="4" readonly>
+{{- range .CurrentMeta.Pairs}}
+{{.Key}}: {{.Value}}
+{{- end -}}
+</textarea>
+</div>
+<div>
+<label for="current-content">Content</label>
+<textarea class="zs-input zs-content" id="current-content" rows="20" readonly>
+{{- .CurrentContent -}}
+</textarea>
+</div>
+{{- end}}
 </article>
 {{end}}`,
 	},
@@ -295,20 +357,45 @@ var constZettelMap = map[domain.ZettelID]constZettel{
 <header>
 <h1>Rename Zettel {{.Meta.Zid.Format}}</h1>
 </header>
+{{- if .Renamed}}
+<p>The zettel was renamed to <a href="{{urlZettel 'h' .NewZid}}">{{.NewZid.Format}}</a>.</p>
+{{- else}}
 <p>Do you really want to rename this zettel?</p>
 <form method="POST">
 <div>
 <label for="newid">New zettel id</label>
-<input class="zs-input" type="text" id="newzid" name="newzid" placeholder="ZID.." value="{{.Meta.Zid.Format}}">
+<input class="zs-input" type="text" id="newzid" name="newzid" placeholder="ZID.." value="{{.NewZid.Format}}">
 </div>
 <input type="hidden" id="curzid" name="curzid" value="{{.Meta.Zid.Format}}">
+<div>
+<input type="checkbox" id="dryrun" name="dryrun" value="true">
+<label for="dryrun">Only show the zettel that would be changed</label>
+</div>
 <input class="zs-button" type="submit" value="Rename">
 </form>
+{{- end}}
+{{- if .Rewritten}}
+<h2>{{if .Renamed}}Updated References{{else}}References to Update{{end}}</h2>
+<ul>
+{{range .Rewritten}}<li><a href="{{urlZettel 'h' .Meta.Zid}}">{{.Title}}</a></li>{{end}}
+</ul>
+{{- end}}
+{{- if .Failed}}
+<h2>{{if .Renamed}}References Not Updated{{else}}References That Cannot Be Updated{{end}}</h2>
+<ul>
+{{range .Failed}}<li><a href="{{urlZettel 'h' .Meta.Zid}}">{{.Title}}</a></li>{{end}}
+</ul>
+{{- end}}
+{{- if .Skipped}}
+<p>{{.Skipped}} referencing zettel {{if .Renamed}}were{{else}}will be{{end}} skipped, because they could not be read.</p>
+{{- end}}
+{{- if not .Renamed}}
 <dl>
 {{- range .Meta.Pairs}}
 <dt>{{.Key}}:</dt><dd>{{.Value}}</dd>
 {{- end -}}
 </dl>
+{{- end}}
 </article>
 {{end}}`,
 	},
@@ -338,6 +425,66 @@ var constZettelMap = map[domain.ZettelID]constZettel{
 {{end}}`,
 	},
 
+	domain.HistoryTemplateID: constZettel{
+		constHeader{
+			domain.MetaKeyTitle:      "History HTML Template",
+			domain.MetaKeySyntax:     syntaxTemplate,
+			domain.MetaKeyRole:       roleConfiguration,
+			domain.MetaKeyVisibility: domain.MetaValueVisibilityOwner,
+		},
+		`{{define "content"}}
+<article>
+<header>
+<h1>History of Zettel {{.Meta.Zid.Format}}</h1>
+<a href="{{urlZettel 'h' .Meta.Zid}}">Web</a>
+&#183; <a href="{{urlZettel 'i' .Meta.Zid}}">Info</a>
+</header>
+{{- if .Versions}}
+<form method="GET">
+<label for="from">Compare</label>
+<select id="from" name="from">
+<option value=""{{if eq $.From ""}} selected{{end}}>Current version</option>
+{{- range .Versions}}
+<option value="{{.Version}}"{{if eq $.From .Version}} selected{{end}}>{{.Time}}</option>
+{{- end}}
+</select>
+<label for="to">with</label>
+<select id="to" name="to">
+<option value=""{{if eq $.To ""}} selected{{end}}>Current version</option>
+{{- range .Versions}}
+<option value="{{.Version}}"{{if eq $.To .Version}} selected{{end}}>{{.Time}}</option>
+{{- end}}
+</select>
+<input class="zs-button" type="submit" value="Compare">
+</form>
+{{- if .Diff}}
+<pre class="zs-diff">{{range .Diff}}<span class="zs-diff-{{.Kind}}">{{.Prefix}} {{.Text}}</span>
+{{end}}</pre>
+{{- end}}
+<table>
+<tr><th>Replaced</th><th>Title</th><th></th><th></th></tr>
+{{- range .Versions}}
+<tr>
+<td>{{.Time}}</td>
+<td>{{.Title}}</td>
+<td><a href="{{urlZettel 'v' $.Meta.Zid}}?from={{.Version}}">Compare with current</a></td>
+<td>{{- if CanWrite $.User $.Meta}}
+<form method="POST">
+<input type="hidden" name="restore" value="{{.Version}}">
+<input type="hidden" name="version" value="{{$.Token}}">
+<input class="zs-button" type="submit" value="Restore">
+</form>
+{{- end}}</td>
+</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>There are no prior versions of this zettel.</p>
+{{- end}}
+</article>
+{{end}}`,
+	},
+
 	domain.RolesTemplateID: constZettel{
 		constHeader{
 			domain.MetaKeyTitle:      "List Roles HTML Template",
@@ -369,6 +516,67 @@ var constZettelMap = map[domain.ZettelID]constZettel{
 {{end}}`,
 	},
 
+	domain.TrashTemplateID: constZettel{
+		constHeader{
+			domain.MetaKeyTitle:      "Trash HTML Template",
+			domain.MetaKeySyntax:     syntaxTemplate,
+			domain.MetaKeyRole:       roleConfiguration,
+			domain.MetaKeyVisibility: domain.MetaValueVisibilityOwner,
+		},
+		`{{define "content"}}
+<h1>Deleted Zettel</h1>
+{{- if .Trash}}
+<table>
+<tr><th>Zettel</th><th>Deleted</th><th>Deleted by</th><th></th></tr>
+{{- range .Trash}}
+<tr>
+<td>{{.Title}} <span class="zs-meta">{{.Meta.Zid.Format}}</span></td>
+<td>{{.Deleted}}</td>
+<td>{{.DeletedBy}}</td>
+<td>{{- if CanCreate $.User}}
+<form method="POST">
+<input type="hidden" name="zid" value="{{.Meta.Zid.Format}}">
+<input class="zs-button" type="submit" value="Restore">
+</form>
+{{- end}}</td>
+</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>The trash is empty.</p>
+{{- end}}
+{{end}}`,
+	},
+
+	domain.JournalTemplateID: constZettel{
+		constHeader{
+			domain.MetaKeyTitle:      "Journal HTML Template",
+			domain.MetaKeySyntax:     syntaxTemplate,
+			domain.MetaKeyRole:       roleConfiguration,
+			domain.MetaKeyVisibility: domain.MetaValueVisibilityOwner,
+		},
+		`{{define "content"}}
+<h1>{{.Title}}</h1>
+{{- if .Journal}}
+<table>
+<tr><th>Time</th><th>Change</th><th>Zettel</th><th>User</th><th>Content hash</th></tr>
+{{- range .Journal}}
+<tr>
+<td>{{.Time}}</td>
+<td>{{.Op}}</td>
+<td><a href="{{urlList 'j'}}/{{.Zid}}">{{.Zid}}</a>
+{{- if .NewZid}} &rarr; <a href="{{urlList 'j'}}/{{.NewZid}}">{{.NewZid}}</a>{{end}}</td>
+<td>{{if .User}}<a href="{{urlList 'h'}}/{{.User}}">{{.User}}</a>{{end}}</td>
+<td>{{if .Hash}}<code>{{.Hash}}</code>{{end}}</td>
+</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No changes were recorded.</p>
+{{- end}}
+{{end}}`,
+	},
+
 	domain.BaseCSSID: constZettel{
 		constHeader{
 			domain.MetaKeyTitle:      "Base CSS",
@@ -627,6 +835,12 @@ kbd {
   padding: .1rem .2rem;
   font-size: 75%;
 }
+.zs-diff-insert {
+  background-color: #e6ffec;
+}
+.zs-diff-delete {
+  background-color: #ffebe9;
+}
 .zs-meta {
   font-size:.75rem;
   color:#888;
@@ -0,0 +1,228 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package dirplace provides a directory-based zettel place.
+package dirplace
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"zettelstore.de/z/config"
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place/dirplace/directory"
+)
+
+// metaCacheName is the name of the file, relative to the place directory,
+// where the parsed meta data of all zettel is stored between restarts.
+const metaCacheName = ".metacache"
+
+// diskCache stores the meta data of zettel files persistently. Each record is
+// only valid as long as the size and the modification time of all files of
+// the zettel are unchanged. All records are invalid, if the key types of the
+// configuration changed, because the meta data was parsed with other types.
+type diskCache struct {
+	path       string
+	mx         sync.Mutex
+	generation string
+	records    map[domain.ZettelID]*cacheRecord
+	dirty      bool
+}
+
+// cacheFile is the stored form of the cache.
+type cacheFile struct {
+	Generation string
+	Records    map[domain.ZettelID]*cacheRecord
+}
+
+type fileStamp struct {
+	Path    string
+	Size    int64
+	ModTime int64
+}
+
+type cacheRecord struct {
+	Stamps  []fileStamp
+	Pairs   []domain.MetaPair
+	YamlSep bool
+}
+
+// newDiskCache creates a new cache and loads the stored records. An
+// unreadable cache file is silently ignored, it will be rewritten later.
+func newDiskCache(path string) *diskCache {
+	dc := &diskCache{
+		path:    path,
+		records: make(map[domain.ZettelID]*cacheRecord),
+	}
+	if f, err := os.Open(path); err == nil {
+		var cf cacheFile
+		if err := gob.NewDecoder(f).Decode(&cf); err == nil && cf.Records != nil {
+			dc.generation = cf.Generation
+			dc.records = cf.Records
+		}
+		f.Close()
+	}
+	return dc
+}
+
+// save writes all records to the cache file, if they were changed.
+func (dc *diskCache) save() error {
+	if dc == nil {
+		return nil
+	}
+	dc.mx.Lock()
+	defer dc.mx.Unlock()
+	if !dc.dirty {
+		return nil
+	}
+	tmpPath := dc.path + ".tmp"
+	f, err := openFileWrite(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(f).Encode(&cacheFile{Generation: dc.generation, Records: dc.records})
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, dc.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	dc.dirty = false
+	return nil
+}
+
+// entryStamps returns the current stamps of all files of the given entry.
+func entryStamps(entry *directory.Entry) ([]fileStamp, bool) {
+	paths := make([]string, 0, 2)
+	if entry.MetaSpec == directory.MetaSpecFile {
+		paths = append(paths, entry.MetaPath)
+	}
+	paths = append(paths, entry.ContentPath)
+	stamps := make([]fileStamp, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, false
+		}
+		stamps = append(stamps, fileStamp{
+			Path:    filepath.Clean(path),
+			Size:    info.Size(),
+			ModTime: info.ModTime().UnixNano(),
+		})
+	}
+	return stamps, true
+}
+
+func sameStamps(s1, s2 []fileStamp) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+	for i, stamp := range s1 {
+		if stamp != s2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns the meta data of the given entry, if its files were not
+// changed since the meta data was stored.
+func (dc *diskCache) get(entry *directory.Entry) (*domain.Meta, bool) {
+	if dc == nil {
+		return nil, false
+	}
+	generation := config.KeyTypesGeneration()
+	dc.mx.Lock()
+	rec, ok := dc.records[entry.Zid]
+	valid := dc.generation == generation
+	dc.mx.Unlock()
+	if !ok || !valid {
+		return nil, false
+	}
+	stamps, ok := entryStamps(entry)
+	if !ok || !sameStamps(stamps, rec.Stamps) {
+		return nil, false
+	}
+	meta := domain.NewMeta(entry.Zid)
+	for _, p := range rec.Pairs {
+		meta.Set(p.Key, p.Value)
+	}
+	meta.YamlSep = rec.YamlSep
+	return meta, true
+}
+
+// set stores the meta data of the given entry. The stamps must be retrieved
+// before the files were read, so that a concurrent change is detected later.
+func (dc *diskCache) set(entry *directory.Entry, stamps []fileStamp, meta *domain.Meta) {
+	if dc == nil || stamps == nil {
+		return
+	}
+	rec := &cacheRecord{
+		Stamps:  stamps,
+		Pairs:   meta.Pairs(),
+		YamlSep: meta.YamlSep,
+	}
+	generation := config.KeyTypesGeneration()
+	dc.mx.Lock()
+	if dc.generation != generation {
+		dc.generation = generation
+		dc.records = make(map[domain.ZettelID]*cacheRecord)
+	}
+	dc.records[entry.Zid] = rec
+	dc.dirty = true
+	dc.mx.Unlock()
+}
+
+// remove deletes the record of the given zettel.
+func (dc *diskCache) remove(zid domain.ZettelID) {
+	if dc == nil {
+		return
+	}
+	dc.mx.Lock()
+	if _, ok := dc.records[zid]; ok {
+		delete(dc.records, zid)
+		dc.dirty = true
+	}
+	dc.mx.Unlock()
+}
+
+// retain deletes all records of zettel that are not in the given entries.
+func (dc *diskCache) retain(entries []directory.Entry) {
+	if dc == nil {
+		return
+	}
+	found := make(map[domain.ZettelID]bool, len(entries))
+	for _, entry := range entries {
+		found[entry.Zid] = true
+	}
+	dc.mx.Lock()
+	for zid := range dc.records {
+		if !found[zid] {
+			delete(dc.records, zid)
+			dc.dirty = true
+		}
+	}
+	dc.mx.Unlock()
+}
@@ -0,0 +1,156 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package dirplace provides a directory-based zettel place.
+package dirplace
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+	"zettelstore.de/z/place/dirplace/directory"
+)
+
+func TestDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	contentPath := filepath.Join(dir, "12345678901234.zettel")
+	if err := ioutil.WriteFile(contentPath, []byte("title: Cached\n\nabc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entry := directory.Entry{
+		Zid:         12345678901234,
+		MetaSpec:    directory.MetaSpecHeader,
+		ContentPath: contentPath,
+		ContentExt:  "zettel",
+	}
+	cachePath := filepath.Join(dir, metaCacheName)
+
+	dc := newDiskCache(cachePath)
+	if _, ok := dc.get(&entry); ok {
+		t.Error("Empty cache must not contain any meta data")
+	}
+	stamps, ok := entryStamps(&entry)
+	if !ok {
+		t.Fatal("Unable to stamp entry")
+	}
+	meta := domain.NewMeta(entry.Zid)
+	meta.Set(domain.MetaKeyTitle, "Cached")
+	dc.set(&entry, stamps, meta)
+	if err := dc.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	dc = newDiskCache(cachePath)
+	got, ok := dc.get(&entry)
+	if !ok {
+		t.Fatal("Stored meta data not found")
+	}
+	if !got.Equal(meta) {
+		t.Errorf("Expected meta %v, but got %v", meta.Pairs(), got.Pairs())
+	}
+
+	if err := ioutil.WriteFile(contentPath, []byte("title: Changed\n\nabcdef"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dc.get(&entry); ok {
+		t.Error("Meta data of changed file must not be returned")
+	}
+
+	dc.retain(nil)
+	if len(dc.records) != 0 {
+		t.Errorf("No record expected, but got %d", len(dc.records))
+	}
+}
+
+func TestDiskCacheSavedOnStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	contentPath := filepath.Join(dir, "12345678901234.zettel")
+	if err := ioutil.WriteFile(contentPath, []byte("title: Cached\n\nabc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := place.Connect("dir://"+dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.SelectMeta(context.Background(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	cachePath := filepath.Join(dir, metaCacheName)
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Errorf("Cache must not be written while selecting, but got %v", err)
+	}
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(newDiskCache(cachePath).records) != 1 {
+		t.Error("Cache must be written when the place is stopped")
+	}
+}
+
+func TestDiskCacheGeneration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	contentPath := filepath.Join(dir, "12345678901234.zettel")
+	if err := ioutil.WriteFile(contentPath, []byte("title: Cached\n\nabc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entry := directory.Entry{
+		Zid:         12345678901234,
+		MetaSpec:    directory.MetaSpecHeader,
+		ContentPath: contentPath,
+		ContentExt:  "zettel",
+	}
+	cachePath := filepath.Join(dir, metaCacheName)
+	dc := newDiskCache(cachePath)
+	stamps, _ := entryStamps(&entry)
+	dc.set(&entry, stamps, domain.NewMeta(entry.Zid))
+
+	// Meta data was parsed with other key types.
+	dc.generation = "other"
+	if err := dc.save(); err != nil {
+		t.Fatal(err)
+	}
+	dc = newDiskCache(cachePath)
+	if _, ok := dc.get(&entry); ok {
+		t.Error("Meta data of another generation must not be returned")
+	}
+	dc.set(&entry, stamps, domain.NewMeta(entry.Zid))
+	if _, ok := dc.get(&entry); !ok || dc.generation != "" {
+		t.Errorf("Meta data of current generation expected, generation=%q", dc.generation)
+	}
+}
@@ -0,0 +1,233 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package dirplace provides a directory-based zettel place.
+package dirplace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/input"
+	"zettelstore.de/z/place"
+	"zettelstore.de/z/place/dirplace/directory"
+)
+
+// checkEntry collects all files of a zettel.
+type checkEntry struct {
+	metaPaths    []string
+	contentPaths []string
+}
+
+var reZidPrefix = regexp.MustCompile(`^\d{14}`)
+
+// Check returns all problems found in the directory of the place. If fix is
+// true, problems that can be repaired without losing data are fixed.
+func (dp *dirPlace) Check(ctx context.Context, fix bool) ([]place.Problem, error) {
+	if dp.isStopped() {
+		return nil, place.ErrStopped
+	}
+	files, err := directory.ListFiles(dp.dir, dp.recursive)
+	if err != nil {
+		return nil, err
+	}
+	var problems []place.Problem
+	entries := make(map[domain.ZettelID]*checkEntry)
+	for _, path := range files {
+		name := filepath.Base(path)
+		if !reZidPrefix.MatchString(name) {
+			// Not a zettel file, e.g. a file of the place itself.
+			continue
+		}
+		match := directory.MatchValidFileName(name)
+		if len(match) == 0 {
+			problems = append(problems, place.Problem{Path: path, Message: "File name without extension"})
+			continue
+		}
+		zid, err := domain.ParseZettelID(match[1])
+		if err != nil {
+			problems = append(problems, place.Problem{Path: path, Message: "Invalid zettel id"})
+			continue
+		}
+		ce := entries[zid]
+		if ce == nil {
+			ce = new(checkEntry)
+			entries[zid] = ce
+		}
+		if match[3] == "meta" {
+			ce.metaPaths = append(ce.metaPaths, path)
+		} else {
+			ce.contentPaths = append(ce.contentPaths, path)
+		}
+	}
+
+	zids := make([]domain.ZettelID, 0, len(entries))
+	for zid := range entries {
+		zids = append(zids, zid)
+	}
+	sort.Slice(zids, func(i, j int) bool { return zids[i] < zids[j] })
+	for _, zid := range zids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		problems = append(problems, dp.checkZettel(zid, entries[zid], fix)...)
+	}
+	return problems, nil
+}
+
+// checkZettel checks all files of one zettel.
+func (dp *dirPlace) checkZettel(zid domain.ZettelID, ce *checkEntry, fix bool) []place.Problem {
+	var problems []place.Problem
+	addProblem := func(path string, fixed bool, format string, args ...interface{}) {
+		problems = append(problems, place.Problem{
+			Zid: zid, Path: path, Message: fmt.Sprintf(format, args...), Fixed: fixed})
+	}
+
+	srcs := make(map[string]string, len(ce.metaPaths)+len(ce.contentPaths))
+	for _, path := range append(ce.metaPaths, ce.contentPaths...) {
+		src, err := dp.crypt.readFile(zid, path)
+		if err != nil {
+			addProblem(path, false, "Unreadable file: %v", err)
+			continue
+		}
+		srcs[path] = src
+	}
+
+	var meta *domain.Meta
+	metaPath := ""
+	if len(ce.metaPaths) > 0 {
+		metaPath = ce.metaPaths[0]
+		if len(ce.metaPaths) > 1 {
+			addProblem(metaPath, false, "Multiple meta files: %v", strings.Join(ce.metaPaths, ", "))
+		}
+		if src, ok := srcs[metaPath]; ok {
+			for _, line := range checkMetaLines(src) {
+				addProblem(metaPath, false, "Unparsable meta data in line %d", line)
+			}
+			meta = domain.NewMetaFromInput(zid, input.NewInput(src))
+		}
+	}
+
+	switch len(ce.contentPaths) {
+	case 0:
+		fixed := false
+		if fix && meta != nil {
+			_, ext := calcSpecExt(meta)
+			path := strings.TrimSuffix(metaPath, ".meta") + "." + ext
+			fixed = dp.crypt.writeFile(zid, path, nil) == nil
+		}
+		addProblem(metaPath, fixed, "Meta file without content")
+		return problems
+	case 1:
+	default:
+		fixed := false
+		if fix && sameSources(srcs, ce.contentPaths) {
+			// The first file is used by the place, all others are equal.
+			fixed = true
+			for _, path := range ce.contentPaths[1:] {
+				if err := os.Remove(path); err != nil {
+					fixed = false
+				}
+			}
+		}
+		addProblem(ce.contentPaths[0], fixed, "Multiple content files: %v", strings.Join(ce.contentPaths, ", "))
+	}
+
+	contentPath := ce.contentPaths[0]
+	content, ok := srcs[contentPath]
+	if !ok {
+		return problems
+	}
+	ext := filepath.Ext(contentPath)[1:]
+	if meta == nil && ext == "zettel" {
+		for _, line := range checkMetaLines(content) {
+			addProblem(contentPath, false, "Unparsable meta data in line %d", line)
+		}
+		inp := input.NewInput(content)
+		meta = domain.NewMetaFromInput(zid, inp)
+		content = inp.Src[inp.Pos:]
+	}
+	syntax := ext
+	if meta != nil {
+		syntax = meta.GetDefault(domain.MetaKeySyntax, ext)
+	}
+	if !binarySyntax[syntax] && !utf8.ValidString(content) {
+		addProblem(contentPath, false, "Content is not valid UTF-8")
+	}
+	return problems
+}
+
+// binarySyntax contains all syntax values whose content is not text.
+var binarySyntax = map[string]bool{
+	"bin":  true,
+	"gif":  true,
+	"jpeg": true,
+	"jpg":  true,
+	"png":  true,
+}
+
+// sameSources returns true, if all files were read and have the same content.
+func sameSources(srcs map[string]string, paths []string) bool {
+	first, ok := srcs[paths[0]]
+	if !ok {
+		return false
+	}
+	for _, path := range paths[1:] {
+		if src, ok := srcs[path]; !ok || src != first {
+			return false
+		}
+	}
+	return true
+}
+
+var reMetaLine = regexp.MustCompile(`^([0-9A-Za-z-]+)[ \t]*:`)
+
+// checkMetaLines returns the numbers of all lines of a meta data section
+// that are ignored when the meta data is parsed.
+func checkMetaLines(src string) []int {
+	var result []int
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "---") {
+			if i == 0 {
+				continue
+			}
+			break
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		if line[0] == '%' || line[0] == ' ' || line[0] == '\t' {
+			// Comment or continuation line
+			continue
+		}
+		match := reMetaLine.FindStringSubmatch(line)
+		if match == nil || !domain.KeyIsValid(strings.ToLower(match[1])) {
+			result = append(result, i+1)
+		}
+	}
+	return result
+}
@@ -0,0 +1,92 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package dirplace provides a directory-based zettel place.
+package dirplace
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zettelstore.de/z/place"
+)
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"20200101000001.zettel": "title: Good\n\nContent",
+		"20200101000002.zettel": "title: Duplicate\n\nContent",
+		"20200101000002.txt":    "title: Duplicate\n\nContent",
+		"20200101000003.meta":   "title: No content\nsyntax: zmk\n",
+		"20200101000004.zettel": "title: Bad\nno key\n\nContent",
+		"20200101000005.zettel": "title: Latin-1\n\nK\xe4se",
+		"20200101000006":        "No extension",
+		"README":                "Not a zettel",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	p, err := place.Connect("dir://"+dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop(ctx)
+	problems, err := p.(place.Checker).Check(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []struct {
+		name  string
+		fixed bool
+	}{
+		{"20200101000006", false},
+		{"20200101000002.txt", true},
+		{"20200101000003.meta", true},
+		{"20200101000004.zettel", false},
+		{"20200101000005.zettel", false},
+	}
+	if len(problems) != len(exp) {
+		t.Fatalf("%d problems expected, but got %d: %v", len(exp), len(problems), problems)
+	}
+	for i, pr := range problems {
+		if name := filepath.Base(pr.Path); name != exp[i].name || pr.Fixed != exp[i].fixed {
+			t.Errorf("%d: problem of %q (fixed=%v) expected, but got %v", i, exp[i].name, exp[i].fixed, pr)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "20200101000002.zettel")); !os.IsNotExist(err) {
+		t.Error("Duplicate content file not removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "20200101000003.zettel")); err != nil {
+		t.Error("Missing content file not created")
+	}
+}
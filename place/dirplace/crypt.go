@@ -0,0 +1,341 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package dirplace provides a directory-based zettel place.
+package dirplace
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+
+	"zettelstore.de/z/config"
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place/dirplace/directory"
+)
+
+const (
+	// cryptName is the name of the file, relative to the place directory,
+	// that stores the salt for deriving the key, and a value to verify the
+	// passphrase.
+	cryptName = ".encryption"
+
+	// Keys of the encryption file.
+	cryptKeySalt  = "salt"
+	cryptKeyCheck = "check"
+
+	// cryptCheckText is encrypted to verify the passphrase.
+	cryptCheckText = "zettelstore"
+)
+
+// cryptMagic starts every encrypted file.
+var cryptMagic = []byte("ZSENC1")
+
+var (
+	errNoPassphrase    = errors.New("no passphrase given for encrypted place")
+	errWrongPassphrase = errors.New("wrong passphrase for encrypted place")
+	errNotEncrypted    = errors.New("file is not encrypted")
+)
+
+// crypter encrypts and decrypts the files of a place with AES-GCM. A nil
+// crypter stores all files unencrypted.
+type crypter struct {
+	aead cipher.AEAD
+}
+
+// openCrypter derives the key of the place in the given directory from the
+// passphrase. If the place was not encrypted before, a new salt is stored.
+func openCrypter(dir string, passphrase []byte) (*crypter, error) {
+	if len(passphrase) == 0 {
+		return nil, errNoPassphrase
+	}
+	path := filepath.Join(dir, cryptName)
+	info, err := parseMetaFile(nil, domain.InvalidZettelID, path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		return createCrypter(path, passphrase)
+	}
+	salt, err := hex.DecodeString(info.GetDefault(cryptKeySalt, ""))
+	if err != nil || len(salt) == 0 {
+		return nil, &os.PathError{Op: "read", Path: path, Err: errors.New("invalid salt")}
+	}
+	check, err := hex.DecodeString(info.GetDefault(cryptKeyCheck, ""))
+	if err != nil {
+		return nil, &os.PathError{Op: "read", Path: path, Err: errors.New("invalid check value")}
+	}
+	c, err := newCrypter(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if text, err := c.open(check, cryptCheckData); err != nil || string(text) != cryptCheckText {
+		return nil, errWrongPassphrase
+	}
+	return c, nil
+}
+
+func createCrypter(path string, passphrase []byte) (*crypter, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	c, err := newCrypter(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	check, err := c.seal([]byte(cryptCheckText), cryptCheckData)
+	if err != nil {
+		return nil, err
+	}
+	info := domain.NewMeta(domain.InvalidZettelID)
+	info.Set(cryptKeySalt, hex.EncodeToString(salt))
+	info.Set(cryptKeyCheck, hex.EncodeToString(check))
+	var buf bytes.Buffer
+	if _, err := info.Write(&buf); err != nil {
+		return nil, err
+	}
+	if err := (*crypter)(nil).writeFile(domain.InvalidZettelID, path, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func newCrypter(passphrase, salt []byte) (*crypter, error) {
+	key, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &crypter{aead: aead}, nil
+}
+
+// cryptCheckData is the additional data to encrypt the check value.
+var cryptCheckData = []byte(cryptName)
+
+// cryptData returns the additional data of a file of the given zettel. It
+// is authenticated together with the encrypted content, so that a file cannot
+// be exchanged with a file of another zettel, or with a file of another kind.
+func cryptData(zid domain.ZettelID, path string) []byte {
+	kind := "content"
+	switch filepath.Base(path) {
+	case trashInfoName:
+		kind = "info"
+	default:
+		if filepath.Ext(path) == ".meta" {
+			kind = "meta"
+		}
+	}
+	return []byte(zid.Format() + "." + kind)
+}
+
+// seal encrypts the given data and authenticates it together with the
+// additional data ad. The result starts with cryptMagic, followed by a
+// random nonce.
+func (c *crypter) seal(data, ad []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	result := make([]byte, 0, len(cryptMagic)+len(nonce)+len(data)+c.aead.Overhead())
+	result = append(append(result, cryptMagic...), nonce...)
+	return c.aead.Seal(result, nonce, data, ad), nil
+}
+
+// open decrypts data that was encrypted by seal with the same additional data.
+func (c *crypter) open(data, ad []byte) ([]byte, error) {
+	if !isEncrypted(data) {
+		return nil, errNotEncrypted
+	}
+	data = data[len(cryptMagic):]
+	size := c.aead.NonceSize()
+	if len(data) < size {
+		return nil, errors.New("encrypted file too short")
+	}
+	return c.aead.Open(nil, data[:size], data[size:], ad)
+}
+
+func isEncrypted(data []byte) bool { return bytes.HasPrefix(data, cryptMagic) }
+
+// readFile returns the decrypted content of the given file of a zettel.
+func (c *crypter) readFile(zid domain.ZettelID, path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if c == nil {
+		return string(data), nil
+	}
+	plain, err := c.open(data, cryptData(zid, path))
+	if err != nil {
+		return "", &os.PathError{Op: "decrypt", Path: path, Err: err}
+	}
+	return string(plain), nil
+}
+
+// writeFile encrypts the given content and writes it to the file of a zettel.
+func (c *crypter) writeFile(zid domain.ZettelID, path string, content []byte) error {
+	if c != nil {
+		sealed, err := c.seal(content, cryptData(zid, path))
+		if err != nil {
+			return err
+		}
+		content = sealed
+	}
+	f, err := openFileWrite(path)
+	if err == nil {
+		_, err = f.Write(content)
+		if err1 := f.Close(); err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+// encryptDir encrypts all files of zettel in the given directory that are
+// still unencrypted. This includes prior versions and deleted zettel. It
+// returns the number of encrypted files.
+func (c *crypter) encryptDir(dir string) (int, error) {
+	count := 0
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if path != dir && directory.IsHiddenDir(name) && name != historyDir && name != trashDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		zid, ok := zettelFileZid(dir, path)
+		if !ok {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil || isEncrypted(data) {
+			return err
+		}
+		if err := c.writeFile(zid, path, data); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// zettelFileZid returns the zettel identifier of the given file, if it stores
+// (a version of) a zettel, or if it describes a deleted zettel.
+func zettelFileZid(dir, path string) (domain.ZettelID, bool) {
+	if match := directory.MatchValidFileName(filepath.Base(path)); len(match) > 0 {
+		zid, err := domain.ParseZettelID(match[1])
+		return zid, err == nil
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return domain.InvalidZettelID, false
+	}
+	elems := strings.Split(rel, string(filepath.Separator))
+	if len(elems) != 3 || (elems[0] != historyDir && (elems[0] != trashDir || elems[2] != trashInfoName)) {
+		return domain.InvalidZettelID, false
+	}
+	zid, err := domain.ParseZettelID(elems[1])
+	return zid, err == nil
+}
+
+// renameFile moves a file of a zettel to another zettel. An encrypted file
+// must be encrypted again, because it is bound to its zettel.
+func (c *crypter) renameFile(curZid domain.ZettelID, curPath string, newZid domain.ZettelID, newPath string) error {
+	if c == nil || curZid == newZid {
+		return os.Rename(curPath, newPath)
+	}
+	content, err := c.readFile(curZid, curPath)
+	if err != nil {
+		return err
+	}
+	if err := c.writeFile(newZid, newPath, []byte(content)); err != nil {
+		return err
+	}
+	if newPath == curPath {
+		return nil
+	}
+	return os.Remove(curPath)
+}
+
+// renameDir moves all prior versions of a zettel to another zettel.
+func (c *crypter) renameDir(curZid domain.ZettelID, curDir string, newZid domain.ZettelID, newDir string) error {
+	if err := os.Rename(curDir, newDir); err != nil || c == nil {
+		return err
+	}
+	infos, err := ioutil.ReadDir(newDir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		path := filepath.Join(newDir, info.Name())
+		if err := c.renameFile(curZid, path, newZid, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openCrypter prepares the encryption of all files of the place. Files that
+// are still unencrypted are encrypted now.
+func (dp *dirPlace) openCrypter() error {
+	c, err := openCrypter(dp.dir, config.PlaceKey())
+	if err != nil {
+		return err
+	}
+	count, err := c.encryptDir(dp.dir)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		log.Printf("Encrypted %d files of place %v", count, dp.dir)
+	}
+
+	// An old meta cache stores titles and tags unencrypted.
+	if err := os.Remove(filepath.Join(dp.dir, metaCacheName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	dp.crypt = c
+	return nil
+}
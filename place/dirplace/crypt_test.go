@@ -0,0 +1,142 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package dirplace provides a directory-based zettel place.
+package dirplace
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zettelstore.de/z/config"
+	"zettelstore.de/z/place"
+)
+
+func TestEncryptedPlace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cryptplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "20200101000001.zettel")
+	if err := ioutil.WriteFile(path, []byte("title: Secret\n\nContent"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	infoPath := filepath.Join(dir, trashDir, "20200101000002", trashInfoName)
+	if err := os.MkdirAll(filepath.Dir(infoPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(infoPath, []byte("deleted-by: alice\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer config.SetupPlaceKey(nil)
+	config.SetupPlaceKey([]byte("passphrase"))
+	ctx := context.Background()
+	p, err := place.Connect("dir://"+dir+"?encrypt=true", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncrypted(data) || bytes.Contains(data, []byte("Secret")) {
+		t.Errorf("File not encrypted: %q", data)
+	}
+	if data, err = ioutil.ReadFile(infoPath); err != nil {
+		t.Fatal(err)
+	}
+	if !isEncrypted(data) {
+		t.Errorf("Trash info not encrypted: %q", data)
+	}
+	zettel, err := p.GetZettel(ctx, 20200101000001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := zettel.Meta.GetDefault("title", ""); got != "Secret" {
+		t.Errorf("Title %q expected, but got %q", "Secret", got)
+	}
+	if got := zettel.Content.AsString(); got != "Content" {
+		t.Errorf("Content %q expected, but got %q", "Content", got)
+	}
+	p.Stop(ctx)
+
+	config.SetupPlaceKey([]byte("wrong"))
+	if p, err = place.Connect("dir://"+dir+"?encrypt=true", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(ctx); err != errWrongPassphrase {
+		t.Errorf("Wrong passphrase not detected, got %v", err)
+	}
+}
+
+func TestEncryptedFilesBound(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cryptplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := openCrypter(dir, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path1 := filepath.Join(dir, "20200101000001.meta")
+	if err := c.writeFile(20200101000001, path1, []byte("title: One")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.readFile(20200101000001, path1); err != nil || got != "title: One" {
+		t.Errorf("Meta data %q expected, but got %q (%v)", "title: One", got, err)
+	}
+	if _, err := c.readFile(20200101000002, filepath.Join(dir, "20200101000002.meta")); err == nil {
+		t.Error("File of another zettel must not be decrypted")
+	}
+	if _, err := c.readFile(20200101000001, filepath.Join(dir, "20200101000001.zettel")); err == nil {
+		t.Error("Meta file must not be decrypted as content")
+	}
+
+	path2 := filepath.Join(dir, "20200101000002.meta")
+	if err := c.renameFile(20200101000001, path1, 20200101000002, path2); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.readFile(20200101000002, path2); err != nil || got != "title: One" {
+		t.Errorf("Renamed meta data %q expected, but got %q (%v)", "title: One", got, err)
+	}
+	if _, err := os.Stat(path1); !os.IsNotExist(err) {
+		t.Errorf("Renamed file must be removed, but got %v", err)
+	}
+}
+
+func TestEncryptedGitPlace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cryptplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := place.Connect("git://"+dir+"?encrypt=true", nil); err != errGitEncrypt {
+		t.Errorf("Encrypted git place must be refused, but got %v", err)
+	}
+}
@@ -21,6 +21,7 @@
 package directory
 
 import (
+	"strings"
 	"sync"
 	"time"
 
@@ -31,18 +32,21 @@ import (
 // Service specifies a directory scan service.
 type Service struct {
 	dirPath     string
+	recursive   bool
 	ticker      *time.Ticker
 	cmds        chan dirCmd
 	changeFuncs []place.ObserverFunc
 	mxFuncs     sync.RWMutex
 }
 
-// NewService creates a new directory service.
-func NewService(directoryPath string, rescanTime time.Duration) *Service {
+// NewService creates a new directory service. If recursive is true, all
+// subdirectories are scanned too, except hidden ones.
+func NewService(directoryPath string, rescanTime time.Duration, recursive bool) *Service {
 	srv := &Service{
-		dirPath: directoryPath,
-		ticker:  time.NewTicker(rescanTime),
-		cmds:    make(chan dirCmd),
+		dirPath:   directoryPath,
+		recursive: recursive,
+		ticker:    time.NewTicker(rescanTime),
+		cmds:      make(chan dirCmd),
 	}
 	return srv
 }
@@ -56,7 +60,7 @@ func (srv *Service) Start() {
 	ready := make(chan int)
 	go srv.directoryService(events, ready)
 	go collectEvents(events, rawEvents)
-	go watchDirectory(srv.dirPath, rawEvents, done)
+	go watchDirectory(srv.dirPath, srv.recursive, rawEvents, done)
 	go ping(done, srv.ticker)
 	<-ready
 }
@@ -76,12 +80,12 @@ func (srv *Service) Subscribe(changeFunc place.ObserverFunc) {
 	srv.mxFuncs.Unlock()
 }
 
-func (srv *Service) notifyChange(all bool, zid domain.ZettelID) {
+func (srv *Service) notifyChange(reason place.ChangeReason, zid domain.ZettelID) {
 	srv.mxFuncs.RLock()
 	changeFuncs := srv.changeFuncs
 	srv.mxFuncs.RUnlock()
 	for _, changeF := range changeFuncs {
-		changeF(all, zid)
+		changeF(place.ChangeInfo{Reason: reason, Zid: zid})
 	}
 }
 
@@ -111,6 +115,78 @@ func (e *Entry) IsValid() bool {
 	return e.Zid.IsValid()
 }
 
+// AddFile updates the entry with a file that belongs to its zettel. The
+// extension determines whether the file stores meta data or content. If
+// there is already another file of the same kind, e.g. with the same name in
+// another subdirectory, the entry is marked as having duplicates.
+func (e *Entry) AddFile(path, ext string) {
+	if ext == "meta" {
+		if e.MetaPath != "" && e.MetaPath != path {
+			e.Duplicates = true
+			return
+		}
+		e.MetaSpec = MetaSpecFile
+		e.MetaPath = path
+		return
+	}
+	if len(e.ContentExt) != 0 && (e.ContentExt != ext || e.ContentPath != path) {
+		e.Duplicates = true
+		return
+	}
+	if e.MetaSpec != MetaSpecFile {
+		if ext == "zettel" {
+			e.MetaSpec = MetaSpecHeader
+		} else {
+			e.MetaSpec = MetaSpecNone
+		}
+	}
+	e.ContentPath = path
+	e.ContentExt = ext
+}
+
+var alternativeSyntax = map[string]string{
+	"htm":  "html",
+	"tmpl": "go-template-html",
+}
+
+// CalcSyntax returns the syntax of the zettel content, derived from the
+// extension of the content file.
+func (e *Entry) CalcSyntax() string {
+	ext := strings.ToLower(e.ContentExt)
+	if syntax, ok := alternativeSyntax[ext]; ok {
+		return syntax
+	}
+	return ext
+}
+
+// CalcMeta returns the meta data of a zettel, which has no stored meta data.
+func (e *Entry) CalcMeta() *domain.Meta {
+	meta := domain.NewMeta(e.Zid)
+	meta.Set(domain.MetaKeyTitle, e.Zid.Format())
+	meta.Set(domain.MetaKeySyntax, e.CalcSyntax())
+	return meta
+}
+
+// CleanupMeta sets all meta values that can be derived from the entry, if
+// they are missing: title, syntax, and the given modification time. Duplicate
+// files are marked too.
+func (e *Entry) CleanupMeta(meta *domain.Meta, modified time.Time) {
+	if title, ok := meta.Get(domain.MetaKeyTitle); !ok || title == "" {
+		meta.Set(domain.MetaKeyTitle, e.Zid.Format())
+	}
+	if e.MetaSpec == MetaSpecFile {
+		if syntax, ok := meta.Get(domain.MetaKeySyntax); !ok || syntax == "" {
+			meta.Set(domain.MetaKeySyntax, e.CalcSyntax())
+		}
+	}
+	if _, ok := meta.Get(domain.MetaKeyModified); !ok && !modified.IsZero() {
+		meta.Set(domain.MetaKeyModified, domain.TimestampValue(modified))
+	}
+	if e.Duplicates {
+		meta.Set("duplicates", "yes")
+	}
+}
+
 // GetEntries returns an unsorted list of all current directory entries.
 func (srv *Service) GetEntries() []Entry {
 	resChan := make(chan resGetEntries)
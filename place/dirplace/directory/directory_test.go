@@ -0,0 +1,50 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package directory manages the directory part of a file store.
+package directory
+
+import "testing"
+
+func TestAddFile(t *testing.T) {
+	type file struct{ path, ext string }
+	testcases := []struct {
+		files   []file
+		path    string
+		metaSpc MetaSpec
+		dup     bool
+	}{
+		{[]file{{"a/1.zettel", "zettel"}}, "a/1.zettel", MetaSpecHeader, false},
+		{[]file{{"a/1.zettel", "zettel"}, {"a/1.zettel", "zettel"}}, "a/1.zettel", MetaSpecHeader, false},
+		{[]file{{"a/1.txt", "txt"}, {"a/1.meta", "meta"}}, "a/1.txt", MetaSpecFile, false},
+		{[]file{{"a/1.txt", "txt"}, {"a/1.md", "md"}}, "a/1.txt", MetaSpecNone, true},
+		{[]file{{"a/1.zettel", "zettel"}, {"b/1.zettel", "zettel"}}, "a/1.zettel", MetaSpecHeader, true},
+		{[]file{{"a/1.meta", "meta"}, {"b/1.meta", "meta"}, {"a/1.txt", "txt"}}, "a/1.txt", MetaSpecFile, true},
+	}
+	for i, tc := range testcases {
+		var e Entry
+		for _, f := range tc.files {
+			e.AddFile(f.path, f.ext)
+		}
+		if e.ContentPath != tc.path || e.MetaSpec != tc.metaSpc || e.Duplicates != tc.dup {
+			t.Errorf("TC=%d: exp=%q/%v/%v, got=%q/%v/%v",
+				i, tc.path, tc.metaSpc, tc.dup, e.ContentPath, e.MetaSpec, e.Duplicates)
+		}
+	}
+}
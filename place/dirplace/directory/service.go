@@ -45,31 +45,10 @@ func ping(done chan<- struct{}, tick *time.Ticker) {
 func newEntry(ev *fileEvent) *Entry {
 	de := new(Entry)
 	de.Zid = ev.zid
-	updateEntry(de, ev)
+	de.AddFile(ev.path, ev.ext)
 	return de
 }
 
-func updateEntry(de *Entry, ev *fileEvent) {
-	if ev.ext == "meta" {
-		de.MetaSpec = MetaSpecFile
-		de.MetaPath = ev.path
-		return
-	}
-	if len(de.ContentExt) != 0 && de.ContentExt != ev.ext {
-		de.Duplicates = true
-		return
-	}
-	if de.MetaSpec != MetaSpecFile {
-		if ev.ext == "zettel" {
-			de.MetaSpec = MetaSpecHeader
-		} else {
-			de.MetaSpec = MetaSpecNone
-		}
-	}
-	de.ContentPath = ev.path
-	de.ContentExt = ev.ext
-}
-
 type dirMap map[domain.ZettelID]*Entry
 
 func dirMapUpdate(dm dirMap, ev *fileEvent) {
@@ -78,7 +57,7 @@ func dirMapUpdate(dm dirMap, ev *fileEvent) {
 		dm[ev.zid] = newEntry(ev)
 		return
 	}
-	updateEntry(de, ev)
+	de.AddFile(ev.path, ev.ext)
 }
 
 // directoryService is the main service.
@@ -102,22 +81,26 @@ func (srv *Service) directoryService(events <-chan *fileEvent, ready chan<- int)
 					close(ready)
 					ready = nil
 				}
-				srv.notifyChange(true, domain.InvalidZettelID)
+				srv.notifyChange(place.OnReload, domain.InvalidZettelID)
 			case fileStatusError:
 				log.Println("DIRPLACE", "ERROR", ev.err)
 			case fileStatusUpdate:
 				if newMap != nil {
 					dirMapUpdate(newMap, ev)
 				} else {
+					reason := place.OnUpdate
+					if _, found := curMap[ev.zid]; !found {
+						reason = place.OnCreate
+					}
 					dirMapUpdate(curMap, ev)
-					srv.notifyChange(false, ev.zid)
+					srv.notifyChange(reason, ev.zid)
 				}
 			case fileStatusDelete:
 				if newMap != nil {
 					delete(newMap, ev.zid)
 				} else {
 					delete(curMap, ev.zid)
-					srv.notifyChange(false, ev.zid)
+					srv.notifyChange(place.OnDelete, ev.zid)
 				}
 			}
 		case cmd, ok := <-srv.cmds:
@@ -34,7 +34,10 @@ import (
 
 var validFileName = regexp.MustCompile("^(\\d{14}).*(\\.(.+))$")
 
-func matchValidFileName(name string) []string {
+// MatchValidFileName checks whether the given file name belongs to a zettel.
+// If so, the second element of the result is the zettel id and the fourth
+// element is the file extension.
+func MatchValidFileName(name string) []string {
 	return validFileName.FindStringSubmatch(name)
 }
 
@@ -65,10 +68,52 @@ const (
 	sendExit
 )
 
-func watchDirectory(directory string, events chan<- *fileEvent, done <-chan struct{}) {
+// IsHiddenDir returns true, if the directory must not be scanned. These
+// directories are used by the place itself, e.g. to store prior versions.
+func IsHiddenDir(name string) bool {
+	return len(name) > 0 && name[0] == '.'
+}
+
+// ListFiles returns all regular files of the directory. If recursive is true,
+// the files of all non-hidden subdirectories are returned too.
+func ListFiles(directory string, recursive bool) ([]string, error) {
+	files, _, err := listFiles(directory, recursive)
+	return files, err
+}
+
+// listFiles returns all regular files of the directory. If recursive is true,
+// the files of all non-hidden subdirectories are returned too, as well as all
+// scanned directories.
+func listFiles(directory string, recursive bool) (files, dirs []string, err error) {
+	infos, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, nil, err
+	}
+	dirs = []string{directory}
+	for _, info := range infos {
+		path := filepath.Join(directory, info.Name())
+		if info.Mode().IsRegular() {
+			files = append(files, path)
+			continue
+		}
+		if !recursive || !info.IsDir() || IsHiddenDir(info.Name()) {
+			continue
+		}
+		subFiles, subDirs, err := listFiles(path, recursive)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, subFiles...)
+		dirs = append(dirs, subDirs...)
+	}
+	return files, dirs, nil
+}
+
+func watchDirectory(directory string, recursive bool, events chan<- *fileEvent, done <-chan struct{}) {
 	defer close(events)
 
 	var watcher *fsnotify.Watcher
+	var watchedDirs map[string]bool
 	defer func() {
 		if watcher != nil {
 			watcher.Close()
@@ -108,7 +153,7 @@ func watchDirectory(directory string, events chan<- *fileEvent, done <-chan stru
 	reloadStartEvent := &fileEvent{status: fileStatusReloadStart}
 	reloadEndEvent := &fileEvent{status: fileStatusReloadEnd}
 	reloadFiles := func() bool {
-		files, err := ioutil.ReadDir(directory)
+		files, dirs, err := listFiles(directory, recursive)
 		if err != nil {
 			if res := sendError(err); res != sendDone {
 				return res == sendReload
@@ -130,26 +175,26 @@ func watchDirectory(directory string, events chan<- *fileEvent, done <-chan stru
 			}
 		}
 
-		for _, file := range files {
-			if !file.Mode().IsRegular() {
-				continue
-			}
-			name := file.Name()
-			match := matchValidFileName(name)
+		for _, path := range files {
+			match := MatchValidFileName(filepath.Base(path))
 			if len(match) > 0 {
-				path := filepath.Join(directory, name)
 				if res := sendFileEvent(fileStatusUpdate, path, match); res != sendDone {
 					return res == sendReload
 				}
 			}
 		}
 
+		watchedDirs = make(map[string]bool, len(dirs))
 		if watcher != nil {
-			err = watcher.Add(directory)
-			if err != nil {
-				if res := sendError(err); res != sendDone {
-					return res == sendReload
+			for _, dir := range dirs {
+				err = watcher.Add(dir)
+				if err != nil {
+					if res := sendError(err); res != sendDone {
+						return res == sendReload
+					}
+					continue
 				}
+				watchedDirs[dir] = true
 			}
 		}
 		if res := sendEvent(reloadEndEvent); res != sendDone {
@@ -169,7 +214,12 @@ func watchDirectory(directory string, events chan<- *fileEvent, done <-chan stru
 					return false
 				}
 				path := filepath.Clean(wevent.Name)
-				match := matchValidFileName(filepath.Base(path))
+				if recursive && isDirChange(wevent, path, watchedDirs) {
+					// Scan everything again, to add or remove all watches
+					// and all files of the changed directory.
+					return true
+				}
+				match := MatchValidFileName(filepath.Base(path))
 				if len(match) == 0 {
 					continue
 				}
@@ -224,6 +274,18 @@ func watchDirectory(directory string, events chan<- *fileEvent, done <-chan stru
 	}
 }
 
+// isDirChange returns true, if a subdirectory was created or removed.
+func isDirChange(wevent fsnotify.Event, path string, watchedDirs map[string]bool) bool {
+	if wevent.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		return watchedDirs[path]
+	}
+	if wevent.Op&fsnotify.Create != 0 {
+		fi, err := os.Lstat(path)
+		return err == nil && fi.IsDir() && !IsHiddenDir(fi.Name())
+	}
+	return false
+}
+
 func sendCollectedEvents(out chan<- *fileEvent, events []*fileEvent) {
 	for _, ev := range events {
 		if ev.status != fileStatusNone {
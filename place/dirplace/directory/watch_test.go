@@ -21,6 +21,10 @@
 package directory
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 )
 
@@ -49,7 +53,7 @@ func TestMatchValidFileName(t *testing.T) {
 	}
 
 	for i, tc := range testcases {
-		got := matchValidFileName(tc.name)
+		got := MatchValidFileName(tc.name)
 		if len(got) == 0 {
 			if len(tc.exp) > 0 {
 				t.Errorf("TC=%d, name=%q, exp=%v, got=%v", i, tc.name, tc.exp, got)
@@ -64,3 +68,59 @@ func TestMatchValidFileName(t *testing.T) {
 		}
 	}
 }
+
+func TestListFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "directory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{
+		"12345678901234.zettel",
+		"sub/12345678901235.zettel",
+		"sub/deep/12345678901236.txt",
+		".history/12345678901234/20200101000000.meta",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testcases := []struct {
+		recursive bool
+		files     []string
+		dirs      []string
+	}{
+		{false, []string{"12345678901234.zettel"}, []string{"."}},
+		{true,
+			[]string{"12345678901234.zettel", "sub/12345678901235.zettel", "sub/deep/12345678901236.txt"},
+			[]string{".", "sub", "sub/deep"}},
+	}
+	for i, tc := range testcases {
+		files, dirs, err := listFiles(dir, tc.recursive)
+		if err != nil {
+			t.Errorf("TC=%d: unexpected error %v", i, err)
+			continue
+		}
+		if got := relPaths(dir, files); !sameStringSlices(got, tc.files) {
+			t.Errorf("TC=%d: files exp=%v, got=%v", i, tc.files, got)
+		}
+		if got := relPaths(dir, dirs); !sameStringSlices(got, tc.dirs) {
+			t.Errorf("TC=%d: dirs exp=%v, got=%v", i, tc.dirs, got)
+		}
+	}
+}
+
+func relPaths(base string, paths []string) []string {
+	result := make([]string, 0, len(paths))
+	for _, path := range paths {
+		rel, _ := filepath.Rel(base, path)
+		result = append(result, filepath.ToSlash(rel))
+	}
+	sort.Strings(result)
+	return result
+}
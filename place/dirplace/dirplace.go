@@ -22,10 +22,10 @@ package dirplace
 
 import (
 	"context"
+	"log"
 	"net/url"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -38,22 +38,44 @@ import (
 
 func init() {
 	place.Register("dir", func(u *url.URL, next place.Place) (place.Place, error) {
-		path := getDirPath(u)
-		if _, err := os.Stat(path); os.IsNotExist(err) {
+		dp, err := newDirPlace(u, next)
+		if err != nil {
 			return nil, err
 		}
-		dp := dirPlace{
-			u:         u,
-			next:      next,
-			dir:       path,
-			dirRescan: time.Duration(getQueryInt(u, "rescan", 60, 600, 30*24*60*60)) * time.Second,
-			fSrvs:     uint32(getQueryInt(u, "worker", 1, 17, 1499)),
-		}
-		dp.cacheChange(true, domain.InvalidZettelID)
-		return &dp, nil
+		return dp, nil
 	})
 }
 
+// newDirPlace creates a new directory place. Prior versions of zettel are only
+// kept, if the URL specifies it, because they are never removed.
+func newDirPlace(u *url.URL, next place.Place) (*dirPlace, error) {
+	path := getDirPath(u)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, err
+	}
+	dp := dirPlace{
+		u:         u,
+		next:      next,
+		dir:       path,
+		dirRescan: time.Duration(place.GetQueryInt(u, "rescan", 60, 600, 30*24*60*60)) * time.Second,
+		fSrvs:     uint32(place.GetQueryInt(u, "worker", 1, 17, 1499)),
+		history:   place.GetQueryBool(u, "history", false),
+		trashDays: place.GetQueryInt(u, "trash", 0, 30, 3650),
+		recursive: place.GetQueryBool(u, "recursive", false),
+		useCache:  place.GetQueryBool(u, "metacache", true),
+		encrypt:   place.GetQueryBool(u, "encrypt", false),
+	}
+	if dp.encrypt {
+		// The meta cache would store titles and tags unencrypted.
+		dp.useCache = false
+	}
+	if dp.recursive {
+		dp.folder = getQueryFolder(u, "folder")
+	}
+	dp.cacheChange(true, domain.InvalidZettelID)
+	return &dp, nil
+}
+
 func getDirPath(u *url.URL) string {
 	if u.Opaque != "" {
 		return filepath.Clean(u.Opaque)
@@ -61,22 +83,19 @@ func getDirPath(u *url.URL) string {
 	return filepath.Clean(u.Path)
 }
 
-func getQueryInt(u *url.URL, key string, min, def, max int) int {
-	sVal := u.Query().Get(key)
-	if sVal == "" {
-		return def
-	}
-	iVal, err := strconv.Atoi(sVal)
-	if err != nil {
-		return def
-	}
-	if iVal < min {
-		return min
+// getQueryFolder returns a relative path to a subdirectory. Hidden
+// directories, or paths outside the place directory are not allowed.
+func getQueryFolder(u *url.URL, key string) string {
+	folder := filepath.Clean(filepath.FromSlash(u.Query().Get(key)))
+	if folder == "." || filepath.IsAbs(folder) {
+		return ""
 	}
-	if iVal > max {
-		return max
+	for _, elem := range strings.Split(folder, string(filepath.Separator)) {
+		if directory.IsHiddenDir(elem) {
+			return ""
+		}
 	}
-	return iVal
+	return folder
 }
 
 // dirPlace uses a directory to store zettel as files.
@@ -93,8 +112,28 @@ type dirPlace struct {
 	mxCmds     sync.RWMutex
 	metaCache  map[domain.ZettelID]*domain.Meta
 	mxCache    sync.RWMutex
+	mxUpdate   sync.Mutex // Serializes updates, so that versions can be checked
+	history    bool
+	trashDays  int
+	recursive  bool
+	folder     string // subdirectory for new zettel, if recursive
+	useCache   bool
+	diskCache  *diskCache
+	cacheDone  chan struct{} // closed to stop saving the disk cache
+	git        *gitRepo      // nil, if changes are not committed
+	encrypt    bool
+	crypt      *crypter // nil, if files are not encrypted
 }
 
+const (
+	// historyDir is the directory, relative to the place directory, where
+	// prior versions of all zettel are stored.
+	historyDir = ".history"
+
+	// versionLayout is used to name the files of a prior version.
+	versionLayout = "20060102150405.000000000"
+)
+
 func (dp *dirPlace) isStopped() bool {
 	return dp.dirSrv == nil
 }
@@ -114,27 +153,50 @@ func (dp *dirPlace) Start(ctx context.Context) error {
 			return err
 		}
 	}
+	if dp.encrypt {
+		if err := dp.openCrypter(); err != nil {
+			return err
+		}
+	}
 	dp.mxCmds.Lock()
 	dp.fCmds = make([]chan fileCmd, 0, dp.fSrvs)
 	for i := uint32(0); i < dp.fSrvs; i++ {
 		cc := make(chan fileCmd)
-		go fileService(i, cc)
+		go fileService(i, cc, dp.crypt)
 		dp.fCmds = append(dp.fCmds, cc)
 	}
-	dp.dirSrv = directory.NewService(dp.dir, dp.dirRescan)
+	if dp.folder != "" {
+		if err := os.MkdirAll(filepath.Join(dp.dir, dp.folder), 0755); err != nil {
+			dp.mxCmds.Unlock()
+			return err
+		}
+	}
+	if dp.git != nil {
+		if err := dp.git.open(ctx); err != nil {
+			dp.mxCmds.Unlock()
+			return err
+		}
+	}
+	if dp.useCache {
+		dp.diskCache = newDiskCache(filepath.Join(dp.dir, metaCacheName))
+		dp.cacheDone = make(chan struct{})
+		go dp.cacheSaver(dp.cacheDone)
+	}
+	dp.dirSrv = directory.NewService(dp.dir, dp.dirRescan, dp.recursive)
 	dp.mxCmds.Unlock()
 	dp.dirSrv.Subscribe(dp.notifyChanged)
 	dp.dirSrv.Start()
+	dp.purgeTrash()
 	return nil
 }
 
-func (dp *dirPlace) notifyChanged(all bool, zid domain.ZettelID) {
-	dp.cacheChange(all, zid)
+func (dp *dirPlace) notifyChanged(ci place.ChangeInfo) {
+	dp.cacheChange(ci.Reason == place.OnReload, ci.Zid)
 	dp.mxObserver.RLock()
 	observers := dp.observers
 	dp.mxObserver.RUnlock()
 	for _, ob := range observers {
-		ob(all, zid)
+		ob(ci)
 	}
 }
 
@@ -157,6 +219,11 @@ func (dp *dirPlace) Stop(ctx context.Context) error {
 	dirSrv := dp.dirSrv
 	dp.dirSrv = nil
 	dirSrv.Stop()
+	if dp.cacheDone != nil {
+		close(dp.cacheDone)
+		dp.cacheDone = nil
+	}
+	dp.saveDiskCache()
 	for _, c := range dp.fCmds {
 		close(c)
 	}
@@ -198,8 +265,13 @@ func (dp *dirPlace) CreateZettel(ctx context.Context, zettel domain.Zettel) (dom
 	close(rc)
 	if err == nil {
 		dp.dirSrv.UpdateEntry(&entry)
+		dp.notifyChanged(place.ChangeInfo{Reason: place.OnCreate, Zid: meta.Zid})
+		dp.commitZettel(ctx, "Create zettel "+meta.Zid.Format(), meta.Zid)
 
 		// Make meta available, because place may need some time to update directory.
+		if folder := dp.entryFolder(&entry); folder != "" {
+			meta.Set(domain.MetaKeyFolder, folder)
+		}
 		dp.cacheSetMeta(zettel.Meta)
 	}
 	return meta.Zid, err
@@ -219,6 +291,7 @@ func (dp *dirPlace) GetZettel(ctx context.Context, zid domain.ZettelID) (domain.
 		return domain.Zettel{}, &place.ErrUnknownID{Zid: zid}
 	}
 
+	stamps, _ := entryStamps(&entry)
 	rc := make(chan resGetMetaContent)
 	dp.getFileChan(zid) <- &fileGetMetaContent{&entry, rc}
 	res := <-rc
@@ -227,7 +300,8 @@ func (dp *dirPlace) GetZettel(ctx context.Context, zid domain.ZettelID) (domain.
 	if res.err != nil {
 		return domain.Zettel{}, res.err
 	}
-	dp.cleanupMeta(ctx, res.meta)
+	dp.diskCache.set(&entry, stamps, res.meta)
+	dp.cleanupMeta(ctx, res.meta, &entry)
 	zettel := domain.Zettel{Meta: res.meta, Content: domain.NewContent(res.content)}
 	dp.cacheSetMeta(res.meta)
 	return zettel, nil
@@ -250,17 +324,23 @@ func (dp *dirPlace) GetMeta(ctx context.Context, zid domain.ZettelID) (*domain.M
 		return nil, &place.ErrUnknownID{Zid: zid}
 	}
 
-	rc := make(chan resGetMeta)
-	dp.getFileChan(zid) <- &fileGetMeta{&entry, rc}
-	res := <-rc
-	close(rc)
+	meta, ok = dp.diskCache.get(&entry)
+	if !ok {
+		stamps, _ := entryStamps(&entry)
+		rc := make(chan resGetMeta)
+		dp.getFileChan(zid) <- &fileGetMeta{&entry, rc}
+		res := <-rc
+		close(rc)
 
-	if res.err != nil {
-		return nil, res.err
+		if res.err != nil {
+			return nil, res.err
+		}
+		meta = res.meta
+		dp.diskCache.set(&entry, stamps, meta)
 	}
-	dp.cleanupMeta(ctx, res.meta)
-	dp.cacheSetMeta(res.meta)
-	return res.meta, nil
+	dp.cleanupMeta(ctx, meta, &entry)
+	dp.cacheSetMeta(meta)
+	return meta, nil
 }
 
 // SelectMeta returns all zettel meta data that match the selection
@@ -272,33 +352,35 @@ func (dp *dirPlace) SelectMeta(ctx context.Context, f *place.Filter, s *place.So
 
 	hasMatch := place.CreateFilterFunc(f)
 	entries := dp.dirSrv.GetEntries()
-	rc := make(chan resGetMeta)
 	res = make([]*domain.Meta, 0, len(entries))
-	for _, entry := range entries {
+	var missing []*directory.Entry
+	for i := range entries {
+		entry := &entries[i]
 		meta, ok := dp.cacheGetMeta(entry.Zid)
 		if !ok {
-			dp.getFileChan(entry.Zid) <- &fileGetMeta{&entry, rc}
-
-			// Response processing could be done by separate goroutine, so that
-			// requests can be executed concurrently.
-			res := <-rc
-
-			if res.err != nil {
+			meta, ok = dp.diskCache.get(entry)
+			if !ok {
+				missing = append(missing, entry)
 				continue
 			}
-			meta = res.meta
-			dp.cleanupMeta(ctx, meta)
+			dp.cleanupMeta(ctx, meta, entry)
 			dp.cacheSetMeta(meta)
 		}
-
 		if hasMatch(meta) {
 			res = append(res, meta)
 		}
 	}
-	close(rc)
+
+	metas, err := dp.loadMetas(ctx, missing)
 	if err != nil {
 		return nil, err
 	}
+	for _, meta := range metas {
+		if hasMatch(meta) {
+			res = append(res, meta)
+		}
+	}
+	dp.diskCache.retain(entries)
 	if dp.next != nil {
 		other, err := dp.next.SelectMeta(ctx, f, nil)
 		if err != nil {
@@ -309,6 +391,63 @@ func (dp *dirPlace) SelectMeta(ctx context.Context, f *place.Filter, s *place.So
 	return place.ApplySorter(res, s), nil
 }
 
+// loadMetas reads the meta data of all given entries from their files. The
+// requests are sent to all file services concurrently, so that they are
+// working in parallel.
+func (dp *dirPlace) loadMetas(ctx context.Context, entries []*directory.Entry) ([]*domain.Meta, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	byZid := make(map[domain.ZettelID]*directory.Entry, len(entries))
+	stamps := make(map[domain.ZettelID][]fileStamp, len(entries))
+	for _, entry := range entries {
+		byZid[entry.Zid] = entry
+		stamps[entry.Zid], _ = entryStamps(entry)
+	}
+
+	// The channel is buffered, so that no file service blocks if the
+	// responses are not read because of a cancelled context.
+	rc := make(chan resGetMeta, len(entries))
+
+	// Every file service gets its own dispatcher, so that a busy file service
+	// does not delay the requests for all other file services.
+	groups := make(map[chan fileCmd][]*directory.Entry, dp.fSrvs)
+	for _, entry := range entries {
+		cc := dp.getFileChan(entry.Zid)
+		groups[cc] = append(groups[cc], entry)
+	}
+	for cc, group := range groups {
+		go func(cc chan<- fileCmd, group []*directory.Entry) {
+			for _, entry := range group {
+				select {
+				case cc <- &fileGetMeta{entry, rc}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(cc, group)
+	}
+
+	result := make([]*domain.Meta, 0, len(entries))
+	for range entries {
+		select {
+		case res := <-rc:
+			if res.err != nil {
+				continue
+			}
+			meta := res.meta
+			entry := byZid[meta.Zid]
+			dp.diskCache.set(entry, stamps[meta.Zid], meta)
+			dp.cleanupMeta(ctx, meta, entry)
+			dp.cacheSetMeta(meta)
+			result = append(result, meta)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return result, nil
+}
+
 func (dp *dirPlace) CanUpdateZettel(ctx context.Context, zettel domain.Zettel) bool {
 	if dp.isStopped() {
 		return false
@@ -320,29 +459,81 @@ func (dp *dirPlace) UpdateZettel(ctx context.Context, zettel domain.Zettel) erro
 	if dp.isStopped() {
 		return place.ErrStopped
 	}
+	dp.mxUpdate.Lock()
+	defer dp.mxUpdate.Unlock()
+	return dp.updateZettel(ctx, zettel)
+}
+
+// GetVersionedZettel retrieves a zettel, together with the version token of
+// its current state.
+func (dp *dirPlace) GetVersionedZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, string, error) {
+	if dp.isStopped() {
+		return domain.Zettel{}, "", place.ErrStopped
+	}
+	if entry := dp.dirSrv.GetEntry(zid); !entry.IsValid() && dp.next != nil {
+		return place.GetVersionedZettel(ctx, dp.next, zid)
+	}
+	zettel, err := dp.GetZettel(ctx, zid)
+	if err != nil {
+		return domain.Zettel{}, "", err
+	}
+	return zettel, place.CalcVersion(zettel), nil
+}
+
+// UpdateVersionedZettel updates a zettel, if it was not changed since the
+// given version was retrieved.
+func (dp *dirPlace) UpdateVersionedZettel(ctx context.Context, zettel domain.Zettel, version string) error {
+	if dp.isStopped() {
+		return place.ErrStopped
+	}
+	dp.mxUpdate.Lock()
+	defer dp.mxUpdate.Unlock()
+	if err := place.CheckVersion(ctx, dp, zettel.Meta.Zid, version); err != nil {
+		return err
+	}
+	return dp.updateZettel(ctx, zettel)
+}
 
+func (dp *dirPlace) updateZettel(ctx context.Context, zettel domain.Zettel) error {
 	meta := zettel.Meta
 	if !meta.Zid.IsValid() {
 		return &place.ErrInvalidID{Zid: meta.Zid}
 	}
 	entry := dp.dirSrv.GetEntry(meta.Zid)
-	if !entry.IsValid() {
+	isNew := !entry.IsValid()
+	if isNew {
 		// Existing zettel, but new in this place.
 		entry.Zid = meta.Zid
 		dp.updateEntryFromMeta(&entry, meta)
 	}
-	dp.notifyChanged(false, meta.Zid)
+
+	if dp.history && entry.IsValid() {
+		rc := make(chan resSaveVersion)
+		dp.getFileChan(meta.Zid) <- &fileSaveVersion{&entry, dp.historyPath(meta.Zid), rc}
+		err := <-rc
+		close(rc)
+		if err != nil {
+			return err
+		}
+	}
 
 	rc := make(chan resSetZettel)
 	dp.getFileChan(meta.Zid) <- &fileSetZettel{&entry, zettel, rc}
 	err := <-rc
 	close(rc)
+	if err == nil {
+		if isNew {
+			dp.dirSrv.UpdateEntry(&entry)
+		}
+		dp.notifyChanged(place.ChangeInfo{Reason: place.OnUpdate, Zid: meta.Zid})
+		dp.commitZettel(ctx, "Update zettel "+meta.Zid.Format(), meta.Zid)
+	}
 	return err
 }
 
 func (dp *dirPlace) updateEntryFromMeta(entry *directory.Entry, meta *domain.Meta) {
 	entry.MetaSpec, entry.ContentExt = calcSpecExt(meta)
-	basePath := filepath.Join(dp.dir, entry.Zid.Format())
+	basePath := filepath.Join(dp.dir, dp.folder, entry.Zid.Format())
 	if entry.MetaSpec == directory.MetaSpecFile {
 		entry.MetaPath = basePath + ".meta"
 	}
@@ -393,7 +584,6 @@ func (dp *dirPlace) RenameZettel(ctx context.Context, curZid, newZid domain.Zett
 			ContentPath: renamePath(curEntry.ContentPath, curZid, newZid),
 			ContentExt:  curEntry.ContentExt,
 		}
-		dp.notifyChanged(false, curZid)
 		if err := dp.dirSrv.RenameEntry(&curEntry, &newEntry); err != nil {
 			return err
 		}
@@ -405,6 +595,14 @@ func (dp *dirPlace) RenameZettel(ctx context.Context, curZid, newZid domain.Zett
 		if err != nil {
 			return err
 		}
+		if dp.history {
+			err = dp.crypt.renameDir(curZid, dp.historyPath(curZid), newZid, dp.historyPath(newZid))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		dp.commitZettel(ctx, "Rename zettel "+curZid.Format()+" to "+newZid.Format(), curZid, newZid)
+		dp.notifyChanged(place.ChangeInfo{Reason: place.OnRename, Zid: curZid, NewZid: newZid})
 	}
 
 	if dp.next != nil {
@@ -429,16 +627,100 @@ func (dp *dirPlace) DeleteZettel(ctx context.Context, zid domain.ZettelID) error
 
 	entry := dp.dirSrv.GetEntry(zid)
 	if !entry.IsValid() {
-		dp.notifyChanged(false, zid)
+		if dp.next != nil {
+			return dp.next.DeleteZettel(ctx, zid)
+		}
+		dp.notifyChanged(place.ChangeInfo{Reason: place.OnDelete, Zid: zid})
 		return nil
 	}
 	dp.dirSrv.DeleteEntry(zid)
-	rc := make(chan resDeleteZettel)
-	dp.getFileChan(zid) <- &fileDeleteZettel{&entry, rc}
-	err := <-rc
-	close(rc)
-	dp.notifyChanged(false, zid)
-	return err
+	var err error
+	if dp.trashDays > 0 {
+		err = dp.trashZettel(ctx, &entry)
+		dp.purgeTrash()
+	} else {
+		rc := make(chan resDeleteZettel)
+		dp.getFileChan(zid) <- &fileDeleteZettel{&entry, rc}
+		err = <-rc
+		close(rc)
+	}
+	if err != nil {
+		return err
+	}
+	dp.commitZettel(ctx, "Delete zettel "+zid.Format(), zid)
+	dp.notifyChanged(place.ChangeInfo{Reason: place.OnDelete, Zid: zid})
+	return nil
+}
+
+// HasHistory returns true, if prior versions are stored in this place or in
+// the next place.
+func (dp *dirPlace) HasHistory() bool {
+	return dp.git != nil || dp.history || place.HasHistory(dp.next)
+}
+
+// GetHistory returns all stored prior versions of a zettel, newest first.
+func (dp *dirPlace) GetHistory(ctx context.Context, zid domain.ZettelID) ([]place.ZettelVersion, error) {
+	if dp.isStopped() {
+		return nil, place.ErrStopped
+	}
+	var versions []place.ZettelVersion
+	if dp.git != nil {
+		var err error
+		entry := dp.dirSrv.GetEntry(zid)
+		if versions, err = dp.git.history(ctx, zid, &entry); err != nil {
+			return nil, err
+		}
+	} else if dp.history {
+		rc := make(chan resGetHistory)
+		dp.getFileChan(zid) <- &fileGetHistory{zid, dp.historyPath(zid), rc}
+		res := <-rc
+		close(rc)
+		if res.err != nil {
+			return nil, res.err
+		}
+		versions = res.versions
+	}
+	if len(versions) == 0 {
+		if h, ok := dp.next.(place.Historian); ok {
+			return h.GetHistory(ctx, zid)
+		}
+	}
+	return versions, nil
+}
+
+// GetZettelVersion retrieves a prior version of a zettel.
+func (dp *dirPlace) GetZettelVersion(ctx context.Context, zid domain.ZettelID, version string) (domain.Zettel, error) {
+	if dp.isStopped() {
+		return domain.Zettel{}, place.ErrStopped
+	}
+	if dp.git != nil {
+		if entry := dp.dirSrv.GetEntry(zid); entry.IsValid() || dp.next == nil {
+			return dp.git.zettelVersion(ctx, zid, version)
+		}
+	}
+	if _, err := time.Parse(versionLayout, version); err != nil {
+		return domain.Zettel{}, &place.ErrUnknownID{Zid: zid}
+	}
+	if dp.history {
+		rc := make(chan resGetMetaContent)
+		dp.getFileChan(zid) <- &fileGetVersion{zid, filepath.Join(dp.historyPath(zid), version), rc}
+		res := <-rc
+		close(rc)
+		if res.err == nil {
+			return domain.Zettel{Meta: res.meta, Content: domain.NewContent(res.content)}, nil
+		}
+		if !os.IsNotExist(res.err) {
+			return domain.Zettel{}, res.err
+		}
+	}
+	if h, ok := dp.next.(place.Historian); ok {
+		return h.GetZettelVersion(ctx, zid, version)
+	}
+	return domain.Zettel{}, &place.ErrUnknownID{Zid: zid}
+}
+
+func (dp *dirPlace) historyPath(zid domain.ZettelID) string {
+	return filepath.Join(dp.dir, historyDir, zid.Format())
 }
 
 // Reload clears all caches, reloads all internal data to reflect changes
@@ -463,13 +745,33 @@ func (dp *dirPlace) Reload(ctx context.Context) error {
 	return err
 }
 
-func (dp *dirPlace) cleanupMeta(ctx context.Context, meta *domain.Meta) {
+func (dp *dirPlace) cleanupMeta(ctx context.Context, meta *domain.Meta, entry *directory.Entry) {
 	if syntax, ok := meta.Get(domain.MetaKeySyntax); !ok || syntax == "" {
 		meta.Set(domain.MetaKeySyntax, config.GetDefaultSyntax())
 	}
 	if role, ok := meta.Get(domain.MetaKeyRole); !ok || role == "" {
 		meta.Set(domain.MetaKeyRole, config.GetDefaultRole())
 	}
+	if folder := dp.entryFolder(entry); folder != "" {
+		meta.Set(domain.MetaKeyFolder, folder)
+	}
+}
+
+// entryFolder returns the subdirectory of the zettel files, relative to the
+// place directory. It is empty for zettel in the place directory.
+func (dp *dirPlace) entryFolder(entry *directory.Entry) string {
+	if !dp.recursive || entry == nil {
+		return ""
+	}
+	path := entry.ContentPath
+	if path == "" {
+		path = entry.MetaPath
+	}
+	folder, err := filepath.Rel(dp.dir, filepath.Dir(path))
+	if err != nil || folder == "." {
+		return ""
+	}
+	return filepath.ToSlash(folder)
 }
 
 func renamePath(path string, curID, newID domain.ZettelID) string {
@@ -487,10 +789,35 @@ func (dp *dirPlace) cacheChange(all bool, zid domain.ZettelID) {
 		dp.metaCache = make(map[domain.ZettelID]*domain.Meta, len(dp.metaCache))
 	} else {
 		delete(dp.metaCache, zid)
+		dp.diskCache.remove(zid)
 	}
 	dp.mxCache.Unlock()
 }
 
+// cacheSaveInterval is the time between two writes of a changed disk cache.
+const cacheSaveInterval = time.Minute
+
+// cacheSaver writes the disk cache periodically, until done is closed. The
+// final write is done when the place is stopped.
+func (dp *dirPlace) cacheSaver(done <-chan struct{}) {
+	tick := time.NewTicker(cacheSaveInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			dp.saveDiskCache()
+		case <-done:
+			return
+		}
+	}
+}
+
+func (dp *dirPlace) saveDiskCache() {
+	if err := dp.diskCache.save(); err != nil {
+		log.Println("Unable to save meta cache:", err)
+	}
+}
+
 func (dp *dirPlace) cacheSetMeta(meta *domain.Meta) {
 	dp.mxCache.Lock()
 	meta.Freeze()
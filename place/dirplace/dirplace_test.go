@@ -0,0 +1,324 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package dirplace provides a directory-based zettel place.
+package dirplace
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+	"zettelstore.de/z/place/dirplace/directory"
+	_ "zettelstore.de/z/place/memplace"
+)
+
+func TestSelectMetaConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	const numZettel = 100
+	for i := 0; i < numZettel; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("%014d.zettel", 20200101000000+i))
+		content := fmt.Sprintf("title: Zettel %d\n\nContent", i)
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := place.Connect("dir://"+dir+"?worker=7&metacache=false", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.SelectMeta(ctx, nil, nil); err != context.Canceled {
+		t.Errorf("Cancelled context expected, but got %v", err)
+	}
+
+	metaList, err := p.SelectMeta(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metaList) != numZettel {
+		t.Errorf("%d zettel expected, but got %d", numZettel, len(metaList))
+	}
+	for i := 1; i < len(metaList); i++ {
+		if metaList[i-1].Zid <= metaList[i].Zid {
+			t.Errorf("Zettel not sorted descending at position %d", i)
+			break
+		}
+	}
+}
+
+func TestLoadMetasBusyService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dp, err := newDirPlace(&url.URL{Scheme: "dir", Path: dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first file service never reads a request, the second one works.
+	dp.fSrvs = 2
+	dp.fCmds = []chan fileCmd{make(chan fileCmd), make(chan fileCmd)}
+	go fileService(1, dp.fCmds[1], nil)
+	defer close(dp.fCmds[1])
+
+	var entries []*directory.Entry
+	busy, working := 0, 0
+	for i := 0; i < 20; i++ {
+		zid := domain.ZettelID(20200101000000 + i)
+		path := filepath.Join(dir, zid.Format()+".zettel")
+		content := fmt.Sprintf("title: Zettel %d\nsyntax: zmk\nrole: zettel\n\nContent", i)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		entry := &directory.Entry{Zid: zid, MetaSpec: directory.MetaSpecHeader, ContentPath: path, ContentExt: "zettel"}
+		if dp.getFileChan(zid) == dp.fCmds[0] {
+			busy++
+		} else {
+			working++
+		}
+		entries = append(entries, entry)
+	}
+	if busy == 0 || working == 0 || dp.getFileChan(entries[0].Zid) != dp.fCmds[0] {
+		t.Skip("Zettel identifier do not cover both file services")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := dp.loadMetas(ctx, entries); err != context.DeadlineExceeded {
+		t.Errorf("Deadline expected, but got %v", err)
+	}
+	found := 0
+	for _, entry := range entries {
+		if _, ok := dp.cacheGetMeta(entry.Zid); ok {
+			found++
+		}
+	}
+	if found != working {
+		t.Errorf("A busy file service must not block the others: %d of %d zettel read", found, working)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	for _, history := range []bool{false, true} {
+		p, err := place.Connect(fmt.Sprintf("dir://%s?history=%v", dir, history), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+		meta := domain.NewMeta(domain.InvalidZettelID)
+		meta.Set(domain.MetaKeyTitle, "First")
+		meta.Set(domain.MetaKeySyntax, "zmk")
+		zid, err := p.CreateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("One")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta = meta.Clone()
+		meta.Zid = zid
+		meta.Set(domain.MetaKeyTitle, "Second")
+		if err := p.UpdateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("Two")}); err != nil {
+			t.Fatal(err)
+		}
+		versions, err := p.(place.Historian).GetHistory(ctx, zid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Stop(ctx)
+		if !history {
+			if len(versions) != 0 {
+				t.Errorf("No versions expected without history, but got %v", versions)
+			}
+			continue
+		}
+		if len(versions) != 1 {
+			t.Fatalf("One version expected, but got %v", versions)
+		}
+		if got := versions[0].Meta.GetDefault(domain.MetaKeyTitle, ""); got != "First" {
+			t.Errorf("Title %q of version expected, but got %q", "First", got)
+		}
+	}
+}
+
+func TestUpdateNotifiesAfterWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	p, err := place.Connect("dir://"+dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop(ctx)
+	meta := domain.NewMeta(domain.InvalidZettelID)
+	meta.Set(domain.MetaKeySyntax, "zmk")
+	meta.Set(domain.MetaKeyRole, "zettel")
+	zid, err := p.CreateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("Old")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen string
+	p.RegisterChangeObserver(func(ci place.ChangeInfo) {
+		if ci.Reason == place.OnUpdate && ci.Zid == zid {
+			if zettel, err := p.GetZettel(ctx, zid); err == nil {
+				seen = zettel.Content.AsString()
+			}
+		}
+	})
+	meta = meta.Clone()
+	meta.Zid = zid
+	if err := p.UpdateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("New")}); err != nil {
+		t.Fatal(err)
+	}
+	if seen != "New" {
+		t.Errorf("Observer should see the updated content %q, but got %q", "New", seen)
+	}
+}
+
+func TestRenameDeleteNotifyAfterSuccess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	p, err := place.Connect("dir://"+dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop(ctx)
+	var zids []domain.ZettelID
+	for i := 0; i < 2; i++ {
+		meta := domain.NewMeta(domain.InvalidZettelID)
+		meta.Set(domain.MetaKeySyntax, "zmk")
+		meta.Set(domain.MetaKeyRole, "zettel")
+		zid, err := p.CreateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		zids = append(zids, zid)
+	}
+
+	var infos []place.ChangeInfo
+	var readable []bool
+	p.RegisterChangeObserver(func(ci place.ChangeInfo) {
+		infos = append(infos, ci)
+		zid := ci.Zid
+		if ci.Reason == place.OnRename {
+			zid = ci.NewZid
+		}
+		_, err := p.GetMeta(ctx, zid)
+		readable = append(readable, err == nil)
+	})
+	if err := p.RenameZettel(ctx, zids[0], zids[1]); err == nil {
+		t.Fatalf("Renaming %v to existing %v must fail", zids[0], zids[1])
+	}
+	if len(infos) != 0 {
+		t.Fatalf("Failed rename must not notify, but got %v", infos)
+	}
+	newZid := zids[1] + 1
+	if err := p.RenameZettel(ctx, zids[0], newZid); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.DeleteZettel(ctx, zids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].Reason != place.OnRename || infos[1].Reason != place.OnDelete {
+		t.Fatalf("Expected a rename and a delete notification, but got %v", infos)
+	}
+	if !readable[0] {
+		t.Errorf("Observer should read renamed zettel %v", newZid)
+	}
+	if readable[1] {
+		t.Errorf("Observer should not read deleted zettel %v", zids[1])
+	}
+}
+
+func TestDeleteNext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	next, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := place.Connect("dir://"+dir, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop(ctx)
+	meta := domain.NewMeta(domain.InvalidZettelID)
+	meta.Set(domain.MetaKeyTitle, "Next")
+	zid, err := next.CreateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.CanDeleteZettel(ctx, zid) {
+		t.Errorf("Zettel %v of next place should be deletable", zid)
+	}
+	if err := p.DeleteZettel(ctx, zid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := next.GetMeta(ctx, zid); err == nil {
+		t.Errorf("Zettel %v was not deleted in next place", zid)
+	}
+}
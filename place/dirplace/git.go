@@ -0,0 +1,378 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package dirplace provides a directory-based zettel place.
+package dirplace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/input"
+	"zettelstore.de/z/place"
+	"zettelstore.de/z/place/dirplace/directory"
+)
+
+func init() {
+	place.Register("git", func(u *url.URL, next place.Place) (place.Place, error) {
+		dp, err := newDirPlace(u, next)
+		if err != nil {
+			return nil, err
+		}
+		if dp.encrypt {
+			// All zettel that were committed before the place was encrypted
+			// would stay readable in the history of the repository.
+			return nil, errGitEncrypt
+		}
+		dp.git = &gitRepo{dir: dp.dir}
+		return dp, nil
+	})
+}
+
+var errGitEncrypt = errors.New("git place cannot be encrypted")
+
+// gitExcludes returns the patterns of all files that are used by the place
+// itself, but must not be committed. The prefix is the path of the place
+// directory, relative to the root of the git repository.
+func gitExcludes(prefix string) []string {
+	return []string{
+		"/" + prefix + historyDir + "/",
+		"/" + prefix + trashDir + "/",
+		"/" + prefix + metaCacheName + "*",
+	}
+}
+
+// gitRepo stores all changes of zettel in a local git repository. Only local
+// git commands are used, nothing is ever fetched from or pushed to a remote.
+type gitRepo struct {
+	dir      string
+	mx       sync.Mutex
+	identity []string // Committer, if git is not configured
+}
+
+// reCommit matches a full commit hash, the version of a zettel.
+var reCommit = regexp.MustCompile("^[0-9a-f]{40}$")
+
+func (gr *gitRepo) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", gr.dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	return cmd
+}
+
+func (gr *gitRepo) run(ctx context.Context, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := gr.command(ctx, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// open makes sure that the place directory is part of a git repository and
+// that all internal files of the place are ignored.
+func (gr *gitRepo) open(ctx context.Context) error {
+	gr.mx.Lock()
+	defer gr.mx.Unlock()
+	created := false
+	if _, err := gr.run(ctx, "rev-parse", "--git-dir"); err != nil {
+		if _, err := gr.run(ctx, "init", "--quiet"); err != nil {
+			return err
+		}
+		created = true
+	}
+	if out, err := gr.run(ctx, "config", "user.email"); err != nil || len(bytes.TrimSpace(out)) == 0 {
+		gr.identity = []string{"-c", "user.name=Zettelstore", "-c", "user.email=zettelstore@localhost"}
+	}
+	if err := gr.exclude(ctx); err != nil {
+		return err
+	}
+	if !created {
+		return nil
+	}
+
+	// Existing zettel are the base of all further changes.
+	if _, err := gr.run(ctx, "add", "--all"); err != nil {
+		return err
+	}
+	return gr.commitStaged("Import zettel\n")
+}
+
+// exclude adds all internal files of the place to the list of files that
+// are ignored by git.
+func (gr *gitRepo) exclude(ctx context.Context) error {
+	out, err := gr.run(ctx, "rev-parse", "--show-prefix")
+	if err != nil {
+		return err
+	}
+	prefix := strings.TrimSpace(string(out))
+	out, err = gr.run(ctx, "rev-parse", "--git-path", "info/exclude")
+	if err != nil {
+		return err
+	}
+	excludePath := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(excludePath) {
+		excludePath = filepath.Join(gr.dir, excludePath)
+	}
+	data, err := ioutil.ReadFile(excludePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	var missing []string
+	for _, pattern := range gitExcludes(prefix) {
+		if !containsLine(lines, pattern) {
+			missing = append(missing, pattern)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(excludePath), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(excludePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		missing[0] = "\n" + missing[0]
+	}
+	_, err = f.WriteString(strings.Join(missing, "\n") + "\n")
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+func containsLine(lines []string, line string) bool {
+	for _, l := range lines {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+	return false
+}
+
+// zettelSpec returns a pathspec that matches all files of a zettel, even if
+// they are stored in a subdirectory.
+func zettelSpec(zid domain.ZettelID) string {
+	return ":(glob)**/" + zid.Format() + "*"
+}
+
+// commit records the current state of all files of the given zettel.
+func (gr *gitRepo) commit(ctx context.Context, message string, zids ...domain.ZettelID) error {
+	specs := make([]string, 0, len(zids))
+	for _, zid := range zids {
+		specs = append(specs, zettelSpec(zid))
+	}
+	ident := "anonymous"
+	if user := place.GetUser(ctx); user != nil {
+		ident = user.GetDefault(domain.MetaKeyIdent, user.Zid.Format())
+	}
+	message += "\n\nUser: " + ident + "\n"
+
+	// The request context may be cancelled, but the commit must be completed.
+	bg := context.Background()
+	gr.mx.Lock()
+	defer gr.mx.Unlock()
+	paths, err := gr.changedPaths(bg, specs)
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+	if _, err := gr.run(bg, append([]string{"add", "--all", "--"}, paths...)...); err != nil {
+		return err
+	}
+	return gr.commitStaged(message)
+}
+
+// changedPaths returns the paths of all files that match one of the given
+// pathspecs and differ from the last commit. A pathspec that matches no file
+// at all is not an error, as it would be for "git add".
+func (gr *gitRepo) changedPaths(ctx context.Context, specs []string) ([]string, error) {
+	out, err := gr.run(ctx, append([]string{"status", "--porcelain", "-z", "--untracked-files=all", "--"}, specs...)...)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i < len(fields); i++ {
+		if len(fields[i]) < 4 {
+			continue
+		}
+		paths = append(paths, ":(top,literal)"+fields[i][3:])
+		if st := fields[i][0]; (st == 'R' || st == 'C') && i+1 < len(fields) {
+			// Renamed or copied files report their source as an extra field.
+			i++
+			paths = append(paths, ":(top,literal)"+fields[i])
+		}
+	}
+	return paths, nil
+}
+
+// commitStaged commits all staged changes, if there are some.
+func (gr *gitRepo) commitStaged(message string) error {
+	if err := gr.command(context.Background(), "diff", "--cached", "--quiet").Run(); err == nil {
+		// Nothing was changed
+		return nil
+	}
+	args := append(append([]string{}, gr.identity...), "commit", "--quiet", "--no-verify", "-m", message)
+	_, err := gr.run(context.Background(), args...)
+	return err
+}
+
+// commitZettel commits the changes of one or more zettel, if the place is
+// backed by git. Errors are only logged, because the zettel itself was
+// successfully changed.
+func (dp *dirPlace) commitZettel(ctx context.Context, message string, zids ...domain.ZettelID) {
+	if dp.git == nil {
+		return
+	}
+	if err := dp.git.commit(ctx, message, zids...); err != nil {
+		log.Println("Unable to commit zettel:", err)
+	}
+}
+
+// history returns all commits that changed the given zettel, newest first.
+// The meta data of each version is read from the files of the given entry, if
+// the commit contains them.
+func (gr *gitRepo) history(
+	ctx context.Context, zid domain.ZettelID, entry *directory.Entry,
+) ([]place.ZettelVersion, error) {
+	if _, err := gr.run(ctx, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		// No commit so far
+		return nil, nil
+	}
+	out, err := gr.run(ctx, "log", "--format=%H %ct", "--", zettelSpec(zid))
+	if err != nil {
+		return nil, err
+	}
+	var versions []place.ZettelVersion
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		sec, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, place.ZettelVersion{
+			Version: fields[0],
+			Time:    time.Unix(sec, 0),
+			Meta:    gr.versionMeta(ctx, zid, fields[0], entry),
+		})
+	}
+	return versions, nil
+}
+
+// versionMeta returns the meta data of the zettel as it was stored in the
+// given commit, or nil if it is not available. Only the file that contains
+// the meta data is read.
+func (gr *gitRepo) versionMeta(
+	ctx context.Context, zid domain.ZettelID, version string, entry *directory.Entry,
+) *domain.Meta {
+	var path string
+	switch entry.MetaSpec {
+	case directory.MetaSpecFile:
+		path = entry.MetaPath
+	case directory.MetaSpecHeader:
+		path = entry.ContentPath
+	default:
+		return nil
+	}
+	rel, err := filepath.Rel(gr.dir, path)
+	if err != nil {
+		return nil
+	}
+	src, err := gr.show(ctx, version, filepath.ToSlash(rel))
+	if err != nil {
+		return nil
+	}
+	return domain.NewMetaFromInput(zid, input.NewInput(src))
+}
+
+// zettelVersion returns the zettel as it was stored in the given commit.
+func (gr *gitRepo) zettelVersion(
+	ctx context.Context, zid domain.ZettelID, version string,
+) (domain.Zettel, error) {
+	if !reCommit.MatchString(version) {
+		return domain.Zettel{}, &place.ErrUnknownID{Zid: zid}
+	}
+	// "git ls-tree" does not support glob pathspecs.
+	out, err := gr.run(ctx, "ls-tree", "-r", "--name-only", version)
+	if err != nil {
+		return domain.Zettel{}, err
+	}
+	entry := directory.Entry{Zid: zid}
+	for _, name := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		match := directory.MatchValidFileName(path.Base(name))
+		if len(match) == 0 || match[1] != zid.Format() {
+			continue
+		}
+		entry.AddFile(name, match[3])
+	}
+	if entry.ContentPath == "" {
+		return domain.Zettel{}, &place.ErrUnknownID{Zid: zid}
+	}
+
+	var meta *domain.Meta
+	content, err := gr.show(ctx, version, entry.ContentPath)
+	if err != nil {
+		return domain.Zettel{}, err
+	}
+	switch entry.MetaSpec {
+	case directory.MetaSpecFile:
+		metaSrc, err := gr.show(ctx, version, entry.MetaPath)
+		if err != nil {
+			return domain.Zettel{}, err
+		}
+		meta = domain.NewMetaFromInput(zid, input.NewInput(metaSrc))
+	case directory.MetaSpecHeader:
+		inp := input.NewInput(content)
+		meta = domain.NewMetaFromInput(zid, inp)
+		content = content[inp.Pos:]
+	default:
+		meta = entry.CalcMeta()
+	}
+	cleanupMeta(meta, &entry)
+	return domain.Zettel{Meta: meta, Content: domain.NewContent(content)}, nil
+}
+
+// show returns the content of a file, as it was stored in the given commit.
+func (gr *gitRepo) show(ctx context.Context, version, path string) (string, error) {
+	data, err := gr.run(ctx, "show", version+":./"+path)
+	return string(data), err
+}
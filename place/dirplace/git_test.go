@@ -0,0 +1,123 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package dirplace provides a directory-based zettel place.
+package dirplace
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+func TestGitPlace(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir, err := ioutil.TempDir("", "gitplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, err := place.Connect("git://"+dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop(ctx)
+
+	meta := domain.NewMeta(domain.InvalidZettelID)
+	meta.Set(domain.MetaKeyTitle, "First")
+	meta.Set(domain.MetaKeySyntax, "zmk")
+	zid, err := p.CreateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("One")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta = meta.Clone()
+	meta.Zid = zid
+	meta.Set(domain.MetaKeyTitle, "Second")
+	if err := p.UpdateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("Two")}); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := p.(place.Historian).GetHistory(ctx, zid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Two versions expected, but got %v", versions)
+	}
+	if got := versions[1].Meta.GetDefault(domain.MetaKeyTitle, ""); got != "First" {
+		t.Errorf("Title %q of version expected, but got %q", "First", got)
+	}
+	zettel, err := p.(place.Historian).GetZettelVersion(ctx, zid, versions[1].Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := zettel.Meta.GetDefault(domain.MetaKeyTitle, ""); got != "First" {
+		t.Errorf("Title %q expected, but got %q", "First", got)
+	}
+	if got := zettel.Content.AsString(); got != "One" {
+		t.Errorf("Content %q expected, but got %q", "One", got)
+	}
+	if _, err := p.(place.Historian).GetZettelVersion(ctx, zid, "HEAD"); err == nil {
+		t.Error("Error expected for invalid version")
+	}
+}
+
+func TestGitExcludeSubdir(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir, err := ioutil.TempDir("", "gitplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	if _, err := (&gitRepo{dir: dir}).run(ctx, "init", "--quiet"); err != nil {
+		t.Fatal(err)
+	}
+	placeDir := filepath.Join(dir, "zettel")
+	if err := os.Mkdir(placeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	gr := &gitRepo{dir: placeDir}
+	if err := gr.open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{metaCacheName, historyDir + "/x", trashDir + "/x"} {
+		if _, err := gr.run(ctx, "check-ignore", "--quiet", "--no-index", name); err != nil {
+			t.Errorf("%q must be ignored: %v", name, err)
+		}
+	}
+	if _, err := gr.run(ctx, "check-ignore", "--quiet", "--no-index", "12345678901234.zettel"); err == nil {
+		t.Error("Zettel file must not be ignored")
+	}
+}
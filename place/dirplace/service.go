@@ -21,23 +21,30 @@
 package dirplace
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"zettelstore.de/z/domain"
 	"zettelstore.de/z/input"
+	"zettelstore.de/z/place"
 	"zettelstore.de/z/place/dirplace/directory"
 )
 
-func fileService(num uint32, cmds <-chan fileCmd) {
+// fileService executes all file commands. If c is not nil, all zettel files
+// are encrypted.
+func fileService(num uint32, cmds <-chan fileCmd, c *crypter) {
 	for cmd := range cmds {
-		cmd.run()
+		cmd.run(c)
 	}
 }
 
 type fileCmd interface {
-	run()
+	run(c *crypter)
 }
 
 // COMMAND: getMeta ----------------------------------------
@@ -53,16 +60,16 @@ type resGetMeta struct {
 	err  error
 }
 
-func (cmd *fileGetMeta) run() {
+func (cmd *fileGetMeta) run(c *crypter) {
 	var meta *domain.Meta
 	var err error
 	switch cmd.entry.MetaSpec {
 	case directory.MetaSpecFile:
-		meta, err = parseMetaFile(cmd.entry.Zid, cmd.entry.MetaPath)
+		meta, err = parseMetaFile(c, cmd.entry.Zid, cmd.entry.MetaPath)
 	case directory.MetaSpecHeader:
-		meta, _, err = parseMetaContentFile(cmd.entry.Zid, cmd.entry.ContentPath)
+		meta, _, err = parseMetaContentFile(c, cmd.entry.Zid, cmd.entry.ContentPath)
 	default:
-		meta = calculateMeta(cmd.entry)
+		meta = cmd.entry.CalcMeta()
 	}
 	if err == nil {
 		cleanupMeta(meta, cmd.entry)
@@ -84,20 +91,22 @@ type resGetMetaContent struct {
 	err     error
 }
 
-func (cmd *fileGetMetaContent) run() {
+func (cmd *fileGetMetaContent) run(c *crypter) {
 	var meta *domain.Meta
 	var content string
 	var err error
 
 	switch cmd.entry.MetaSpec {
 	case directory.MetaSpecFile:
-		meta, err = parseMetaFile(cmd.entry.Zid, cmd.entry.MetaPath)
-		content, err = readFileContent(cmd.entry.ContentPath)
+		meta, err = parseMetaFile(c, cmd.entry.Zid, cmd.entry.MetaPath)
+		if err == nil {
+			content, err = c.readFile(cmd.entry.Zid, cmd.entry.ContentPath)
+		}
 	case directory.MetaSpecHeader:
-		meta, content, err = parseMetaContentFile(cmd.entry.Zid, cmd.entry.ContentPath)
+		meta, content, err = parseMetaContentFile(c, cmd.entry.Zid, cmd.entry.ContentPath)
 	default:
-		meta = calculateMeta(cmd.entry)
-		content, err = readFileContent(cmd.entry.ContentPath)
+		meta = cmd.entry.CalcMeta()
+		content, err = c.readFile(cmd.entry.Zid, cmd.entry.ContentPath)
 	}
 	if err == nil {
 		cleanupMeta(meta, cmd.entry)
@@ -116,40 +125,31 @@ type fileSetZettel struct {
 }
 type resSetZettel = error
 
-func (cmd *fileSetZettel) run() {
-	var f *os.File
+func (cmd *fileSetZettel) run(c *crypter) {
+	var buf bytes.Buffer
 	var err error
 
 	switch cmd.entry.MetaSpec {
 	case directory.MetaSpecFile:
-		f, err = openFileWrite(cmd.entry.MetaPath)
+		_, err = cmd.zettel.Meta.Write(&buf)
 		if err == nil {
-			_, err = cmd.zettel.Meta.Write(f)
-			if err1 := f.Close(); err == nil {
-				err = err1
-			}
-
-			if err == nil {
-				err = writeFileContent(cmd.entry.ContentPath, cmd.zettel.Content.AsString())
-			}
+			err = c.writeFile(cmd.entry.Zid, cmd.entry.MetaPath, buf.Bytes())
+		}
+		if err == nil {
+			err = c.writeFile(cmd.entry.Zid, cmd.entry.ContentPath, cmd.zettel.Content.AsBytes())
 		}
 
 	case directory.MetaSpecHeader:
-		f, err = openFileWrite(cmd.entry.ContentPath)
+		_, err = cmd.zettel.Meta.WriteAsHeader(&buf)
 		if err == nil {
-			_, err = cmd.zettel.Meta.WriteAsHeader(f)
-			if err == nil {
-				_, err = f.WriteString(cmd.zettel.Content.AsString())
-				if err1 := f.Close(); err == nil {
-					err = err1
-				}
-			}
+			buf.WriteString(cmd.zettel.Content.AsString())
+			err = c.writeFile(cmd.entry.Zid, cmd.entry.ContentPath, buf.Bytes())
 		}
 
 	case directory.MetaSpecNone:
 		// TODO: if meta has some additional infos: write meta to new .meta; update entry in dir
 
-		err = writeFileContent(cmd.entry.ContentPath, cmd.zettel.Content.AsString())
+		err = c.writeFile(cmd.entry.Zid, cmd.entry.ContentPath, cmd.zettel.Content.AsBytes())
 
 	case directory.MetaSpecUnknown:
 		panic("TODO: ???")
@@ -157,6 +157,105 @@ func (cmd *fileSetZettel) run() {
 	cmd.rc <- err
 }
 
+// COMMAND: saveVersion ----------------------------------------
+//
+// Stores the current version of a zettel in the history directory.
+
+type fileSaveVersion struct {
+	entry   *directory.Entry
+	histDir string
+	rc      chan<- resSaveVersion
+}
+type resSaveVersion = error
+
+func (cmd *fileSaveVersion) run(c *crypter) {
+	rc := make(chan resGetMetaContent, 1)
+	(&fileGetMetaContent{cmd.entry, rc}).run(c)
+	res := <-rc
+	if res.err != nil {
+		if os.IsNotExist(res.err) {
+			res.err = nil
+		}
+		cmd.rc <- res.err
+		return
+	}
+	err := os.MkdirAll(cmd.histDir, 0755)
+	if err == nil {
+		basePath := filepath.Join(cmd.histDir, time.Now().UTC().Format(versionLayout))
+		var buf bytes.Buffer
+		_, err = res.meta.Write(&buf)
+		if err == nil {
+			err = c.writeFile(cmd.entry.Zid, basePath+".meta", buf.Bytes())
+		}
+		if err == nil {
+			err = c.writeFile(cmd.entry.Zid, basePath+".content", []byte(res.content))
+		}
+	}
+	cmd.rc <- err
+}
+
+// COMMAND: getHistory ----------------------------------------
+//
+// Lists all stored versions of a zettel.
+
+type fileGetHistory struct {
+	zid     domain.ZettelID
+	histDir string
+	rc      chan<- resGetHistory
+}
+type resGetHistory struct {
+	versions []place.ZettelVersion
+	err      error
+}
+
+func (cmd *fileGetHistory) run(c *crypter) {
+	infos, err := ioutil.ReadDir(cmd.histDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		cmd.rc <- resGetHistory{nil, err}
+		return
+	}
+	versions := make([]place.ZettelVersion, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		if !strings.HasSuffix(name, ".meta") {
+			continue
+		}
+		ver := name[:len(name)-len(".meta")]
+		if t, err := time.Parse(versionLayout, ver); err == nil {
+			// Only the meta file is read, the content is not needed.
+			meta, err := parseMetaFile(c, cmd.zid, filepath.Join(cmd.histDir, name))
+			if err != nil {
+				meta = nil
+			}
+			versions = append(versions, place.ZettelVersion{Version: ver, Time: t, Meta: meta})
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version > versions[j].Version })
+	cmd.rc <- resGetHistory{versions, nil}
+}
+
+// COMMAND: getVersion ----------------------------------------
+//
+// Retrieves a stored version of a zettel.
+
+type fileGetVersion struct {
+	zid      domain.ZettelID
+	basePath string
+	rc       chan<- resGetMetaContent
+}
+
+func (cmd *fileGetVersion) run(c *crypter) {
+	meta, err := parseMetaFile(c, cmd.zid, cmd.basePath+".meta")
+	var content string
+	if err == nil {
+		content, err = c.readFile(cmd.zid, cmd.basePath+".content")
+	}
+	cmd.rc <- resGetMetaContent{meta, content, err}
+}
+
 // COMMAND: renameZettel ----------------------------------------
 //
 // Gives an existing zettel a new id.
@@ -169,18 +268,19 @@ type fileRenameZettel struct {
 
 type resRenameZettel = error
 
-func (cmd *fileRenameZettel) run() {
+func (cmd *fileRenameZettel) run(c *crypter) {
 	var err error
 
+	curZid, newZid := cmd.curEntry.Zid, cmd.newEntry.Zid
 	switch cmd.curEntry.MetaSpec {
 	case directory.MetaSpecFile:
-		err1 := os.Rename(cmd.curEntry.MetaPath, cmd.newEntry.MetaPath)
-		err = os.Rename(cmd.curEntry.ContentPath, cmd.newEntry.ContentPath)
+		err1 := c.renameFile(curZid, cmd.curEntry.MetaPath, newZid, cmd.newEntry.MetaPath)
+		err = c.renameFile(curZid, cmd.curEntry.ContentPath, newZid, cmd.newEntry.ContentPath)
 		if err == nil {
 			err = err1
 		}
 	case directory.MetaSpecHeader, directory.MetaSpecNone:
-		err = os.Rename(cmd.curEntry.ContentPath, cmd.newEntry.ContentPath)
+		err = c.renameFile(curZid, cmd.curEntry.ContentPath, newZid, cmd.newEntry.ContentPath)
 	case directory.MetaSpecUnknown:
 		panic("TODO: ???")
 	}
@@ -197,7 +297,7 @@ type fileDeleteZettel struct {
 }
 type resDeleteZettel = error
 
-func (cmd *fileDeleteZettel) run() {
+func (cmd *fileDeleteZettel) run(c *crypter) {
 	var err error
 
 	switch cmd.entry.MetaSpec {
@@ -217,18 +317,82 @@ func (cmd *fileDeleteZettel) run() {
 	cmd.rc <- err
 }
 
-// Utility functions ----------------------------------------
+// COMMAND: trashZettel ----------------------------------------
+//
+// Moves an existing zettel into the trash.
+
+type fileTrashZettel struct {
+	entry     *directory.Entry
+	trashPath string
+	info      *domain.Meta
+	rc        chan<- resTrashZettel
+}
+type resTrashZettel = error
+
+func (cmd *fileTrashZettel) run(c *crypter) {
+	err := os.RemoveAll(cmd.trashPath)
+	if err == nil {
+		err = os.MkdirAll(cmd.trashPath, 0755)
+	}
+	if err == nil && cmd.entry.MetaSpec == directory.MetaSpecFile {
+		err = moveFile(cmd.entry.MetaPath, cmd.trashPath)
+	}
+	if err == nil {
+		err = moveFile(cmd.entry.ContentPath, cmd.trashPath)
+	}
+	if err == nil {
+		var buf bytes.Buffer
+		if _, err = cmd.info.Write(&buf); err == nil {
+			err = c.writeFile(cmd.entry.Zid, filepath.Join(cmd.trashPath, trashInfoName), buf.Bytes())
+		}
+	}
+	cmd.rc <- err
+}
+
+// COMMAND: restoreZettel ----------------------------------------
+//
+// Moves a zettel from the trash back into the directory.
+
+type fileRestoreZettel struct {
+	zid       domain.ZettelID
+	trashPath string
+	dir       string
+	rc        chan<- resRestoreZettel
+}
+type resRestoreZettel struct {
+	entry directory.Entry
+	err   error
+}
 
-func readFileContent(path string) (string, error) {
-	data, err := ioutil.ReadFile(path)
+func (cmd *fileRestoreZettel) run(c *crypter) {
+	entry, err := buildEntry(cmd.zid, cmd.trashPath)
 	if err != nil {
-		return "", err
+		cmd.rc <- resRestoreZettel{entry, err}
+		return
+	}
+	err = os.MkdirAll(cmd.dir, 0755)
+	if err == nil && entry.MetaSpec == directory.MetaSpecFile {
+		err = moveFile(entry.MetaPath, cmd.dir)
+		entry.MetaPath = filepath.Join(cmd.dir, filepath.Base(entry.MetaPath))
+	}
+	if err == nil {
+		err = moveFile(entry.ContentPath, cmd.dir)
+		entry.ContentPath = filepath.Join(cmd.dir, filepath.Base(entry.ContentPath))
+	}
+	if err == nil {
+		err = os.RemoveAll(cmd.trashPath)
 	}
-	return string(data), nil
+	cmd.rc <- resRestoreZettel{entry, err}
 }
 
-func parseMetaFile(zid domain.ZettelID, path string) (*domain.Meta, error) {
-	src, err := readFileContent(path)
+// Utility functions ----------------------------------------
+
+func moveFile(path, dir string) error {
+	return os.Rename(path, filepath.Join(dir, filepath.Base(path)))
+}
+
+func parseMetaFile(c *crypter, zid domain.ZettelID, path string) (*domain.Meta, error) {
+	src, err := c.readFile(zid, path)
 	if err != nil {
 		return nil, err
 	}
@@ -236,8 +400,8 @@ func parseMetaFile(zid domain.ZettelID, path string) (*domain.Meta, error) {
 	return domain.NewMetaFromInput(zid, inp), nil
 }
 
-func parseMetaContentFile(zid domain.ZettelID, path string) (*domain.Meta, string, error) {
-	src, err := readFileContent(path)
+func parseMetaContentFile(c *crypter, zid domain.ZettelID, path string) (*domain.Meta, string, error) {
+	src, err := c.readFile(zid, path)
 	if err != nil {
 		return nil, "", err
 	}
@@ -247,53 +411,26 @@ func parseMetaContentFile(zid domain.ZettelID, path string) (*domain.Meta, strin
 }
 
 func cleanupMeta(meta *domain.Meta, entry *directory.Entry) {
-	if title, ok := meta.Get(domain.MetaKeyTitle); !ok || title == "" {
-		meta.Set(domain.MetaKeyTitle, entry.Zid.Format())
-	}
-
-	switch entry.MetaSpec {
-	case directory.MetaSpecFile:
-		if syntax, ok := meta.Get(domain.MetaKeySyntax); !ok || syntax == "" {
-			meta.Set(domain.MetaKeySyntax, calculateSyntax(entry))
-		}
-	}
-
-	if entry.Duplicates {
-		meta.Set("duplicates", "yes")
-	}
+	modified, _ := entryModified(entry)
+	entry.CleanupMeta(meta, modified)
 }
 
-var alternativeSyntax = map[string]string{
-	"htm":  "html",
-	"tmpl": "go-template-html",
-}
-
-func calculateSyntax(entry *directory.Entry) string {
-	ext := strings.ToLower(entry.ContentExt)
-	if syntax, ok := alternativeSyntax[ext]; ok {
-		return syntax
+// entryModified returns the latest modification time of the files of the
+// given entry.
+func entryModified(entry *directory.Entry) (time.Time, bool) {
+	stamps, ok := entryStamps(entry)
+	if !ok {
+		return time.Time{}, false
 	}
-	return ext
-}
-
-func calculateMeta(entry *directory.Entry) *domain.Meta {
-	meta := domain.NewMeta(entry.Zid)
-	meta.Set(domain.MetaKeyTitle, entry.Zid.Format())
-	meta.Set(domain.MetaKeySyntax, calculateSyntax(entry))
-	return meta
+	var modified int64
+	for _, stamp := range stamps {
+		if stamp.ModTime > modified {
+			modified = stamp.ModTime
+		}
+	}
+	return time.Unix(0, modified), true
 }
 
 func openFileWrite(path string) (*os.File, error) {
 	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 }
-
-func writeFileContent(path string, content string) error {
-	f, err := openFileWrite(path)
-	if err == nil {
-		_, err = f.WriteString(content)
-		if err1 := f.Close(); err == nil {
-			err = err1
-		}
-	}
-	return err
-}
@@ -0,0 +1,287 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package dirplace provides a directory-based zettel place.
+package dirplace
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+	"zettelstore.de/z/place/dirplace/directory"
+)
+
+const (
+	// trashDir is the directory, relative to the place directory, where
+	// deleted zettel are moved to.
+	trashDir = ".trash"
+
+	// trashInfoName is the name of the file that describes a deleted zettel.
+	trashInfoName = "deleted.info"
+
+	// Keys of the trash info file.
+	trashKeyDeleted   = "deleted"
+	trashKeyDeletedBy = "deleted-by"
+	trashKeyFolder    = "deleted-from"
+
+	trashTimeLayout = "20060102150405"
+)
+
+func (dp *dirPlace) trashPath(zid domain.ZettelID) string {
+	return filepath.Join(dp.dir, trashDir, zid.Format())
+}
+
+// trashZettel moves the zettel of the given entry into the trash.
+func (dp *dirPlace) trashZettel(ctx context.Context, entry *directory.Entry) error {
+	info := domain.NewMeta(entry.Zid)
+	info.Set(trashKeyDeleted, time.Now().UTC().Format(trashTimeLayout))
+	if user := place.GetUser(ctx); user != nil {
+		info.Set(trashKeyDeletedBy, user.GetDefault(domain.MetaKeyIdent, user.Zid.Format()))
+	}
+	if folder := dp.entryFolder(entry); folder != "" {
+		info.Set(trashKeyFolder, folder)
+	}
+	rc := make(chan resTrashZettel)
+	dp.getFileChan(entry.Zid) <- &fileTrashZettel{entry, dp.trashPath(entry.Zid), info, rc}
+	err := <-rc
+	close(rc)
+	return err
+}
+
+// HasTrash returns true, if this place or the next place has a trash.
+func (dp *dirPlace) HasTrash() bool {
+	return dp.trashDays > 0 || place.HasTrash(dp.next)
+}
+
+// CanTrashZettel returns true, if deleting the zettel moves it into the
+// trash.
+func (dp *dirPlace) CanTrashZettel(ctx context.Context, zid domain.ZettelID) bool {
+	if dp.isStopped() {
+		return false
+	}
+	if entry := dp.dirSrv.GetEntry(zid); entry.IsValid() {
+		return dp.trashDays > 0
+	}
+	return place.CanTrashZettel(ctx, dp.next, zid)
+}
+
+// GetTrash returns all deleted zettel that are still in the trash, most
+// recently deleted first.
+func (dp *dirPlace) GetTrash(ctx context.Context) ([]place.TrashEntry, error) {
+	if dp.isStopped() {
+		return nil, place.ErrStopped
+	}
+	var result []place.TrashEntry
+	if dp.trashDays > 0 {
+		dp.purgeTrash()
+		entries, err := dp.readTrash()
+		if err != nil {
+			return nil, err
+		}
+		rc := make(chan resGetMeta)
+		for _, te := range entries {
+			entry, err := buildEntry(te.zid, dp.trashPath(te.zid))
+			if err != nil {
+				continue
+			}
+			dp.getFileChan(te.zid) <- &fileGetMeta{&entry, rc}
+			res := <-rc
+			if res.err != nil {
+				continue
+			}
+			dp.cleanupMeta(ctx, res.meta, nil)
+			if te.folder != "" {
+				res.meta.Set(domain.MetaKeyFolder, te.folder)
+			}
+			result = append(result, place.TrashEntry{
+				Meta:      res.meta,
+				Deleted:   te.deleted,
+				DeletedBy: te.deletedBy,
+			})
+		}
+		close(rc)
+	}
+	if t, ok := dp.next.(place.Trash); ok {
+		other, err := t.GetTrash(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, other...)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Deleted.After(result[j].Deleted) })
+	return result, nil
+}
+
+// RestoreZettel moves a deleted zettel from the trash back to its original
+// zettel id.
+func (dp *dirPlace) RestoreZettel(ctx context.Context, zid domain.ZettelID) error {
+	if dp.isStopped() {
+		return place.ErrStopped
+	}
+	trashPath := dp.trashPath(zid)
+	info, err := parseMetaFile(dp.crypt, zid, filepath.Join(trashPath, trashInfoName))
+	if dp.trashDays <= 0 || err != nil {
+		if t, ok := dp.next.(place.Trash); ok {
+			return t.RestoreZettel(ctx, zid)
+		}
+		return &place.ErrUnknownID{Zid: zid}
+	}
+	if entry := dp.dirSrv.GetEntry(zid); entry.IsValid() {
+		return &place.ErrInvalidID{Zid: zid}
+	}
+
+	dir := dp.dir
+	if folder, ok := info.Get(trashKeyFolder); ok && dp.recursive {
+		dir = filepath.Join(dir, filepath.FromSlash(folder))
+
+		// The zettel must not be restored outside of the place directory.
+		if rel, err := filepath.Rel(dp.dir, dir); err != nil || rel == ".." ||
+			strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return errTrashFolder
+		}
+	}
+	rc := make(chan resRestoreZettel)
+	dp.getFileChan(zid) <- &fileRestoreZettel{zid, trashPath, dir, rc}
+	res := <-rc
+	close(rc)
+	if res.err != nil {
+		return res.err
+	}
+	dp.dirSrv.UpdateEntry(&res.entry)
+	dp.notifyChanged(place.ChangeInfo{Reason: place.OnCreate, Zid: zid})
+	dp.commitZettel(ctx, "Restore zettel "+zid.Format(), zid)
+	return nil
+}
+
+var errTrashFolder = errors.New("deleted zettel cannot be restored outside of the place directory")
+
+type trashEntry struct {
+	zid       domain.ZettelID
+	deleted   time.Time
+	deletedBy string
+	folder    string
+}
+
+func (dp *dirPlace) readTrash() ([]trashEntry, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(dp.dir, trashDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	result := make([]trashEntry, 0, len(infos))
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		zid, err := domain.ParseZettelID(info.Name())
+		if err != nil {
+			continue
+		}
+		meta, err := parseMetaFile(dp.crypt, zid, filepath.Join(dp.trashPath(zid), trashInfoName))
+		if err != nil {
+			continue
+		}
+		deleted, err := time.Parse(trashTimeLayout, meta.GetDefault(trashKeyDeleted, ""))
+		if err != nil {
+			continue
+		}
+		result = append(result, trashEntry{
+			zid:       zid,
+			deleted:   deleted,
+			deletedBy: meta.GetDefault(trashKeyDeletedBy, ""),
+			folder:    meta.GetDefault(trashKeyFolder, ""),
+		})
+	}
+	return result, nil
+}
+
+// purgeTrash removes all zettel from the trash that were deleted before the
+// retention period. If such a zettel was not created again, its history is
+// removed too.
+func (dp *dirPlace) purgeTrash() {
+	if dp.trashDays <= 0 {
+		return
+	}
+	entries, err := dp.readTrash()
+	if err != nil {
+		log.Println("Unable to read trash:", err)
+		return
+	}
+	limit := time.Now().Add(-time.Duration(dp.trashDays) * 24 * time.Hour)
+	for _, te := range entries {
+		if te.deleted.After(limit) {
+			continue
+		}
+		if err := os.RemoveAll(dp.trashPath(te.zid)); err != nil {
+			log.Println("Unable to purge zettel from trash:", err)
+			continue
+		}
+		if entry := dp.dirSrv.GetEntry(te.zid); !entry.IsValid() {
+			os.RemoveAll(dp.historyPath(te.zid))
+		}
+	}
+}
+
+// buildEntry calculates the directory entry of all files of a zettel that
+// are stored in the given directory.
+func buildEntry(zid domain.ZettelID, dir string) (directory.Entry, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return directory.Entry{}, err
+	}
+	entry := directory.Entry{Zid: zid}
+	prefix := zid.Format()
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		ext := strings.TrimPrefix(filepath.Ext(name), ".")
+		if ext == "meta" {
+			entry.MetaSpec = directory.MetaSpecFile
+			entry.MetaPath = path
+			continue
+		}
+		entry.ContentPath = path
+		entry.ContentExt = ext
+	}
+	if entry.ContentPath == "" {
+		return directory.Entry{}, &place.ErrUnknownID{Zid: zid}
+	}
+	if entry.MetaSpec != directory.MetaSpecFile {
+		if entry.ContentExt == "zettel" {
+			entry.MetaSpec = directory.MetaSpecHeader
+		} else {
+			entry.MetaSpec = directory.MetaSpecNone
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,227 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package dirplace provides a directory-based zettel place.
+package dirplace
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zettelstore.de/z/config"
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+func startTrashPlace(t *testing.T, query string) (place.Place, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "trashplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := place.Connect("dir://"+dir+query, nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	if err := p.Start(context.Background()); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return p, dir
+}
+
+func createTrashZettel(t *testing.T, p place.Place) domain.ZettelID {
+	t.Helper()
+	meta := domain.NewMeta(domain.InvalidZettelID)
+	meta.Set(domain.MetaKeyTitle, "Trash")
+	meta.Set(domain.MetaKeySyntax, "zmk")
+	meta.Set(domain.MetaKeyRole, "zettel")
+	zid, err := p.CreateZettel(context.Background(), domain.Zettel{Meta: meta, Content: domain.NewContent("Content")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zid
+}
+
+func TestTrashRestore(t *testing.T) {
+	p, dir := startTrashPlace(t, "")
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	defer p.Stop(ctx)
+	tp := p.(place.Trash)
+	if !tp.HasTrash() {
+		t.Fatal("Place should have a trash")
+	}
+	zid := createTrashZettel(t, p)
+	if !tp.CanTrashZettel(ctx, zid) {
+		t.Errorf("Zettel %v should be moved into the trash", zid)
+	}
+
+	user := domain.NewMeta(domain.ZettelID(20210101000000))
+	user.Set(domain.MetaKeyIdent, "alice")
+	if err := p.DeleteZettel(place.WithUser(ctx, user), zid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetMeta(ctx, zid); err == nil {
+		t.Errorf("Deleted zettel %v still found", zid)
+	}
+	entries, err := tp.GetTrash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Meta.Zid != zid {
+		t.Fatalf("Zettel %v expected in trash, but got %v", zid, entries)
+	}
+	if got := entries[0].DeletedBy; got != "alice" {
+		t.Errorf("Expected deleting user %q, but got %q", "alice", got)
+	}
+
+	if err := tp.RestoreZettel(ctx, zid); err != nil {
+		t.Fatal(err)
+	}
+	zettel, err := p.GetZettel(ctx, zid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := zettel.Content.AsString(); got != "Content" {
+		t.Errorf("Restored content %q expected, but got %q", "Content", got)
+	}
+	if entries, err = tp.GetTrash(ctx); err != nil || len(entries) != 0 {
+		t.Errorf("Empty trash expected, but got %v/%v", entries, err)
+	}
+}
+
+func TestTrashPurge(t *testing.T) {
+	p, dir := startTrashPlace(t, "?trash=1")
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	defer p.Stop(ctx)
+	tp := p.(place.Trash)
+	zid := createTrashZettel(t, p)
+	if err := p.DeleteZettel(ctx, zid); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend that the zettel was deleted long ago.
+	info := filepath.Join(dir, trashDir, zid.Format(), trashInfoName)
+	if err := ioutil.WriteFile(info, []byte(trashKeyDeleted+": 20000101000000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := tp.GetTrash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Purged trash expected, but got %v", entries)
+	}
+	if _, err := os.Stat(filepath.Dir(info)); !os.IsNotExist(err) {
+		t.Errorf("Trash directory of zettel %v was not removed: %v", zid, err)
+	}
+	if err := tp.RestoreZettel(ctx, zid); err == nil {
+		t.Errorf("Purged zettel %v must not be restored", zid)
+	}
+}
+
+func TestTrashDisabled(t *testing.T) {
+	p, dir := startTrashPlace(t, "?trash=0")
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	defer p.Stop(ctx)
+	tp := p.(place.Trash)
+	if tp.HasTrash() {
+		t.Error("Place should not have a trash")
+	}
+	zid := createTrashZettel(t, p)
+	if tp.CanTrashZettel(ctx, zid) {
+		t.Errorf("Zettel %v must not be moved into the trash", zid)
+	}
+	if err := p.DeleteZettel(ctx, zid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, trashDir, zid.Format())); !os.IsNotExist(err) {
+		t.Errorf("Zettel %v was moved into the trash: %v", zid, err)
+	}
+}
+
+func TestTrashRestoreOutside(t *testing.T) {
+	p, dir := startTrashPlace(t, "?recursive=true")
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	defer p.Stop(ctx)
+	zid := createTrashZettel(t, p)
+	if err := p.DeleteZettel(ctx, zid); err != nil {
+		t.Fatal(err)
+	}
+
+	info := filepath.Join(dir, trashDir, zid.Format(), trashInfoName)
+	for _, folder := range []string{"..", "../outside", "sub/../../outside"} {
+		data := trashKeyDeleted + ": 20300101000000\n" + trashKeyFolder + ": " + folder + "\n"
+		if err := ioutil.WriteFile(info, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.(place.Trash).RestoreZettel(ctx, zid); err != errTrashFolder {
+			t.Errorf("Restore into folder %q must fail, but got %v", folder, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "outside")); !os.IsNotExist(err) {
+		t.Errorf("Zettel %v was restored outside of the place: %v", zid, err)
+	}
+}
+
+func TestTrashEncrypted(t *testing.T) {
+	defer config.SetupPlaceKey(nil)
+	config.SetupPlaceKey([]byte("passphrase"))
+	p, dir := startTrashPlace(t, "?encrypt=true")
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+	defer p.Stop(ctx)
+	tp := p.(place.Trash)
+	zid := createTrashZettel(t, p)
+	user := domain.NewMeta(domain.ZettelID(20210101000000))
+	user.Set(domain.MetaKeyIdent, "alice")
+	if err := p.DeleteZettel(place.WithUser(ctx, user), zid); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, trashDir, zid.Format(), trashInfoName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncrypted(data) || bytes.Contains(data, []byte("alice")) {
+		t.Errorf("Trash info not encrypted: %q", data)
+	}
+	entries, err := tp.GetTrash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].DeletedBy != "alice" {
+		t.Fatalf("Zettel %v deleted by %q expected in trash, but got %v", zid, "alice", entries)
+	}
+	if err := tp.RestoreZettel(ctx, zid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetZettel(ctx, zid); err != nil {
+		t.Error(err)
+	}
+}
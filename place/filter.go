@@ -36,53 +36,81 @@ type matchFunc func(value string) bool
 func matchAlways(value string) bool { return true }
 func matchNever(value string) bool  { return false }
 
-type matchSpec struct {
-	key   string
-	match matchFunc
-}
-
 // CreateFilterFunc calculates a filter func based on the given filter.
 func CreateFilterFunc(filter *Filter) FilterFunc {
 	if filter == nil {
 		return selectAll
 	}
-	specs := make([]matchSpec, 0, len(filter.Expr))
-	var searchAll FilterFunc
-	for key, values := range filter.Expr {
+	node := createExprNode(filter.Expr, filter.Negate)
+	if !filter.Query.IsEmpty() {
+		qNode := filter.Query.root
+		if filter.Negate {
+			qNode = notNode{qNode}
+		}
+		if node == nil {
+			node = qNode
+		} else {
+			node = andNode{node, qNode}
+		}
+	}
+	if node == nil {
+		return selectAll
+	}
+	return node.compile(filter.MatchContent)
+}
+
+// createExprNode builds an expression tree for the given filter expression.
+// All keys are and-ed, values for a key are or-ed. If a search for all keys is
+// given, it is or-ed with the other keys.
+func createExprNode(expr FilterExpr, negate bool) queryNode {
+	var specs andNode
+	var searchAll queryNode
+	for key, values := range expr {
 		if len(key) == 0 {
 			// Special handling if searching all keys...
-			searchAll = createSearchAllFunc(values, filter.Negate)
+			searchAll = wordNode(values)
 			continue
 		}
 		if domain.KeyIsValid(key) {
-			match := createMatchFunc(key, values)
-			if match != nil {
-				specs = append(specs, matchSpec{key, match})
-			}
+			specs = append(specs, &matchNode{key: key, op: opMatch, values: values})
 		}
 	}
 	if len(specs) == 0 {
 		if searchAll == nil {
-			return selectAll
+			return nil
+		}
+		if negate {
+			return notNode{searchAll}
 		}
 		return searchAll
 	}
-	negate := filter.Negate
-	searchMeta := func(m *domain.Meta) bool {
-		for _, s := range specs {
-			value, ok := m.Get(s.key)
-			if !ok || !s.match(value) {
-				return negate
-			}
+	if searchAll == nil {
+		if negate {
+			return notNode{specs}
 		}
-		return !negate
+		return specs
 	}
-	if searchAll == nil {
-		return searchMeta
+	if negate {
+		return notNode{andNode{searchAll, specs}}
 	}
-	return func(meta *domain.Meta) bool {
-		return searchAll(meta) || searchMeta(meta)
+	return orNode{searchAll, specs}
+}
+
+// ContentWords returns all words of the filter that are searched in all meta
+// data values. If MatchContent is set, they are also matched against the
+// content of a zettel.
+func (f *Filter) ContentWords() []string {
+	if f == nil {
+		return nil
+	}
+	var words []string
+	if values, ok := f.Expr[""]; ok {
+		words = collectWords(wordNode(values), words)
 	}
+	if !f.Query.IsEmpty() {
+		words = collectWords(f.Query.root, words)
+	}
+	return words
 }
 
 func createMatchFunc(key string, values []string) matchFunc {
@@ -149,6 +177,21 @@ func createMatchFunc(key string, values []string) matchFunc {
 			}
 			return true
 		}
+	case domain.MetaTypeZettelIDSet:
+		zidValues := preprocessSet(values)
+		return func(value string) bool {
+			zids := domain.ListFromValue(value)
+			for _, neededZids := range zidValues {
+				for _, neededZid := range neededZids {
+					if !matchAllWord(zids, neededZid) {
+						return false
+					}
+				}
+			}
+			return true
+		}
+	case domain.MetaTypeNumber, domain.MetaTypeTimestamp:
+		return createRangeMatchFunc(domain.KeyType(key), values)
 	}
 
 	values = sliceToLower(values)
@@ -163,7 +206,51 @@ func createMatchFunc(key string, values []string) matchFunc {
 	}
 }
 
-func createSearchAllFunc(values []string, negate bool) FilterFunc {
+// createRangeMatchFunc returns a match function for keys with ordered values.
+// A value may start with a compare operator ("<", "<=", ">", ">="), or it may
+// be a range "low..high", where both bounds are included and one of them may
+// be omitted. Otherwise, the value must be equal. A timestamp is equal to a
+// less precise timestamp, if it lies within the period of the latter, e.g.
+// "2021" matches all timestamps of that year.
+func createRangeMatchFunc(keyType byte, values []string) matchFunc {
+	preds := make([]matchFunc, 0, len(values))
+	for _, v := range values {
+		preds = append(preds, createRangePred(keyType, strings.TrimSpace(v)))
+	}
+	return func(value string) bool {
+		for _, pred := range preds {
+			if pred(value) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+var rangeOperators = []string{opLessEqual, opGreaterEqual, opLess, opGreater}
+
+func createRangePred(keyType byte, spec string) matchFunc {
+	for _, op := range rangeOperators {
+		if strings.HasPrefix(spec, op) {
+			check := compareCheck(op)
+			want := strings.TrimSpace(spec[len(op):])
+			return func(value string) bool {
+				return check(compareValues(keyType, value, want))
+			}
+		}
+	}
+	if pos := strings.Index(spec, ".."); pos >= 0 {
+		low := strings.TrimSpace(spec[:pos])
+		high := strings.TrimSpace(spec[pos+2:])
+		return func(value string) bool {
+			return (low == "" || compareValues(keyType, value, low) >= 0) &&
+				(high == "" || compareValues(keyType, value, high) <= 0)
+		}
+	}
+	return func(value string) bool { return compareValues(keyType, value, spec) == 0 }
+}
+
+func createSearchAllFunc(values []string) FilterFunc {
 	matchFuncs := map[byte]matchFunc{}
 	return func(meta *domain.Meta) bool {
 		for _, p := range meta.Pairs() {
@@ -174,14 +261,14 @@ func createSearchAllFunc(values []string, negate bool) FilterFunc {
 				matchFuncs[keyType] = match
 			}
 			if match(p.Value) {
-				return !negate
+				return true
 			}
 		}
 		match, ok := matchFuncs[domain.KeyType(domain.MetaKeyID)]
 		if !ok {
 			match = createMatchFunc(domain.MetaKeyID, values)
 		}
-		return match(meta.Zid.Format()) != negate
+		return match(meta.Zid.Format())
 	}
 }
 
@@ -0,0 +1,139 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package place_test provides some tests for filtering and sorting.
+package place_test
+
+import (
+	"testing"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+var typedMetas = []*domain.Meta{
+	newTestMeta(20210101000000, "priority", "10", "due", "20210315"),
+	newTestMeta(20210202000000, "priority", "9", "due", "2021041512"),
+	newTestMeta(20210303000000, "priority", "-1", "due", "2022"),
+	newTestMeta(20210404000000, "title", "No priority"),
+}
+
+func filterTyped(t *testing.T, key string, values ...string) []domain.ZettelID {
+	t.Helper()
+	match := place.CreateFilterFunc(&place.Filter{Expr: place.FilterExpr{key: values}})
+	var result []domain.ZettelID
+	for _, meta := range typedMetas {
+		if match(meta) {
+			result = append(result, meta.Zid)
+		}
+	}
+	return result
+}
+
+func TestTypedFilter(t *testing.T) {
+	testcases := []struct {
+		key    string
+		values []string
+		exp    []domain.ZettelID
+	}{
+		{"priority", []string{"9"}, []domain.ZettelID{20210202000000}},
+		{"priority", []string{">9"}, []domain.ZettelID{20210101000000}},
+		{"priority", []string{">=9"}, []domain.ZettelID{20210101000000, 20210202000000}},
+		{"priority", []string{"<0", "10"}, []domain.ZettelID{20210101000000, 20210303000000}},
+		{"priority", []string{"0..9"}, []domain.ZettelID{20210202000000}},
+		{"priority", []string{"..9"}, []domain.ZettelID{20210202000000, 20210303000000}},
+		{"due", []string{"2021"}, []domain.ZettelID{20210101000000, 20210202000000}},
+		{"due", []string{"2021-04"}, []domain.ZettelID{20210202000000}},
+		{"due", []string{"<2021-04"}, []domain.ZettelID{20210101000000}},
+		{"due", []string{">2021-03-15"}, []domain.ZettelID{20210202000000, 20210303000000}},
+		{"due", []string{"2021-03..2021-04"}, []domain.ZettelID{20210101000000, 20210202000000}},
+		{"due", []string{"2021-04.."}, []domain.ZettelID{20210202000000, 20210303000000}},
+	}
+	for i, tc := range testcases {
+		got := filterTyped(t, tc.key, tc.values...)
+		if !equalZids(got, tc.exp) {
+			t.Errorf("TC=%d, %s=%v: exp=%v, got=%v", i, tc.key, tc.values, tc.exp, got)
+		}
+	}
+}
+
+func TestTypedQuery(t *testing.T) {
+	testcases := []struct {
+		query string
+		exp   []domain.ZettelID
+	}{
+		{"priority>9", []domain.ZettelID{20210101000000}},
+		{"priority=10", []domain.ZettelID{20210101000000}},
+		{"due=2021", []domain.ZettelID{20210101000000, 20210202000000}},
+		{"due>=2021-04-01 due<2022", []domain.ZettelID{20210202000000}},
+	}
+	for i, tc := range testcases {
+		q, err := place.ParseQuery(tc.query)
+		if err != nil {
+			t.Errorf("TC=%d, query=%q: unexpected error %v", i, tc.query, err)
+			continue
+		}
+		match := place.CreateFilterFunc(&place.Filter{Query: q})
+		var got []domain.ZettelID
+		for _, meta := range typedMetas {
+			if match(meta) {
+				got = append(got, meta.Zid)
+			}
+		}
+		if !equalZids(got, tc.exp) {
+			t.Errorf("TC=%d, query=%q: exp=%v, got=%v", i, tc.query, tc.exp, got)
+		}
+	}
+}
+
+func TestTypedSorter(t *testing.T) {
+	testcases := []struct {
+		order      string
+		descending bool
+		exp        []domain.ZettelID
+	}{
+		{"priority", false, []domain.ZettelID{20210303000000, 20210202000000, 20210101000000, 20210404000000}},
+		{"priority", true, []domain.ZettelID{20210101000000, 20210202000000, 20210303000000, 20210404000000}},
+		{"due", false, []domain.ZettelID{20210101000000, 20210202000000, 20210303000000, 20210404000000}},
+		{"due", true, []domain.ZettelID{20210303000000, 20210202000000, 20210101000000, 20210404000000}},
+	}
+	for i, tc := range testcases {
+		ml := append([]*domain.Meta(nil), typedMetas...)
+		ml = place.ApplySorter(ml, &place.Sorter{Order: tc.order, Descending: tc.descending})
+		got := make([]domain.ZettelID, 0, len(ml))
+		for _, meta := range ml {
+			got = append(got, meta.Zid)
+		}
+		if !equalZids(got, tc.exp) {
+			t.Errorf("TC=%d, order=%q, descending=%v: exp=%v, got=%v", i, tc.order, tc.descending, tc.exp, got)
+		}
+	}
+}
+
+func equalZids(got, exp []domain.ZettelID) bool {
+	if len(got) != len(exp) {
+		return false
+	}
+	for i := range got {
+		if got[i] != exp[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -22,6 +22,7 @@ package memplace
 
 import (
 	"context"
+	"log"
 	"net/url"
 	"sync"
 	"time"
@@ -34,7 +35,12 @@ func init() {
 	place.Register(
 		"mem",
 		func(u *url.URL, next place.Place) (place.Place, error) {
-			return &memPlace{u: u, next: next}, nil
+			return &memPlace{
+				u:        u,
+				next:     next,
+				snapshot: u.Query().Get("snapshot"),
+				interval: time.Duration(place.GetQueryInt(u, "interval", 1, 60, 24*60*60)) * time.Second,
+			}, nil
 		})
 }
 
@@ -44,16 +50,24 @@ type memPlace struct {
 	zettel    map[domain.ZettelID]domain.Zettel
 	started   bool
 	mx        sync.RWMutex
+	mxUpdate  sync.Mutex // Serializes updates, so that versions can be checked
 	observers []place.ObserverFunc
+
+	snapshot string        // Path of the snapshot file, or empty
+	interval time.Duration // Time between two snapshots
+	changes  uint64        // Number of changes since start
+	saved    uint64        // Number of changes that are in the snapshot
+	mxSave   sync.Mutex    // Only one snapshot is written at a time
+	done     chan struct{}
 }
 
-func (mp *memPlace) notifyChanged(all bool, zid domain.ZettelID) {
+func (mp *memPlace) notifyChanged(ci place.ChangeInfo) {
 	for _, ob := range mp.observers {
-		ob(all, zid)
+		ob(ci)
 	}
 }
 
-func (mp *memPlace) Next() place.Place { return nil }
+func (mp *memPlace) Next() place.Place { return mp.next }
 
 func (mp *memPlace) Location() string {
 	return mp.u.String()
@@ -65,27 +79,100 @@ func (mp *memPlace) Start(ctx context.Context) error {
 			return err
 		}
 	}
+	zettel := make(map[domain.ZettelID]domain.Zettel)
+	if mp.snapshot != "" {
+		var err error
+		if zettel, err = loadSnapshot(mp.snapshot); err != nil {
+			if mp.next != nil {
+				mp.next.Stop(ctx)
+			}
+			return err
+		}
+	}
 	mp.mx.Lock()
 	defer mp.mx.Unlock()
 	if mp.started {
 		panic("memPlace started twice")
 	}
-	mp.zettel = make(map[domain.ZettelID]domain.Zettel)
+	mp.zettel = zettel
+	mp.changes = 0
+	mp.saved = 0
 	mp.started = true
+	if mp.snapshot != "" {
+		mp.done = make(chan struct{})
+		go mp.saveRegularly(mp.done)
+	}
 	return nil
 }
 
 func (mp *memPlace) Stop(ctx context.Context) error {
 	mp.mx.Lock()
-	defer mp.mx.Unlock()
 	if !mp.started {
+		mp.mx.Unlock()
 		return place.ErrStopped
 	}
+	if mp.done != nil {
+		close(mp.done)
+		mp.done = nil
+	}
+	mp.mx.Unlock()
+	err := mp.save()
+
+	mp.mx.Lock()
 	mp.zettel = nil
 	mp.started = false
+	mp.mx.Unlock()
 	if mp.next != nil {
-		return mp.next.Stop(ctx)
+		if err1 := mp.next.Stop(ctx); err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+// saveRegularly writes a snapshot after each interval, if some zettel were
+// changed.
+func (mp *memPlace) saveRegularly(done <-chan struct{}) {
+	ticker := time.NewTicker(mp.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := mp.save(); err != nil {
+				log.Println("MEMPLACE", "ERROR", mp.snapshot, err)
+			}
+		}
+	}
+}
+
+// save writes all zettel into the snapshot file, if they were changed since
+// the last snapshot.
+func (mp *memPlace) save() error {
+	if mp.snapshot == "" {
+		return nil
+	}
+	mp.mxSave.Lock()
+	defer mp.mxSave.Unlock()
+	mp.mx.RLock()
+	changes := mp.changes
+	if changes == mp.saved || mp.zettel == nil {
+		mp.mx.RUnlock()
+		return nil
+	}
+	zettel := make([]domain.Zettel, 0, len(mp.zettel))
+	for _, z := range mp.zettel {
+		zettel = append(zettel, z)
+	}
+	mp.mx.RUnlock()
+
+	if err := writeSnapshot(mp.snapshot, zettel); err != nil {
+		return err
 	}
+	mp.mx.Lock()
+	mp.saved = changes
+	mp.mx.Unlock()
 	return nil
 }
 
@@ -111,13 +198,23 @@ func (mp *memPlace) CreateZettel(ctx context.Context, zettel domain.Zettel) (dom
 
 	meta := zettel.Meta.Clone()
 	meta.Zid = mp.calcNewZid()
+	setModified(meta)
 	meta.Freeze()
 	zettel.Meta = meta
 	mp.zettel[meta.Zid] = zettel
-	mp.notifyChanged(false, meta.Zid)
+	mp.changes++
+	mp.notifyChanged(place.ChangeInfo{Reason: place.OnCreate, Zid: meta.Zid})
 	return meta.Zid, nil
 }
 
+// setModified stores the current time as the time of the last update, if the
+// meta data does not specify one.
+func setModified(meta *domain.Meta) {
+	if _, ok := meta.Get(domain.MetaKeyModified); !ok {
+		meta.Set(domain.MetaKeyModified, domain.TimestampValue(time.Now()))
+	}
+}
+
 func (mp *memPlace) calcNewZid() domain.ZettelID {
 	zid := domain.NewZettelID(false)
 	if _, ok := mp.zettel[zid]; !ok {
@@ -195,6 +292,39 @@ func (mp *memPlace) CanUpdateZettel(ctx context.Context, zettel domain.Zettel) b
 }
 
 func (mp *memPlace) UpdateZettel(ctx context.Context, zettel domain.Zettel) error {
+	mp.mxUpdate.Lock()
+	defer mp.mxUpdate.Unlock()
+	return mp.updateZettel(zettel)
+}
+
+// GetVersionedZettel retrieves a zettel, together with the version token of
+// its current state.
+func (mp *memPlace) GetVersionedZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, string, error) {
+	mp.mx.RLock()
+	zettel, ok := mp.zettel[zid]
+	mp.mx.RUnlock()
+	if !ok && mp.next != nil {
+		return place.GetVersionedZettel(ctx, mp.next, zid)
+	}
+	zettel, err := mp.GetZettel(ctx, zid)
+	if err != nil {
+		return domain.Zettel{}, "", err
+	}
+	return zettel, place.CalcVersion(zettel), nil
+}
+
+// UpdateVersionedZettel updates a zettel, if it was not changed since the
+// given version was retrieved.
+func (mp *memPlace) UpdateVersionedZettel(ctx context.Context, zettel domain.Zettel, version string) error {
+	mp.mxUpdate.Lock()
+	defer mp.mxUpdate.Unlock()
+	if err := place.CheckVersion(ctx, mp, zettel.Meta.Zid, version); err != nil {
+		return err
+	}
+	return mp.updateZettel(zettel)
+}
+
+func (mp *memPlace) updateZettel(zettel domain.Zettel) error {
 	mp.mx.Lock()
 	defer mp.mx.Unlock()
 	if !mp.started {
@@ -205,16 +335,18 @@ func (mp *memPlace) UpdateZettel(ctx context.Context, zettel domain.Zettel) erro
 	if !meta.Zid.IsValid() {
 		return &place.ErrInvalidID{Zid: meta.Zid}
 	}
+	setModified(meta)
 	meta.Freeze()
 	zettel.Meta = meta
 	mp.zettel[meta.Zid] = zettel
-	mp.notifyChanged(false, meta.Zid)
+	mp.changes++
+	mp.notifyChanged(place.ChangeInfo{Reason: place.OnUpdate, Zid: meta.Zid})
 	return nil
 }
 
 func (mp *memPlace) CanDeleteZettel(ctx context.Context, zid domain.ZettelID) bool {
 	mp.mx.RLock()
-	defer mp.mx.Unlock()
+	defer mp.mx.RUnlock()
 	if !mp.started {
 		return false
 	}
@@ -235,13 +367,14 @@ func (mp *memPlace) DeleteZettel(ctx context.Context, zid domain.ZettelID) error
 		return &place.ErrUnknownID{Zid: zid}
 	}
 	delete(mp.zettel, zid)
-	mp.notifyChanged(false, zid)
+	mp.changes++
+	mp.notifyChanged(place.ChangeInfo{Reason: place.OnDelete, Zid: zid})
 	return nil
 }
 
 func (mp *memPlace) CanRenameZettel(ctx context.Context, zid domain.ZettelID) bool {
 	mp.mx.RLock()
-	defer mp.mx.Unlock()
+	defer mp.mx.RUnlock()
 	if !mp.started {
 		return false
 	}
@@ -272,7 +405,8 @@ func (mp *memPlace) RenameZettel(ctx context.Context, curZid, newZid domain.Zett
 	zettel.Meta = meta
 	mp.zettel[newZid] = zettel
 	delete(mp.zettel, curZid)
-	mp.notifyChanged(false, curZid)
+	mp.changes++
+	mp.notifyChanged(place.ChangeInfo{Reason: place.OnRename, Zid: curZid, NewZid: newZid})
 	return nil
 }
 
@@ -0,0 +1,180 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package memplace stores zettel volatile in main memory.
+package memplace
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "memplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	uri := "mem:?snapshot=" + filepath.Join(dir, "snapshot")
+
+	ctx := context.Background()
+	p, err := place.Connect(uri, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	meta := domain.NewMeta(domain.InvalidZettelID)
+	meta.Set(domain.MetaKeyTitle, "Snapshot")
+	zid, err := p.CreateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("Content")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "snapshot")); err != nil {
+		t.Fatal(err)
+	} else if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("Snapshot must be readable by the owner only, but has mode %v", perm)
+	}
+
+	if p, err = place.Connect(uri, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop(ctx)
+	zettel, err := p.GetZettel(ctx, zid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := zettel.Meta.GetDefault(domain.MetaKeyTitle, ""); got != "Snapshot" {
+		t.Errorf("Title %q expected, but got %q", "Snapshot", got)
+	}
+	if got := zettel.Content.AsString(); got != "Content" {
+		t.Errorf("Content %q expected, but got %q", "Content", got)
+	}
+}
+
+func TestSnapshotBroken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "memplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot")
+	if err := ioutil.WriteFile(path, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	next, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := place.Connect("mem:?snapshot="+path, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(ctx); err == nil {
+		p.Stop(ctx)
+		t.Fatal("Broken snapshot not detected")
+	}
+
+	// The next place must have been stopped, otherwise it cannot be started.
+	if err := next.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	next.Stop(ctx)
+}
+
+func TestNext(t *testing.T) {
+	next, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := place.Connect("mem:", next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Next(); got != next {
+		t.Errorf("Next place %v expected, but got %v", next, got)
+	}
+}
+
+func TestUpdateVersionedZettel(t *testing.T) {
+	ctx := context.Background()
+	p, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop(ctx)
+	meta := domain.NewMeta(domain.InvalidZettelID)
+	meta.Set(domain.MetaKeyTitle, "Version")
+	zid, err := p.CreateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("Content")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, version, err := place.GetVersionedZettel(ctx, p, zid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			meta := domain.NewMeta(zid)
+			meta.Set(domain.MetaKeyTitle, "Update")
+			content := domain.NewContent(string(rune('A' + i)))
+			errs <- place.UpdateVersionedZettel(ctx, p, domain.Zettel{Meta: meta, Content: content}, version)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	updated := 0
+	for err := range errs {
+		switch err.(type) {
+		case nil:
+			updated++
+		case *place.ErrVersionConflict:
+		default:
+			t.Error(err)
+		}
+	}
+	if updated != 1 {
+		t.Errorf("Exactly one update with version %q expected, but got %d", version, updated)
+	}
+}
@@ -0,0 +1,101 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package memplace stores zettel volatile in main memory.
+package memplace
+
+import (
+	"encoding/gob"
+	"os"
+	"sort"
+
+	"zettelstore.de/z/domain"
+)
+
+// snapshotZettel is the stored form of a zettel in a snapshot file.
+type snapshotZettel struct {
+	Zid     domain.ZettelID
+	Pairs   []domain.MetaPair
+	YamlSep bool
+	Content string
+}
+
+// loadSnapshot reads all zettel of a snapshot file. A missing file results
+// in an empty set of zettel, because no snapshot was written so far.
+func loadSnapshot(path string) (map[domain.ZettelID]domain.Zettel, error) {
+	result := make(map[domain.ZettelID]domain.Zettel)
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var list []snapshotZettel
+	if err := gob.NewDecoder(f).Decode(&list); err != nil {
+		return nil, &os.PathError{Op: "decode", Path: path, Err: err}
+	}
+	for _, sz := range list {
+		meta := domain.NewMeta(sz.Zid)
+		for _, p := range sz.Pairs {
+			meta.Set(p.Key, p.Value)
+		}
+		meta.YamlSep = sz.YamlSep
+		meta.Freeze()
+		result[sz.Zid] = domain.Zettel{Meta: meta, Content: domain.NewContent(sz.Content)}
+	}
+	return result, nil
+}
+
+// writeSnapshot stores the given zettel in a snapshot file. The file is
+// replaced only after all zettel were written and synced successfully. Since
+// it contains the full zettel data, it is readable by the owner only.
+func writeSnapshot(path string, zettel []domain.Zettel) error {
+	sort.Slice(zettel, func(i, j int) bool { return zettel[i].Meta.Zid < zettel[j].Meta.Zid })
+	list := make([]snapshotZettel, 0, len(zettel))
+	for _, z := range zettel {
+		list = append(list, snapshotZettel{
+			Zid:     z.Meta.Zid,
+			Pairs:   z.Meta.Pairs(),
+			YamlSep: z.Meta.YamlSep,
+			Content: z.Content.AsString(),
+		})
+	}
+
+	tmpPath := path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(f).Encode(list)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
@@ -0,0 +1,488 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package mirrorplace provides a zettel place that stores all zettel in a
+// primary place and replicates them to some secondary places.
+package mirrorplace
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+func init() {
+	place.Register("mirror", newMirrorPlace)
+}
+
+// newMirrorPlace creates a mirror place from an URI like
+// "mirror:?primary=dir:///a&secondary=dir:///b&secondary=dir:///c". The URIs
+// of the primary and secondary places must be escaped, if they contain a
+// query.
+func newMirrorPlace(u *url.URL, next place.Place) (place.Place, error) {
+	q := u.Query()
+	primaryURI := q.Get("primary")
+	if primaryURI == "" {
+		return nil, &url.Error{Op: "parse", URL: u.String(), Err: errNoPrimary}
+	}
+	secondaryURIs := q["secondary"]
+	if len(secondaryURIs) == 0 {
+		return nil, &url.Error{Op: "parse", URL: u.String(), Err: errNoSecondary}
+	}
+
+	// Primary and secondaries store only their own zettel, the next place is
+	// handled by the mirror place. Otherwise, zettel of the next place would
+	// be replicated too.
+	primary, err := place.Connect(primaryURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	mp := &mirrorPlace{
+		u:       u,
+		primary: primary,
+		next:    next,
+		check:   time.Duration(place.GetQueryInt(u, "check", 0, 60*60, 7*24*60*60)) * time.Second,
+		retry:   time.Duration(place.GetQueryInt(u, "retry", 1, 10, 60*60)) * time.Second,
+	}
+	for _, uri := range secondaryURIs {
+		p, err := place.Connect(uri, nil)
+		if err != nil {
+			return nil, err
+		}
+		mp.secondaries = append(mp.secondaries, newSecondary(p))
+	}
+	primary.RegisterChangeObserver(mp.observePrimary)
+	return mp, nil
+}
+
+var (
+	errNoPrimary   = errors.New("missing primary place")
+	errNoSecondary = errors.New("missing secondary place")
+)
+
+// mirrorPlace forwards all changes to a primary place. They are replicated
+// asynchronously to all secondary places.
+type mirrorPlace struct {
+	u           *url.URL
+	primary     place.Place
+	secondaries []*secondary
+	next        place.Place
+	check       time.Duration // Time between two checks for divergence, 0: no checks
+	retry       time.Duration // Time to wait after a failed replication
+
+	mx       sync.Mutex
+	mxUpdate sync.Mutex // Serializes updates, so that versions can be checked
+	started  bool
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	// mxReload is write-locked while places are reloaded. Replication
+	// holds a read-lock, because reloaded places are temporarily stopped.
+	mxReload sync.RWMutex
+}
+
+func (mp *mirrorPlace) Next() place.Place { return mp.next }
+
+func (mp *mirrorPlace) Location() string {
+	locs := make([]string, 0, len(mp.secondaries))
+	for _, sec := range mp.secondaries {
+		locs = append(locs, sec.place.Location())
+	}
+	return "mirror:" + mp.primary.Location() + " => " + strings.Join(locs, ", ")
+}
+
+func (mp *mirrorPlace) Start(ctx context.Context) error {
+	mp.mx.Lock()
+	defer mp.mx.Unlock()
+	if mp.started {
+		panic("mirrorPlace started twice")
+	}
+	if mp.next != nil {
+		if err := mp.next.Start(ctx); err != nil {
+			return err
+		}
+	}
+	if err := mp.primary.Start(ctx); err != nil {
+		return err
+	}
+	for _, sec := range mp.secondaries {
+		if err := sec.place.Start(ctx); err != nil {
+			return err
+		}
+	}
+	mp.done = make(chan struct{})
+	for _, sec := range mp.secondaries {
+		// A secondary could be changed while the place was stopped.
+		sec.requestResync()
+		mp.wg.Add(1)
+		go mp.replicate(sec, mp.done)
+	}
+	mp.started = true
+	return nil
+}
+
+func (mp *mirrorPlace) Stop(ctx context.Context) error {
+	mp.mx.Lock()
+	defer mp.mx.Unlock()
+	if !mp.started {
+		return place.ErrStopped
+	}
+	close(mp.done)
+	mp.wg.Wait()
+	mp.started = false
+
+	err := mp.primary.Stop(ctx)
+	for _, sec := range mp.secondaries {
+		if err1 := sec.place.Stop(ctx); err == nil {
+			err = err1
+		}
+	}
+	if mp.next != nil {
+		if err1 := mp.next.Stop(ctx); err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+func (mp *mirrorPlace) isStarted() bool {
+	mp.mx.Lock()
+	defer mp.mx.Unlock()
+	return mp.started
+}
+
+// RegisterChangeObserver registers an observer that will be notified
+// if a zettel was found to be changed.
+func (mp *mirrorPlace) RegisterChangeObserver(f place.ObserverFunc) {
+	if mp.next != nil {
+		mp.next.RegisterChangeObserver(f)
+	}
+	mp.primary.RegisterChangeObserver(f)
+}
+
+// observePrimary schedules the replication of all changes of the primary
+// place. It must not block, because some places notify their observers
+// while they hold a lock.
+func (mp *mirrorPlace) observePrimary(ci place.ChangeInfo) {
+	for _, sec := range mp.secondaries {
+		switch ci.Reason {
+		case place.OnReload:
+			sec.requestResync()
+		case place.OnRename:
+			sec.requestSync(ci.Zid, ci.NewZid)
+		default:
+			sec.requestSync(ci.Zid)
+		}
+	}
+}
+
+// changed schedules the replication of zettel that were changed by this
+// place. The replication must not depend on the notifications of the primary
+// place, because a place may notify its observers only after some delay.
+func (mp *mirrorPlace) changed(zids ...domain.ZettelID) {
+	for _, sec := range mp.secondaries {
+		sec.requestSync(zids...)
+	}
+}
+
+func (mp *mirrorPlace) CanCreateZettel(ctx context.Context) bool {
+	return mp.isStarted() && mp.primary.CanCreateZettel(ctx)
+}
+
+func (mp *mirrorPlace) CreateZettel(ctx context.Context, zettel domain.Zettel) (domain.ZettelID, error) {
+	if !mp.isStarted() {
+		return domain.InvalidZettelID, place.ErrStopped
+	}
+	zid, err := mp.primary.CreateZettel(ctx, zettel)
+	if err == nil {
+		mp.changed(zid)
+	}
+	return zid, err
+}
+
+func (mp *mirrorPlace) GetZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, error) {
+	if !mp.isStarted() {
+		return domain.Zettel{}, place.ErrStopped
+	}
+	zettel, err := mp.primary.GetZettel(ctx, zid)
+	if isUnknown(err) && mp.next != nil {
+		return mp.next.GetZettel(ctx, zid)
+	}
+	return zettel, err
+}
+
+// GetMeta retrieves just the meta data of a specific zettel.
+func (mp *mirrorPlace) GetMeta(ctx context.Context, zid domain.ZettelID) (*domain.Meta, error) {
+	if !mp.isStarted() {
+		return nil, place.ErrStopped
+	}
+	meta, err := mp.primary.GetMeta(ctx, zid)
+	if isUnknown(err) && mp.next != nil {
+		return mp.next.GetMeta(ctx, zid)
+	}
+	return meta, err
+}
+
+// SelectMeta returns all zettel meta data that match the selection
+// criteria. The result is ordered by descending zettel id.
+func (mp *mirrorPlace) SelectMeta(ctx context.Context, f *place.Filter, s *place.Sorter) ([]*domain.Meta, error) {
+	if !mp.isStarted() {
+		return nil, place.ErrStopped
+	}
+	metaList, err := mp.primary.SelectMeta(ctx, f, nil)
+	if err != nil {
+		return nil, err
+	}
+	if mp.next != nil {
+		other, err := mp.next.SelectMeta(ctx, f, nil)
+		if err != nil {
+			return nil, err
+		}
+		return place.MergeSorted(place.ApplySorter(metaList, nil), other, s), nil
+	}
+	return place.ApplySorter(metaList, s), nil
+}
+
+func (mp *mirrorPlace) CanUpdateZettel(ctx context.Context, zettel domain.Zettel) bool {
+	return mp.isStarted() && mp.primary.CanUpdateZettel(ctx, zettel)
+}
+
+func (mp *mirrorPlace) UpdateZettel(ctx context.Context, zettel domain.Zettel) error {
+	if !mp.isStarted() {
+		return place.ErrStopped
+	}
+	mp.mxUpdate.Lock()
+	defer mp.mxUpdate.Unlock()
+	return mp.updateZettel(ctx, zettel)
+}
+
+// GetVersionedZettel retrieves a zettel, together with the version token of
+// its current state.
+func (mp *mirrorPlace) GetVersionedZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, string, error) {
+	if !mp.isStarted() {
+		return domain.Zettel{}, "", place.ErrStopped
+	}
+	zettel, version, err := place.GetVersionedZettel(ctx, mp.primary, zid)
+	if isUnknown(err) && mp.next != nil {
+		return place.GetVersionedZettel(ctx, mp.next, zid)
+	}
+	return zettel, version, err
+}
+
+// UpdateVersionedZettel updates a zettel, if it was not changed since the
+// given version was retrieved.
+func (mp *mirrorPlace) UpdateVersionedZettel(ctx context.Context, zettel domain.Zettel, version string) error {
+	if !mp.isStarted() {
+		return place.ErrStopped
+	}
+	mp.mxUpdate.Lock()
+	defer mp.mxUpdate.Unlock()
+	if err := place.CheckVersion(ctx, mp, zettel.Meta.Zid, version); err != nil {
+		return err
+	}
+	return mp.updateZettel(ctx, zettel)
+}
+
+func (mp *mirrorPlace) updateZettel(ctx context.Context, zettel domain.Zettel) error {
+	err := mp.primary.UpdateZettel(ctx, zettel)
+	if err == nil {
+		mp.changed(zettel.Meta.Zid)
+	}
+	return err
+}
+
+func (mp *mirrorPlace) CanDeleteZettel(ctx context.Context, zid domain.ZettelID) bool {
+	if !mp.isStarted() {
+		return false
+	}
+	if mp.hasZettel(ctx, zid) {
+		return mp.primary.CanDeleteZettel(ctx, zid)
+	}
+	return mp.next != nil && mp.next.CanDeleteZettel(ctx, zid)
+}
+
+// DeleteZettel removes the zettel from the place.
+func (mp *mirrorPlace) DeleteZettel(ctx context.Context, zid domain.ZettelID) error {
+	if !mp.isStarted() {
+		return place.ErrStopped
+	}
+	if !mp.hasZettel(ctx, zid) {
+		if mp.next != nil {
+			return mp.next.DeleteZettel(ctx, zid)
+		}
+		return &place.ErrUnknownID{Zid: zid}
+	}
+	err := mp.primary.DeleteZettel(ctx, zid)
+	if err == nil {
+		mp.changed(zid)
+	}
+	return err
+}
+
+func (mp *mirrorPlace) CanRenameZettel(ctx context.Context, zid domain.ZettelID) bool {
+	if !mp.isStarted() {
+		return false
+	}
+	if mp.hasZettel(ctx, zid) {
+		return mp.primary.CanRenameZettel(ctx, zid)
+	}
+	return mp.next != nil && mp.next.CanRenameZettel(ctx, zid)
+}
+
+// Rename changes the current zid to a new zid.
+func (mp *mirrorPlace) RenameZettel(ctx context.Context, curZid, newZid domain.ZettelID) error {
+	if !mp.isStarted() {
+		return place.ErrStopped
+	}
+	if !mp.hasZettel(ctx, curZid) {
+		if mp.next != nil {
+			return mp.next.RenameZettel(ctx, curZid, newZid)
+		}
+		return &place.ErrUnknownID{Zid: curZid}
+	}
+	err := mp.primary.RenameZettel(ctx, curZid, newZid)
+	if err == nil {
+		mp.changed(curZid, newZid)
+	}
+	return err
+}
+
+// Reload clears all caches, reloads all internal data to reflect changes
+// that were possibly undetected. All secondary places are checked for
+// divergence from the primary place and are resynchronized.
+func (mp *mirrorPlace) Reload(ctx context.Context) error {
+	if !mp.isStarted() {
+		return place.ErrStopped
+	}
+	mp.mxReload.Lock()
+	defer mp.mxReload.Unlock()
+	err := mp.primary.Reload(ctx)
+	for _, sec := range mp.secondaries {
+		if err1 := sec.place.Reload(ctx); err == nil {
+			err = err1
+		}
+		sec.requestResync()
+	}
+	if mp.next != nil {
+		if err1 := mp.next.Reload(ctx); err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+// HasHistory returns true, if the primary or the next place stores prior
+// versions.
+func (mp *mirrorPlace) HasHistory() bool {
+	return place.HasHistory(mp.primary) || place.HasHistory(mp.next)
+}
+
+// GetHistory returns all stored prior versions of a zettel, newest first.
+func (mp *mirrorPlace) GetHistory(ctx context.Context, zid domain.ZettelID) ([]place.ZettelVersion, error) {
+	if h, ok := mp.primary.(place.Historian); ok && mp.hasZettel(ctx, zid) {
+		return h.GetHistory(ctx, zid)
+	}
+	if h, ok := mp.next.(place.Historian); ok {
+		return h.GetHistory(ctx, zid)
+	}
+	return nil, nil
+}
+
+// GetZettelVersion retrieves a prior version of a zettel.
+func (mp *mirrorPlace) GetZettelVersion(ctx context.Context, zid domain.ZettelID, version string) (domain.Zettel, error) {
+	if h, ok := mp.primary.(place.Historian); ok && mp.hasZettel(ctx, zid) {
+		return h.GetZettelVersion(ctx, zid, version)
+	}
+	if h, ok := mp.next.(place.Historian); ok {
+		return h.GetZettelVersion(ctx, zid, version)
+	}
+	return domain.Zettel{}, &place.ErrUnknownID{Zid: zid}
+}
+
+// HasTrash returns true, if the primary place has a trash.
+func (mp *mirrorPlace) HasTrash() bool { return place.HasTrash(mp.primary) }
+
+// CanTrashZettel returns true, if deleting the zettel moves it into the
+// trash of the primary place, or of the next place.
+func (mp *mirrorPlace) CanTrashZettel(ctx context.Context, zid domain.ZettelID) bool {
+	if mp.hasZettel(ctx, zid) {
+		return place.CanTrashZettel(ctx, mp.primary, zid)
+	}
+	return place.CanTrashZettel(ctx, mp.next, zid)
+}
+
+// GetTrash returns all deleted zettel that are still in the trash of the
+// primary place, most recently deleted first.
+func (mp *mirrorPlace) GetTrash(ctx context.Context) ([]place.TrashEntry, error) {
+	if t, ok := mp.primary.(place.Trash); ok {
+		return t.GetTrash(ctx)
+	}
+	return nil, nil
+}
+
+// RestoreZettel moves a deleted zettel from the trash of the primary place
+// back to its original zettel id.
+func (mp *mirrorPlace) RestoreZettel(ctx context.Context, zid domain.ZettelID) error {
+	t, ok := mp.primary.(place.Trash)
+	if !ok {
+		return &place.ErrUnknownID{Zid: zid}
+	}
+	err := t.RestoreZettel(ctx, zid)
+	if err == nil {
+		mp.changed(zid)
+	}
+	return err
+}
+
+// Check returns all problems found in the primary and in the secondary
+// places.
+func (mp *mirrorPlace) Check(ctx context.Context, fix bool) ([]place.Problem, error) {
+	places := []place.Place{mp.primary}
+	for _, sec := range mp.secondaries {
+		places = append(places, sec.place)
+	}
+	var result []place.Problem
+	for _, p := range places {
+		if c, ok := p.(place.Checker); ok {
+			problems, err := c.Check(ctx, fix)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, problems...)
+		}
+	}
+	return result, nil
+}
+
+// hasZettel returns true, if the zettel is stored in the primary place.
+func (mp *mirrorPlace) hasZettel(ctx context.Context, zid domain.ZettelID) bool {
+	_, err := mp.primary.GetMeta(ctx, zid)
+	return err == nil
+}
+
+func isUnknown(err error) bool {
+	_, ok := err.(*place.ErrUnknownID)
+	return ok
+}
@@ -0,0 +1,140 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package mirrorplace provides a zettel place that stores all zettel in a
+// primary place and replicates them to some secondary places.
+package mirrorplace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+	_ "zettelstore.de/z/place/memplace"
+)
+
+// waitFor waits until the condition is true, or fails the test.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Timeout while waiting for %v", what)
+}
+
+func hasZettel(ctx context.Context, p place.Place, zid domain.ZettelID, content string) bool {
+	zettel, err := p.GetZettel(ctx, zid)
+	return err == nil && zettel.Content.AsString() == content
+}
+
+func TestMirror(t *testing.T) {
+	p, err := place.Connect("mirror:?primary=mem:&secondary=mem:&secondary=mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop(ctx)
+	mp := p.(*mirrorPlace)
+
+	meta := domain.NewMeta(domain.InvalidZettelID)
+	meta.Set(domain.MetaKeyTitle, "Mirror")
+	zid, err := p.CreateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("One")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sec := range mp.secondaries {
+		waitFor(t, "created zettel", func() bool { return hasZettel(ctx, sec.place, zid, "One") })
+	}
+
+	newZid := zid + 1
+	if err := p.RenameZettel(ctx, zid, newZid); err != nil {
+		t.Fatal(err)
+	}
+	for _, sec := range mp.secondaries {
+		waitFor(t, "renamed zettel", func() bool {
+			_, err := sec.place.GetMeta(ctx, zid)
+			return isUnknown(err) && hasZettel(ctx, sec.place, newZid, "One")
+		})
+	}
+
+	// Let the first secondary diverge, then resync it.
+	sec := mp.secondaries[0].place
+	meta = domain.NewMeta(newZid)
+	meta.Set(domain.MetaKeyTitle, "Diverged")
+	if err := sec.UpdateZettel(ctx, domain.Zettel{Meta: meta, Content: domain.NewContent("Two")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sec.UpdateZettel(ctx, domain.Zettel{Meta: domain.NewMeta(zid), Content: domain.NewContent("Old")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "resync", func() bool {
+		_, err := sec.GetMeta(ctx, zid)
+		return isUnknown(err) && hasZettel(ctx, sec, newZid, "One")
+	})
+
+	if err := p.DeleteZettel(ctx, newZid); err != nil {
+		t.Fatal(err)
+	}
+	for _, sec := range mp.secondaries {
+		waitFor(t, "deleted zettel", func() bool {
+			_, err := sec.place.GetMeta(ctx, newZid)
+			return isUnknown(err)
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	next, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := place.Connect("mirror:?primary=mem:&secondary=mem:", next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop(ctx)
+	zid, err := next.CreateZettel(ctx, domain.Zettel{Meta: domain.NewMeta(domain.InvalidZettelID)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetMeta(ctx, zid); err != nil {
+		t.Errorf("Zettel of next place not found: %v", err)
+	}
+	if err := p.DeleteZettel(ctx, zid); err != nil {
+		t.Error(err)
+	}
+	if _, err := next.GetMeta(ctx, zid); !isUnknown(err) {
+		t.Errorf("Zettel of next place not deleted: %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package mirrorplace provides a zettel place that stores all zettel in a
+// primary place and replicates them to some secondary places.
+package mirrorplace
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+// secondary stores the replication state of a secondary place.
+type secondary struct {
+	place   place.Place
+	signal  chan struct{} // Signals new work, buffered
+	mx      sync.Mutex
+	pending map[domain.ZettelID]bool // Zettel to be replicated
+	resync  bool                     // All zettel must be checked
+	failed  bool                     // Last replication failed
+}
+
+func newSecondary(p place.Place) *secondary {
+	return &secondary{
+		place:   p,
+		signal:  make(chan struct{}, 1),
+		pending: make(map[domain.ZettelID]bool),
+	}
+}
+
+// requestSync schedules the replication of some zettel.
+func (sec *secondary) requestSync(zids ...domain.ZettelID) {
+	sec.mx.Lock()
+	for _, zid := range zids {
+		if zid.IsValid() {
+			sec.pending[zid] = true
+		}
+	}
+	sec.mx.Unlock()
+	sec.wakeup()
+}
+
+// requestResync schedules a check of all zettel. Every divergent zettel is
+// replicated.
+func (sec *secondary) requestResync() {
+	sec.mx.Lock()
+	sec.resync = true
+	sec.mx.Unlock()
+	sec.wakeup()
+}
+
+func (sec *secondary) wakeup() {
+	select {
+	case sec.signal <- struct{}{}:
+	default:
+	}
+}
+
+// takeWork returns the current work and resets it.
+func (sec *secondary) takeWork() (zids []domain.ZettelID, resync bool) {
+	sec.mx.Lock()
+	defer sec.mx.Unlock()
+	resync = sec.resync
+	sec.resync = false
+	if !resync {
+		zids = make([]domain.ZettelID, 0, len(sec.pending))
+		for zid := range sec.pending {
+			zids = append(zids, zid)
+		}
+	}
+	sec.pending = make(map[domain.ZettelID]bool)
+	return zids, resync
+}
+
+// replicate is the worker that replicates all changes to a secondary place.
+// If the replication fails, it is retried later.
+func (mp *mirrorPlace) replicate(sec *secondary, done <-chan struct{}) {
+	defer mp.wg.Done()
+	var checkC <-chan time.Time
+	if mp.check > 0 {
+		ticker := time.NewTicker(mp.check)
+		defer ticker.Stop()
+		checkC = ticker.C
+	}
+	var retryC <-chan time.Time
+	for {
+		select {
+		case <-done:
+			return
+		case <-sec.signal:
+		case <-retryC:
+			retryC = nil
+		case <-checkC:
+			sec.requestResync()
+			continue
+		}
+		if retryC != nil {
+			// Wait for the retry, to not overload a failing place.
+			continue
+		}
+
+		ctx := context.Background()
+		mp.mxReload.RLock()
+		zids, resync := sec.takeWork()
+		var err error
+		if resync {
+			err = mp.resync(ctx, sec)
+		} else {
+			err = mp.syncZettel(ctx, sec, zids)
+		}
+		mp.mxReload.RUnlock()
+		if err != nil {
+			if !sec.failed {
+				log.Println("MIRRORPLACE", "ERROR", sec.place.Location(), err)
+			}
+			sec.failed = true
+			retryC = time.After(mp.retry)
+		} else if sec.failed {
+			log.Println("MIRRORPLACE", "RECOVERED", sec.place.Location())
+			sec.failed = false
+		}
+	}
+}
+
+// syncZettel replicates the given zettel. Zettel that could not be
+// replicated are scheduled again.
+func (mp *mirrorPlace) syncZettel(ctx context.Context, sec *secondary, zids []domain.ZettelID) error {
+	for i, zid := range zids {
+		if _, err := mp.syncOne(ctx, sec, zid); err != nil {
+			sec.requestSync(zids[i:]...)
+			return err
+		}
+	}
+	return nil
+}
+
+// resync checks all zettel of the primary and the secondary place, and
+// replicates every divergent zettel. The number of divergent zettel is
+// reported.
+func (mp *mirrorPlace) resync(ctx context.Context, sec *secondary) error {
+	primaryList, err := mp.primary.SelectMeta(ctx, nil, nil)
+	if err != nil {
+		sec.requestResync()
+		return err
+	}
+	secondaryList, err := sec.place.SelectMeta(ctx, nil, nil)
+	if err != nil {
+		sec.requestResync()
+		return err
+	}
+	zids := make(map[domain.ZettelID]bool, len(primaryList))
+	for _, meta := range primaryList {
+		zids[meta.Zid] = true
+	}
+	for _, meta := range secondaryList {
+		zids[meta.Zid] = true
+	}
+	divergent := 0
+	for zid := range zids {
+		changed, err := mp.syncOne(ctx, sec, zid)
+		if err != nil {
+			sec.requestResync()
+			return err
+		}
+		if changed {
+			divergent++
+		}
+	}
+	if divergent > 0 {
+		log.Println("MIRRORPLACE", "DIVERGED", sec.place.Location(), divergent, "zettel resynchronized")
+	}
+	return nil
+}
+
+// syncOne replicates a zettel of the primary place to a secondary place, if
+// they differ. It returns true, if the secondary place was changed.
+func (mp *mirrorPlace) syncOne(ctx context.Context, sec *secondary, zid domain.ZettelID) (bool, error) {
+	zettel, err := mp.primary.GetZettel(ctx, zid)
+	if err != nil {
+		if !isUnknown(err) {
+			return false, err
+		}
+		if _, err = sec.place.GetMeta(ctx, zid); err != nil {
+			if isUnknown(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		if err = sec.place.DeleteZettel(ctx, zid); err != nil && !isUnknown(err) {
+			return false, err
+		}
+		return true, nil
+	}
+	zettel = stripComputed(zettel)
+	old, err := sec.place.GetZettel(ctx, zid)
+	if err == nil && stripComputed(old).Equal(zettel) {
+		return false, nil
+	}
+	if err != nil && !isUnknown(err) {
+		return false, err
+	}
+	return true, sec.place.UpdateZettel(ctx, zettel)
+}
+
+// stripComputed returns the zettel without any computed meta values, which
+// may differ between places.
+func stripComputed(zettel domain.Zettel) domain.Zettel {
+	var meta *domain.Meta
+	for _, p := range zettel.Meta.Pairs() {
+		if domain.IsComputedKey(p.Key) {
+			if meta == nil {
+				meta = zettel.Meta.Clone()
+			}
+			meta.Delete(p.Key)
+		}
+	}
+	if meta != nil {
+		zettel.Meta = meta
+	}
+	return zettel
+}
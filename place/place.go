@@ -22,20 +22,58 @@ package place
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"net/url"
 	"sort"
+	"strconv"
+	"time"
 
 	"zettelstore.de/z/domain"
 )
 
+// ChangeReason gives an indication, why the ObserverFunc was called.
+type ChangeReason int
+
+// Values for ChangeReason
+const (
+	_        ChangeReason = iota
+	OnReload              // Place was reloaded, all zettel are possibly changed
+	OnCreate              // A new zettel was created
+	OnUpdate              // A zettel was changed
+	OnDelete              // A zettel was removed
+	OnRename              // A zettel got a new zettel id
+)
+
+// String returns a textual representation of the change reason.
+func (cr ChangeReason) String() string {
+	switch cr {
+	case OnReload:
+		return "reload"
+	case OnCreate:
+		return "create"
+	case OnUpdate:
+		return "update"
+	case OnDelete:
+		return "delete"
+	case OnRename:
+		return "rename"
+	}
+	return "unknown"
+}
+
+// ChangeInfo contains all the data for a changed zettel.
+type ChangeInfo struct {
+	Reason ChangeReason
+	Zid    domain.ZettelID // Invalid, if Reason is OnReload
+	NewZid domain.ZettelID // Only valid, if Reason is OnRename
+}
+
 // ObserverFunc is the function that will be called if something changed.
-// If the first parameter, a bool, is true, then all zettel are possibly
-// changed. If it has the value false, the given ZettelID will identify the
-// changed zettel.
-type ObserverFunc func(bool, domain.ZettelID)
+type ObserverFunc func(ChangeInfo)
 
 // Place is implemented by all Zettel places.
 type Place interface {
@@ -97,6 +135,182 @@ type Place interface {
 	Reload(ctx context.Context) error
 }
 
+// Historian is implemented by places that store prior versions of their
+// zettel.
+type Historian interface {
+	// HasHistory returns true, if prior versions are actually stored. Places
+	// that just forward to other places may not store them.
+	HasHistory() bool
+
+	// GetHistory returns all stored prior versions of a zettel, newest first.
+	GetHistory(ctx context.Context, zid domain.ZettelID) ([]ZettelVersion, error)
+
+	// GetZettelVersion retrieves a prior version of a zettel.
+	GetZettelVersion(ctx context.Context, zid domain.ZettelID, version string) (domain.Zettel, error)
+}
+
+// HasHistory returns true, if the given place stores prior versions of its
+// zettel.
+func HasHistory(p Place) bool {
+	h, ok := p.(Historian)
+	return ok && h.HasHistory()
+}
+
+// ZettelVersion describes a stored prior version of a zettel.
+type ZettelVersion struct {
+	Version string       // Identification of the version, unique for one zettel
+	Time    time.Time    // Time when the version was replaced by a newer one
+	Meta    *domain.Meta // Meta data of the version, nil if not available
+}
+
+// Trash is implemented by places that move deleted zettel into a trash, from
+// where they can be restored for some time.
+type Trash interface {
+	// HasTrash returns true, if deleted zettel are actually moved into a
+	// trash. Places that just forward to other places may not have a trash.
+	HasTrash() bool
+
+	// CanTrashZettel returns true, if deleting the zettel moves it into the
+	// trash.
+	CanTrashZettel(ctx context.Context, zid domain.ZettelID) bool
+
+	// GetTrash returns all deleted zettel that are still in the trash, most
+	// recently deleted first.
+	GetTrash(ctx context.Context) ([]TrashEntry, error)
+
+	// RestoreZettel moves a deleted zettel from the trash back to its
+	// original zettel id.
+	RestoreZettel(ctx context.Context, zid domain.ZettelID) error
+}
+
+// HasTrash returns true, if the given place moves deleted zettel into a trash.
+func HasTrash(p Place) bool {
+	t, ok := p.(Trash)
+	return ok && t.HasTrash()
+}
+
+// CanTrashZettel returns true, if the given place moves the zettel into a
+// trash when it is deleted.
+func CanTrashZettel(ctx context.Context, p Place, zid domain.ZettelID) bool {
+	t, ok := p.(Trash)
+	return ok && t.CanTrashZettel(ctx, zid)
+}
+
+// TrashEntry describes a deleted zettel within the trash.
+type TrashEntry struct {
+	Meta      *domain.Meta // Meta data of the deleted zettel
+	Deleted   time.Time    // Time of deletion
+	DeletedBy string       // Identification of the deleting user, if known
+}
+
+// Journal is implemented by places that record every change of their zettel.
+type Journal interface {
+	// HasJournal returns true, if changes are actually recorded. Places that
+	// just forward to other places may not have a journal.
+	HasJournal() bool
+
+	// GetJournal returns all recorded changes of the given zettel, newest
+	// first. If the zettel id is invalid, the changes of all zettel are
+	// returned.
+	GetJournal(ctx context.Context, zid domain.ZettelID) ([]JournalEntry, error)
+}
+
+// HasJournal returns true, if the given place records all changes.
+func HasJournal(p Place) bool {
+	j, ok := p.(Journal)
+	return ok && j.HasJournal()
+}
+
+// JournalEntry describes a recorded change of a zettel.
+type JournalEntry struct {
+	Time   time.Time       // Time of the change
+	User   domain.ZettelID // Zettel id of the changing user, invalid if not known
+	Op     ChangeReason    // Kind of change: OnCreate, OnUpdate, OnDelete, OnRename
+	Zid    domain.ZettelID // Zettel that was changed
+	NewZid domain.ZettelID // Only valid, if Op is OnRename
+	Hash   string          // SHA-256 of the new content, only for OnCreate and OnUpdate
+}
+
+// Versioner is implemented by places that compute a version token for each
+// of their zettel. The token changes whenever the stored zettel changes. It
+// allows to update a zettel only if it was not changed in the meantime.
+type Versioner interface {
+	// GetVersionedZettel retrieves a specific zettel, together with the
+	// version token of its current state.
+	GetVersionedZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, string, error)
+
+	// UpdateVersionedZettel updates an existing zettel, but only if its
+	// current version token is equal to the given one. Checking the version
+	// and updating the zettel is done atomically.
+	UpdateVersionedZettel(ctx context.Context, zettel domain.Zettel, version string) error
+}
+
+// CalcVersion computes a version token from the data of a zettel. It is used
+// by places that do not store an own version of their zettel.
+func CalcVersion(zettel domain.Zettel) string {
+	h := fnv.New64a()
+	zettel.Meta.Write(h)
+	h.Write([]byte{0})
+	h.Write(zettel.Content.AsBytes())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GetVersionedZettel retrieves a zettel of the given place, together with its
+// version token. If the place does not compute version tokens, the token is
+// calculated from the zettel data.
+func GetVersionedZettel(ctx context.Context, p Place, zid domain.ZettelID) (domain.Zettel, string, error) {
+	if v, ok := p.(Versioner); ok {
+		return v.GetVersionedZettel(ctx, zid)
+	}
+	zettel, err := p.GetZettel(ctx, zid)
+	if err != nil {
+		return domain.Zettel{}, "", err
+	}
+	return zettel, CalcVersion(zettel), nil
+}
+
+// UpdateVersionedZettel updates a zettel of the given place, if its current
+// version token is equal to the given one. If the place does not compute
+// version tokens, checking and updating is not atomic.
+func UpdateVersionedZettel(ctx context.Context, p Place, zettel domain.Zettel, version string) error {
+	if v, ok := p.(Versioner); ok {
+		return v.UpdateVersionedZettel(ctx, zettel, version)
+	}
+	if err := CheckVersion(ctx, p, zettel.Meta.Zid, version); err != nil {
+		return err
+	}
+	return p.UpdateZettel(ctx, zettel)
+}
+
+// CheckVersion returns an ErrVersionConflict, if the current version token of
+// the given zettel is not equal to the given one.
+func CheckVersion(ctx context.Context, p Place, zid domain.ZettelID, version string) error {
+	_, curVersion, err := GetVersionedZettel(ctx, p, zid)
+	if err != nil {
+		return err
+	}
+	if curVersion != version {
+		return &ErrVersionConflict{Zid: zid, Version: curVersion}
+	}
+	return nil
+}
+
+// Checker is implemented by places that are able to check the consistency
+// of their stored zettel.
+type Checker interface {
+	// Check returns all problems found. If fix is true, problems that can be
+	// repaired without losing data are fixed.
+	Check(ctx context.Context, fix bool) ([]Problem, error)
+}
+
+// Problem describes an inconsistency found by a Checker.
+type Problem struct {
+	Zid     domain.ZettelID // Zettel with the problem, invalid if not known
+	Path    string          // Location of the problem, e.g. a file name
+	Message string          // Description of the problem
+	Fixed   bool            // Problem was repaired
+}
+
 // ErrNotAuthorized is returned if the caller has no authorization to perform the operation.
 type ErrNotAuthorized struct {
 	Op   string
@@ -147,11 +361,25 @@ func IsAuthError(err error) bool {
 // ErrStopped is returned if calling methods on a place that was not started.
 var ErrStopped = errors.New("Place is stopped")
 
+// ErrReadOnly is returned if there is an attempt to write to a read-only place.
+var ErrReadOnly = errors.New("Read-only place")
+
 // ErrUnknownID is returned if the zettel id is unknown to the place.
 type ErrUnknownID struct{ Zid domain.ZettelID }
 
 func (err *ErrUnknownID) Error() string { return "Unknown Zettel id: " + err.Zid.Format() }
 
+// ErrVersionConflict is returned if a zettel was changed by someone else since
+// the given version was retrieved.
+type ErrVersionConflict struct {
+	Zid     domain.ZettelID
+	Version string // The current version of the zettel.
+}
+
+func (err *ErrVersionConflict) Error() string {
+	return fmt.Sprintf("zettel %v was changed in the meantime", err.Zid.Format())
+}
+
 // ErrInvalidID is returned if the zettel id is not appropriate for the place operation.
 type ErrInvalidID struct{ Zid domain.ZettelID }
 
@@ -160,7 +388,12 @@ func (err *ErrInvalidID) Error() string { return "Invalid Zettel id: " + err.Zid
 // Filter specifies a mechanism for selecting zettel.
 type Filter struct {
 	Expr   FilterExpr
-	Negate bool
+	Negate bool   // Negate the conditions of Expr and Query
+	Query  *Query // Parsed query, and-ed with Expr
+
+	// MatchContent, if set, checks whether the content of a zettel contains
+	// the given word. It is used in addition to searching all meta values.
+	MatchContent func(zid domain.ZettelID, word string) bool
 }
 
 // FilterExpr is the encoding of a search filter.
@@ -206,6 +439,37 @@ func Register(scheme string, create createFunc) {
 	registry[scheme] = create
 }
 
+// GetQueryInt returns the integer value of the given key of the URL query,
+// limited to the range from min to max. If there is no valid value, def is
+// returned.
+func GetQueryInt(u *url.URL, key string, min, def, max int) int {
+	sVal := u.Query().Get(key)
+	if sVal == "" {
+		return def
+	}
+	iVal, err := strconv.Atoi(sVal)
+	if err != nil {
+		return def
+	}
+	if iVal < min {
+		return min
+	}
+	if iVal > max {
+		return max
+	}
+	return iVal
+}
+
+// GetQueryBool returns the boolean value of the given key of the URL query.
+// If there is no value, def is returned.
+func GetQueryBool(u *url.URL, key string, def bool) bool {
+	sVal := u.Query().Get(key)
+	if sVal == "" {
+		return def
+	}
+	return domain.BoolValue(sVal)
+}
+
 // GetSchemes returns all registered scheme, ordered by scheme string.
 func GetSchemes() []string {
 	result := make([]string, 0, len(registry))
@@ -0,0 +1,46 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package place provides a generic interface to zettel places.
+package place
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestGetQueryInt(t *testing.T) {
+	testcases := []struct {
+		query string
+		exp   int
+	}{
+		{"", 5},
+		{"n=", 5},
+		{"n=abc", 5},
+		{"n=7", 7},
+		{"n=0", 1},
+		{"n=11", 10},
+	}
+	for i, tc := range testcases {
+		u := &url.URL{RawQuery: tc.query}
+		if got := GetQueryInt(u, "n", 1, 5, 10); got != tc.exp {
+			t.Errorf("TC=%d, query=%q: exp=%d, got=%d", i, tc.query, tc.exp, got)
+		}
+	}
+}
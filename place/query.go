@@ -0,0 +1,539 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package place provides a generic interface to zettel places.
+package place
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"zettelstore.de/z/domain"
+)
+
+// Query is a parsed query. It forms an expression tree of conditions that a
+// zettel must fulfil to be selected.
+type Query struct {
+	src  string
+	root queryNode
+}
+
+// String returns the source of the query.
+func (q *Query) String() string { return q.src }
+
+// IsEmpty returns true, if the query does not contain any condition.
+func (q *Query) IsEmpty() bool { return q == nil || q.root == nil }
+
+// QueryError is returned, if a query could not be parsed.
+type QueryError struct {
+	Pos int // Byte position of the error within the query
+	Msg string
+}
+
+func (err *QueryError) Error() string {
+	return fmt.Sprintf("query error at position %d: %s", err.Pos+1, err.Msg)
+}
+
+// ParseQuery parses the given string as a query.
+//
+// A query is a sequence of terms, which are and-ed, unless they are separated
+// by "OR". An "AND" may be given explicitly. "AND" binds stronger than "OR".
+// A term is negated by a prefix "-" or by "NOT". Parentheses group terms.
+// A term is either a word, which is searched in all meta data values, or a
+// comparison of a meta key with a value. "key:value" matches according to the
+// type of the key, an empty value just checks for the existence of the key.
+// Other comparisons are "key=value", "key!=value", "key<value",
+// "key<=value", "key>value", and "key>=value". Words and values may be
+// enclosed in double quotes to include spaces.
+func ParseQuery(src string) (*Query, error) {
+	p := queryParser{src: src}
+	p.next()
+	if p.tok.kind == tokEOF {
+		return &Query{src: src}, nil
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected()
+	}
+	return &Query{src: src, root: node}, nil
+}
+
+// ---------- Expression tree -------------------------------------------------
+
+// contentFunc checks whether the content of a zettel matches a word.
+type contentFunc func(zid domain.ZettelID, word string) bool
+
+type queryNode interface {
+	compile(matchContent contentFunc) FilterFunc
+}
+
+type andNode []queryNode
+
+func (an andNode) compile(matchContent contentFunc) FilterFunc {
+	funcs := compileNodes(an, matchContent)
+	return func(meta *domain.Meta) bool {
+		for _, f := range funcs {
+			if !f(meta) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+type orNode []queryNode
+
+func (on orNode) compile(matchContent contentFunc) FilterFunc {
+	funcs := compileNodes(on, matchContent)
+	return func(meta *domain.Meta) bool {
+		for _, f := range funcs {
+			if f(meta) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func compileNodes(nodes []queryNode, matchContent contentFunc) []FilterFunc {
+	funcs := make([]FilterFunc, 0, len(nodes))
+	for _, node := range nodes {
+		funcs = append(funcs, node.compile(matchContent))
+	}
+	return funcs
+}
+
+type notNode struct{ node queryNode }
+
+func (nn notNode) compile(matchContent contentFunc) FilterFunc {
+	f := nn.node.compile(matchContent)
+	return func(meta *domain.Meta) bool { return !f(meta) }
+}
+
+// wordNode matches if any of its values is found in a meta data value, or in
+// the content of the zettel.
+type wordNode []string
+
+func (wn wordNode) compile(matchContent contentFunc) FilterFunc {
+	searchAll := createSearchAllFunc(wn)
+	if matchContent == nil {
+		return searchAll
+	}
+	return func(meta *domain.Meta) bool {
+		if searchAll(meta) {
+			return true
+		}
+		for _, value := range wn {
+			if matchContent(meta.Zid, contentWord(value)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// contentWord returns the value as a word for a content search. Values with
+// spaces are searched as a phrase.
+func contentWord(value string) string {
+	if strings.IndexFunc(value, unicode.IsSpace) >= 0 {
+		return "\"" + value + "\""
+	}
+	return value
+}
+
+// Operators to compare a meta value.
+const (
+	opMatch        = ":"
+	opEqual        = "="
+	opNotEqual     = "!="
+	opLess         = "<"
+	opLessEqual    = "<="
+	opGreater      = ">"
+	opGreaterEqual = ">="
+)
+
+// Operators sorted by length, so that the longest operator is recognized first.
+var queryOperators = []string{
+	opNotEqual, opLessEqual, opGreaterEqual, opMatch, opEqual, opLess, opGreater}
+
+type matchNode struct {
+	key    string
+	op     string
+	values []string // or-ed values
+}
+
+func (mn *matchNode) compile(matchContent contentFunc) FilterFunc {
+	key := mn.key
+	switch mn.op {
+	case opMatch:
+		if isEmptySlice(mn.values) {
+			return func(meta *domain.Meta) bool {
+				_, ok := meta.Get(key)
+				return ok
+			}
+		}
+		match := createMatchFunc(key, mn.values)
+		return func(meta *domain.Meta) bool {
+			value, ok := meta.Get(key)
+			return ok && match(value)
+		}
+	case opEqual, opNotEqual:
+		negate := mn.op == opNotEqual
+		if keyType := domain.KeyType(key); isOrderedType(keyType) {
+			want := mn.values[0]
+			return func(meta *domain.Meta) bool {
+				value, ok := meta.Get(key)
+				return (ok && compareValues(keyType, value, want) == 0) != negate
+			}
+		}
+		want := strings.ToLower(mn.values[0])
+		return func(meta *domain.Meta) bool {
+			value, ok := meta.Get(key)
+			return (ok && strings.ToLower(value) == want) != negate
+		}
+	}
+	check := compareCheck(mn.op)
+	keyType := domain.KeyType(key)
+	want := mn.values[0]
+	return func(meta *domain.Meta) bool {
+		value, ok := meta.Get(key)
+		return ok && check(compareValues(keyType, value, want))
+	}
+}
+
+func compareCheck(op string) func(int) bool {
+	switch op {
+	case opLess:
+		return func(cmp int) bool { return cmp < 0 }
+	case opLessEqual:
+		return func(cmp int) bool { return cmp <= 0 }
+	case opGreater:
+		return func(cmp int) bool { return cmp > 0 }
+	case opGreaterEqual:
+		return func(cmp int) bool { return cmp >= 0 }
+	}
+	panic("Unknown compare operator " + op)
+}
+
+// isOrderedType returns true, if values of the given key type are compared by
+// their meaning, not by their textual representation.
+func isOrderedType(keyType byte) bool {
+	return keyType == domain.MetaTypeNumber || keyType == domain.MetaTypeTimestamp
+}
+
+// compareValues compares two meta values of a key with the given type.
+// Timestamps are compared chronologically, up to the precision of the less
+// precise value. Numbers are compared numerically, all other values are
+// compared lexically, ignoring case.
+func compareValues(keyType byte, left, right string) int {
+	if keyType == domain.MetaTypeTimestamp {
+		if l, ok := domain.NormalizeTimestamp(left); ok {
+			if r, ok := domain.NormalizeTimestamp(right); ok {
+				n := len(l)
+				if len(r) < n {
+					n = len(r)
+				}
+				return strings.Compare(l[:n], r[:n])
+			}
+		}
+	}
+	if l, ok := domain.NumberValue(left); ok {
+		if r, ok := domain.NumberValue(right); ok {
+			switch {
+			case l < r:
+				return -1
+			case l > r:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(strings.ToLower(left), strings.ToLower(right))
+}
+
+// collectWords returns all values of the expression tree that are searched
+// in all meta data values.
+func collectWords(node queryNode, words []string) []string {
+	switch n := node.(type) {
+	case andNode:
+		for _, sub := range n {
+			words = collectWords(sub, words)
+		}
+	case orNode:
+		for _, sub := range n {
+			words = collectWords(sub, words)
+		}
+	case notNode:
+		words = collectWords(n.node, words)
+	case wordNode:
+		for _, value := range n {
+			words = append(words, contentWord(value))
+		}
+	}
+	return words
+}
+
+// ---------- Parser ----------------------------------------------------------
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokLeft
+	tokRight
+	tokAnd
+	tokOr
+	tokNot
+	tokWord
+	tokMatch
+	tokError
+)
+
+type queryToken struct {
+	kind  tokenKind
+	pos   int
+	key   string
+	op    string
+	value string
+}
+
+type queryParser struct {
+	src string
+	pos int
+	tok queryToken
+}
+
+func (p *queryParser) errorAt(pos int, msg string) *QueryError {
+	return &QueryError{Pos: pos, Msg: msg}
+}
+
+func (p *queryParser) unexpected() error {
+	switch p.tok.kind {
+	case tokError:
+		return p.errorAt(p.tok.pos, p.tok.value)
+	case tokEOF:
+		return p.errorAt(p.tok.pos, "unexpected end of query")
+	}
+	return p.errorAt(p.tok.pos, fmt.Sprintf("unexpected %q", p.src[p.tok.pos:p.pos]))
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokOr {
+		return node, nil
+	}
+	result := orNode{node}
+	for p.tok.kind == tokOr {
+		p.next()
+		node, err = p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, node)
+	}
+	return result, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	var result andNode
+	for {
+		switch p.tok.kind {
+		case tokEOF, tokRight, tokOr:
+			if result == nil {
+				return node, nil
+			}
+			return result, nil
+		case tokAnd:
+			p.next()
+		}
+		next, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			result = andNode{node}
+		}
+		result = append(result, next)
+	}
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	if p.tok.kind == tokNot {
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokLeft:
+		p.next()
+		if p.tok.kind == tokRight {
+			return nil, p.errorAt(tok.pos, "empty parentheses")
+		}
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRight {
+			return nil, p.errorAt(tok.pos, "missing closing parenthesis")
+		}
+		p.next()
+		return node, nil
+	case tokWord:
+		if tok.value == "" {
+			return nil, p.errorAt(tok.pos, "empty phrase")
+		}
+		p.next()
+		return wordNode{tok.value}, nil
+	case tokMatch:
+		p.next()
+		return &matchNode{key: tok.key, op: tok.op, values: []string{tok.value}}, nil
+	}
+	return nil, p.unexpected()
+}
+
+// next scans the next token of the query.
+func (p *queryParser) next() {
+	src := p.src
+	for p.pos < len(src) && isSpace(src[p.pos]) {
+		p.pos++
+	}
+	start := p.pos
+	p.tok = queryToken{pos: start}
+	if start >= len(src) {
+		p.tok.kind = tokEOF
+		return
+	}
+	switch ch := src[start]; ch {
+	case '(':
+		p.pos++
+		p.tok.kind = tokLeft
+		return
+	case ')':
+		p.pos++
+		p.tok.kind = tokRight
+		return
+	case '-':
+		if start+1 < len(src) && !isSpace(src[start+1]) && src[start+1] != ')' {
+			p.pos++
+			p.tok.kind = tokNot
+			return
+		}
+	case '"':
+		p.tok.kind = tokWord
+		p.scanQuoted()
+		return
+	}
+
+	keyEnd := start
+	for keyEnd < len(src) && isKeyChar(src[keyEnd]) {
+		keyEnd++
+	}
+	if key := src[start:keyEnd]; domain.KeyIsValid(key) {
+		for _, op := range queryOperators {
+			if strings.HasPrefix(src[keyEnd:], op) {
+				p.scanMatch(key, op, keyEnd+len(op))
+				return
+			}
+		}
+	}
+
+	p.pos = p.scanWordEnd(start)
+	switch word := src[start:p.pos]; word {
+	case "AND":
+		p.tok.kind = tokAnd
+	case "OR":
+		p.tok.kind = tokOr
+	case "NOT":
+		p.tok.kind = tokNot
+	default:
+		p.tok.kind = tokWord
+		p.tok.value = word
+	}
+}
+
+func (p *queryParser) scanMatch(key, op string, pos int) {
+	p.tok.kind = tokMatch
+	p.tok.key = key
+	p.tok.op = op
+	p.pos = pos
+	if pos < len(p.src) && p.src[pos] == '"' {
+		p.scanQuoted()
+		if p.tok.kind == tokError {
+			return
+		}
+	} else {
+		p.pos = p.scanWordEnd(pos)
+		p.tok.value = p.src[pos:p.pos]
+	}
+	if p.tok.value == "" && op != opMatch {
+		p.tok.kind = tokError
+		p.tok.value = fmt.Sprintf("missing value after %q", key+op)
+	}
+}
+
+// scanQuoted scans a value enclosed in double quotes, starting at the current
+// position.
+func (p *queryParser) scanQuoted() {
+	start := p.pos
+	end := strings.IndexByte(p.src[start+1:], '"')
+	if end < 0 {
+		p.pos = len(p.src)
+		p.tok.kind = tokError
+		p.tok.pos = start
+		p.tok.value = "missing closing quote"
+		return
+	}
+	p.tok.value = p.src[start+1 : start+1+end]
+	p.pos = start + end + 2
+}
+
+func (p *queryParser) scanWordEnd(pos int) int {
+	for pos < len(p.src) {
+		if ch := p.src[pos]; isSpace(ch) || ch == '(' || ch == ')' {
+			break
+		}
+		pos++
+	}
+	return pos
+}
+
+func isSpace(ch byte) bool { return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' }
+
+func isKeyChar(ch byte) bool {
+	return ('0' <= ch && ch <= '9') || ('a' <= ch && ch <= 'z') || ch == '-'
+}
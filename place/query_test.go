@@ -0,0 +1,130 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package place_test provides some tests for the query language.
+package place_test
+
+import (
+	"testing"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+func newTestMeta(zid domain.ZettelID, pairs ...string) *domain.Meta {
+	meta := domain.NewMeta(zid)
+	for i := 0; i < len(pairs); i += 2 {
+		meta.Set(pairs[i], pairs[i+1])
+	}
+	return meta
+}
+
+var testMetas = []*domain.Meta{
+	newTestMeta(20200101000000, "title", "Project plan", "tags", "#project #plan", "role", "zettel"),
+	newTestMeta(20200202000000, "title", "Some task", "tags", "#project", "role", "task"),
+	newTestMeta(20200303000000, "title", "Draft of a task", "role", "task"),
+	newTestMeta(20200404000000, "title", "Unrelated", "role", "zettel", "lang", "de"),
+}
+
+func selectZids(t *testing.T, query string) []domain.ZettelID {
+	t.Helper()
+	q, err := place.ParseQuery(query)
+	if err != nil {
+		t.Errorf("query %q: unexpected error %v", query, err)
+		return nil
+	}
+	match := place.CreateFilterFunc(&place.Filter{Query: q})
+	var result []domain.ZettelID
+	for _, meta := range testMetas {
+		if match(meta) {
+			result = append(result, meta.Zid)
+		}
+	}
+	return result
+}
+
+func TestQuery(t *testing.T) {
+	testcases := []struct {
+		query string
+		exp   []domain.ZettelID
+	}{
+		{"", []domain.ZettelID{20200101000000, 20200202000000, 20200303000000, 20200404000000}},
+		{"task", []domain.ZettelID{20200202000000, 20200303000000}},
+		{"tags:#project", []domain.ZettelID{20200101000000, 20200202000000}},
+		{"tags:#project -role:task", []domain.ZettelID{20200101000000}},
+		{"tags:#project AND NOT role:task", []domain.ZettelID{20200101000000}},
+		{"tags:#project AND NOT role:task OR title:draft", []domain.ZettelID{20200101000000, 20200303000000}},
+		{"tags:#project (role:zettel OR title:draft)", []domain.ZettelID{20200101000000}},
+		{"\"some task\"", []domain.ZettelID{20200202000000}},
+		{"title:\"of a\"", []domain.ZettelID{20200303000000}},
+		{"title=unrelated", []domain.ZettelID{20200404000000}},
+		{"role!=task", []domain.ZettelID{20200101000000, 20200404000000}},
+		{"lang:", []domain.ZettelID{20200404000000}},
+		{"-tags:", []domain.ZettelID{20200303000000, 20200404000000}},
+		{"id>20200202000000", []domain.ZettelID{20200303000000, 20200404000000}},
+		{"id>=20200202000000 id<20200404000000", []domain.ZettelID{20200202000000, 20200303000000}},
+		{"title<p", []domain.ZettelID{20200303000000}},
+	}
+	for i, tc := range testcases {
+		got := selectZids(t, tc.query)
+		if len(got) != len(tc.exp) {
+			t.Errorf("TC=%d, query=%q, exp=%v, got=%v", i, tc.query, tc.exp, got)
+			continue
+		}
+		for j := range got {
+			if got[j] != tc.exp[j] {
+				t.Errorf("TC=%d, query=%q, exp=%v, got=%v", i, tc.query, tc.exp, got)
+				break
+			}
+		}
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	testcases := []struct {
+		query string
+		pos   int
+	}{
+		{"(", 1},
+		{"()", 0},
+		{"(a", 0},
+		{"a)", 1},
+		{"a OR", 4},
+		{"NOT", 3},
+		{"\"abc", 0},
+		{"title:\"abc", 6},
+		{"id>", 0},
+		{"\"\"", 0},
+	}
+	for i, tc := range testcases {
+		_, err := place.ParseQuery(tc.query)
+		if err == nil {
+			t.Errorf("TC=%d, query=%q: error expected", i, tc.query)
+			continue
+		}
+		qe, ok := err.(*place.QueryError)
+		if !ok {
+			t.Errorf("TC=%d, query=%q: QueryError expected, but got %T", i, tc.query, err)
+			continue
+		}
+		if qe.Pos != tc.pos {
+			t.Errorf("TC=%d, query=%q: error position %d expected, but got %d (%v)", i, tc.query, tc.pos, qe.Pos, qe)
+		}
+	}
+}
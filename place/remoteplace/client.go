@@ -0,0 +1,263 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package remoteplace provides a zettel place that is stored in another
+// Zettelstore, accessed via its web API.
+package remoteplace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+// tokenJSON is the answer of the remote Zettelstore to an authentication
+// request.
+type tokenJSON struct {
+	Token   string `json:"access_token"`
+	Expires int    `json:"expires_in"`
+}
+
+// getToken returns a valid access token, or an empty string if the place
+// does not use authentication. The token is renewed after half of its
+// lifetime.
+func (rp *remotePlace) getToken(ctx context.Context) (string, error) {
+	if rp.ident == "" {
+		return "", nil
+	}
+	rp.mxAuth.Lock()
+	defer rp.mxAuth.Unlock()
+	if rp.token != "" {
+		if time.Now().Before(rp.tokenRenew) {
+			return rp.token, nil
+		}
+		if err := rp.authenticate(ctx, true); err == nil {
+			return rp.token, nil
+		}
+	}
+	if err := rp.authenticate(ctx, false); err != nil {
+		return "", err
+	}
+	return rp.token, nil
+}
+
+// clearToken forgets the given token, if it was not replaced in the meantime.
+func (rp *remotePlace) clearToken(token string) {
+	rp.mxAuth.Lock()
+	if rp.token == token {
+		rp.token = ""
+	}
+	rp.mxAuth.Unlock()
+}
+
+// authenticate obtains a new token from the remote Zettelstore. If renew is
+// set, the current token is renewed, otherwise the credentials are sent.
+// The caller must hold the lock mxAuth.
+func (rp *remotePlace) authenticate(ctx context.Context, renew bool) error {
+	var req *http.Request
+	var err error
+	if renew {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPut, rp.base+"/a?_format=json", nil)
+		if err == nil {
+			req.Header.Set("Authorization", "Bearer "+rp.token)
+		}
+	} else {
+		form := url.Values{"username": {rp.ident}, "password": {rp.password}}
+		req, err = http.NewRequestWithContext(
+			ctx, http.MethodPost, rp.base+"/a?_format=json", strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	rp.token = ""
+	resp, err := rp.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return place.NewErrNotAuthorized("Authenticate", nil, domain.InvalidZettelID)
+	}
+	var tj tokenJSON
+	if err := json.NewDecoder(resp.Body).Decode(&tj); err != nil {
+		return err
+	}
+	if tj.Token == "" {
+		return place.NewErrNotAuthorized("Authenticate", nil, domain.InvalidZettelID)
+	}
+	rp.token = tj.Token
+	rp.tokenRenew = time.Now().Add(time.Duration(tj.Expires) * time.Second / 2)
+	return nil
+}
+
+// do sends a request to the remote Zettelstore. If the request is rejected
+// because the token has expired, it is repeated once with a new token.
+func (rp *remotePlace) do(
+	ctx context.Context, client *http.Client, method, path string, query url.Values, header http.Header, body []byte,
+) (*http.Response, error) {
+	u := rp.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	for retry := true; ; retry = false {
+		token, err := rp.getToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var rd io.Reader
+		if body != nil {
+			rd = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, u, rd)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || token == "" || !retry {
+			return resp, nil
+		}
+		resp.Body.Close()
+		rp.clearToken(token)
+	}
+}
+
+// getJSON retrieves a JSON value from the remote Zettelstore.
+func (rp *remotePlace) getJSON(
+	ctx context.Context, op, path string, query url.Values, zid domain.ZettelID, v interface{},
+) error {
+	resp, err := rp.do(ctx, rp.client, http.MethodGet, path, query, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, op, zid); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// checkResponse translates the status code of a response into an error.
+func checkResponse(resp *http.Response, op string, zid domain.ZettelID) error {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent, http.StatusFound:
+		return nil
+	case http.StatusNotFound:
+		return &place.ErrUnknownID{Zid: zid}
+	case http.StatusUnauthorized:
+		return place.NewErrNotAuthorized(op, nil, zid)
+	case http.StatusForbidden:
+		return place.ErrReadOnly
+	case http.StatusPreconditionFailed:
+		return &place.ErrVersionConflict{Zid: zid, Version: getETag(resp)}
+	}
+	var ej struct {
+		Error string `json:"error"`
+	}
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err := json.Unmarshal(data, &ej); err != nil || ej.Error == "" {
+		ej.Error = strings.TrimSpace(string(data))
+	}
+	return fmt.Errorf("remote zettelstore: %s: %s", resp.Status, ej.Error)
+}
+
+// getETag returns the entity tag of a response, without quotes.
+func getETag(resp *http.Response) string {
+	return strings.Trim(strings.TrimPrefix(resp.Header.Get("ETag"), "W/"), "\"")
+}
+
+// jsonHeader returns the header of a request that sends a JSON object.
+func jsonHeader() http.Header {
+	return http.Header{"Content-Type": {"application/json"}}
+}
+
+// zettelJSON is the structure of a zettel, when sent as a JSON object.
+type zettelJSON struct {
+	Meta    map[string]interface{} `json:"meta"`
+	Content string                 `json:"content"`
+}
+
+// metaJSON is the structure of the meta data of a zettel in a list.
+type metaJSON struct {
+	ID   string                 `json:"id"`
+	Meta map[string]interface{} `json:"meta"`
+}
+
+// makeMeta builds the meta data of a zettel that was received from the
+// remote Zettelstore. Values that are computed by the remote Zettelstore
+// are ignored, because they are computed here again. Credentials are
+// ignored too, they must never leave the remote Zettelstore.
+func makeMeta(zid domain.ZettelID, values map[string]interface{}) *domain.Meta {
+	meta := domain.NewMeta(zid)
+	for key, value := range values {
+		if key == domain.MetaKeyID || !domain.KeyIsValid(key) || domain.IsComputedKey(key) ||
+			domain.KeyType(key) == domain.MetaTypeCred {
+			continue
+		}
+		switch val := value.(type) {
+		case string:
+			meta.Set(key, val)
+		case []interface{}:
+			list := make([]string, 0, len(val))
+			for _, elem := range val {
+				if s, ok := elem.(string); ok {
+					list = append(list, s)
+				}
+			}
+			meta.SetList(key, list)
+		}
+	}
+	meta.Freeze()
+	return meta
+}
+
+// encodeZettel returns the JSON representation of a zettel.
+func encodeZettel(zettel domain.Zettel) ([]byte, error) {
+	zj := zettelJSON{
+		Meta:    make(map[string]interface{}),
+		Content: zettel.Content.AsString(),
+	}
+	for _, p := range zettel.Meta.Pairs() {
+		if p.Key != domain.MetaKeyID && !domain.IsComputedKey(p.Key) {
+			zj.Meta[p.Key] = p.Value
+		}
+	}
+	return json.Marshal(zj)
+}
@@ -0,0 +1,574 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package remoteplace provides a zettel place that is stored in another
+// Zettelstore, accessed via its web API.
+package remoteplace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+func init() {
+	place.Register("zs+http", newRemotePlace)
+	place.Register("zs+https", newRemotePlace)
+}
+
+func newRemotePlace(u *url.URL, next place.Place) (place.Place, error) {
+	if u.Host == "" {
+		return nil, &url.Error{Op: "parse", URL: u.Redacted(), Err: errNoHost}
+	}
+	base := url.URL{
+		Scheme: strings.TrimPrefix(u.Scheme, "zs+"),
+		Host:   u.Host,
+		Path:   strings.TrimSuffix(u.Path, "/"),
+	}
+	rp := remotePlace{
+		u:            u,
+		next:         next,
+		base:         base.String(),
+		client:       &http.Client{Timeout: time.Duration(place.GetQueryInt(u, "timeout", 1, 30, 600)) * time.Second},
+		streamClient: &http.Client{},
+		rescan:       time.Duration(place.GetQueryInt(u, "rescan", 1, 60, 24*60*60)) * time.Second,
+	}
+	if u.User != nil {
+		rp.ident = u.User.Username()
+		rp.password, _ = u.User.Password()
+	}
+
+	// A renamed zettel is signalled by a redirect, which must not be followed.
+	rp.client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return &rp, nil
+}
+
+var errNoHost = errors.New("missing host of remote Zettelstore")
+
+// maxSystemZid is the largest identifier of zettel that belong to the
+// software of a Zettelstore, like templates or the configuration. They are
+// never taken from the remote Zettelstore, otherwise they would replace the
+// ones of this Zettelstore.
+const maxSystemZid = domain.ZettelID(9999999999)
+
+// remotePlace uses the web API of another Zettelstore to store zettel.
+type remotePlace struct {
+	u            *url.URL
+	next         place.Place
+	base         string // URL of the remote Zettelstore, without trailing "/"
+	client       *http.Client
+	streamClient *http.Client // Used for the event stream, without timeout
+	rescan       time.Duration
+	ident        string
+	password     string
+
+	mxAuth     sync.Mutex
+	token      string
+	tokenRenew time.Time
+
+	mx      sync.RWMutex
+	started bool
+	done    chan struct{}
+	metas   map[domain.ZettelID]*domain.Meta // nil, if not loaded
+
+	mxObserver sync.RWMutex
+	observers  []place.ObserverFunc
+}
+
+func (rp *remotePlace) Next() place.Place { return rp.next }
+
+// Location returns some information where the place is located.
+func (rp *remotePlace) Location() string {
+	return rp.u.Redacted()
+}
+
+// Start the place. Now all other functions of the place are allowed.
+// Starting an already started place is not allowed.
+func (rp *remotePlace) Start(ctx context.Context) error {
+	if rp.isStarted() {
+		panic("Calling remoteplace.Start() twice.")
+	}
+	if rp.next != nil {
+		if err := rp.next.Start(ctx); err != nil {
+			return err
+		}
+	}
+	rp.mx.Lock()
+	rp.started = true
+	rp.metas = nil
+	rp.done = make(chan struct{})
+	go rp.watch(rp.done)
+	rp.mx.Unlock()
+	return nil
+}
+
+// Stop the started place. Now only the Start() function is allowed.
+func (rp *remotePlace) Stop(ctx context.Context) error {
+	rp.mx.Lock()
+	if !rp.started {
+		rp.mx.Unlock()
+		return place.ErrStopped
+	}
+	rp.started = false
+	rp.metas = nil
+	close(rp.done)
+	rp.mx.Unlock()
+	if rp.next != nil {
+		return rp.next.Stop(ctx)
+	}
+	return nil
+}
+
+func (rp *remotePlace) isStarted() bool {
+	rp.mx.RLock()
+	started := rp.started
+	rp.mx.RUnlock()
+	return started
+}
+
+func (rp *remotePlace) notifyChanged(ci place.ChangeInfo) {
+	rp.mxObserver.RLock()
+	observers := rp.observers
+	rp.mxObserver.RUnlock()
+	for _, ob := range observers {
+		ob(ci)
+	}
+}
+
+// RegisterChangeObserver registers an observer that will be notified
+// if one or all zettel of the remote Zettelstore are found to be changed.
+func (rp *remotePlace) RegisterChangeObserver(f place.ObserverFunc) {
+	if rp.next != nil {
+		rp.next.RegisterChangeObserver(f)
+	}
+	rp.mxObserver.Lock()
+	rp.observers = append(rp.observers, f)
+	rp.mxObserver.Unlock()
+}
+
+func (rp *remotePlace) CanCreateZettel(ctx context.Context) bool {
+	return rp.isStarted()
+}
+
+func (rp *remotePlace) CreateZettel(ctx context.Context, zettel domain.Zettel) (domain.ZettelID, error) {
+	if !rp.isStarted() {
+		return domain.InvalidZettelID, place.ErrStopped
+	}
+	body, err := encodeZettel(zettel)
+	if err != nil {
+		return domain.InvalidZettelID, err
+	}
+	resp, err := rp.do(ctx, rp.client, http.MethodPost, "/z", nil, jsonHeader(), body)
+	if err != nil {
+		return domain.InvalidZettelID, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, "Create", domain.InvalidZettelID); err != nil {
+		return domain.InvalidZettelID, err
+	}
+	var mj metaJSON
+	if err := json.NewDecoder(resp.Body).Decode(&mj); err != nil {
+		return domain.InvalidZettelID, err
+	}
+	zid, err := domain.ParseZettelID(mj.ID)
+	if err != nil {
+		return domain.InvalidZettelID, err
+	}
+	rp.changed(ctx, place.ChangeInfo{Reason: place.OnCreate, Zid: zid})
+	return zid, nil
+}
+
+// GetZettel retrieves a specific zettel.
+func (rp *remotePlace) GetZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, error) {
+	if !rp.isStarted() {
+		return domain.Zettel{}, place.ErrStopped
+	}
+	zettel, _, err := rp.GetVersionedZettel(ctx, zid)
+	return zettel, err
+}
+
+// GetVersionedZettel retrieves a zettel, together with the version token of
+// its current state. For zettel of the remote Zettelstore, it is the entity
+// tag sent by the remote Zettelstore.
+func (rp *remotePlace) GetVersionedZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, string, error) {
+	if !rp.isStarted() {
+		return domain.Zettel{}, "", place.ErrStopped
+	}
+	if zid > maxSystemZid && !rp.isUnknown(zid) {
+		zettel, version, err := rp.fetchZettel(ctx, zid)
+		if err == nil {
+			return zettel, version, nil
+		}
+		if _, ok := err.(*place.ErrUnknownID); !ok {
+			return domain.Zettel{}, "", err
+		}
+	}
+	if rp.next != nil {
+		return place.GetVersionedZettel(ctx, rp.next, zid)
+	}
+	return domain.Zettel{}, "", &place.ErrUnknownID{Zid: zid}
+}
+
+// fetchZettel retrieves a zettel and its entity tag from the remote
+// Zettelstore.
+func (rp *remotePlace) fetchZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, string, error) {
+	resp, err := rp.do(ctx, rp.client, http.MethodGet, "/z/"+zid.Format(), url.Values{"_format": {"json"}}, nil, nil)
+	if err != nil {
+		return domain.Zettel{}, "", err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, "GetZettel", zid); err != nil {
+		return domain.Zettel{}, "", err
+	}
+	var zj zettelJSON
+	if err := json.NewDecoder(resp.Body).Decode(&zj); err != nil {
+		return domain.Zettel{}, "", err
+	}
+	zettel := domain.Zettel{Meta: makeMeta(zid, zj.Meta), Content: domain.NewContent(zj.Content)}
+	version := getETag(resp)
+	if version == "" {
+		version = place.CalcVersion(zettel)
+	}
+	return zettel, version, nil
+}
+
+// GetMeta retrieves just the meta data of a specific zettel.
+func (rp *remotePlace) GetMeta(ctx context.Context, zid domain.ZettelID) (*domain.Meta, error) {
+	if !rp.isStarted() {
+		return nil, place.ErrStopped
+	}
+	meta, err := rp.getMeta(ctx, zid)
+	if err == nil {
+		return meta, nil
+	}
+	if _, ok := err.(*place.ErrUnknownID); ok && rp.next != nil {
+		return rp.next.GetMeta(ctx, zid)
+	}
+	return nil, err
+}
+
+// getMeta retrieves the meta data of a zettel of the remote Zettelstore,
+// preferably from the cache.
+func (rp *remotePlace) getMeta(ctx context.Context, zid domain.ZettelID) (*domain.Meta, error) {
+	if zid <= maxSystemZid {
+		return nil, &place.ErrUnknownID{Zid: zid}
+	}
+	rp.mx.RLock()
+	if rp.metas != nil {
+		meta, ok := rp.metas[zid]
+		rp.mx.RUnlock()
+		if ok {
+			return meta, nil
+		}
+		return nil, &place.ErrUnknownID{Zid: zid}
+	}
+	rp.mx.RUnlock()
+	return rp.fetchMeta(ctx, zid)
+}
+
+func (rp *remotePlace) fetchMeta(ctx context.Context, zid domain.ZettelID) (*domain.Meta, error) {
+	var values map[string]interface{}
+	query := url.Values{"_format": {"json"}, "_part": {"meta"}}
+	if err := rp.getJSON(ctx, "GetMeta", "/z/"+zid.Format(), query, zid, &values); err != nil {
+		return nil, err
+	}
+	return makeMeta(zid, values), nil
+}
+
+// isUnknown returns true, if the cache states that the remote Zettelstore
+// does not store the given zettel.
+func (rp *remotePlace) isUnknown(zid domain.ZettelID) bool {
+	rp.mx.RLock()
+	defer rp.mx.RUnlock()
+	if rp.metas == nil {
+		return false
+	}
+	_, ok := rp.metas[zid]
+	return !ok
+}
+
+func (rp *remotePlace) hasZettel(ctx context.Context, zid domain.ZettelID) bool {
+	_, err := rp.getMeta(ctx, zid)
+	return err == nil
+}
+
+// SelectMeta returns all zettel meta data that match the selection
+// criteria. The result is ordered by descending zettel id.
+//
+// If the filter can be expressed by query parameters, the remote Zettelstore
+// selects the zettel. Otherwise all zettel are retrieved (and cached) and
+// the filter is applied here.
+func (rp *remotePlace) SelectMeta(ctx context.Context, f *place.Filter, s *place.Sorter) ([]*domain.Meta, error) {
+	if !rp.isStarted() {
+		return nil, place.ErrStopped
+	}
+	var metaList []*domain.Meta
+	if query, ok := filterQuery(f); ok && f != nil {
+		list, err := rp.fetchList(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		metaList = list
+	} else {
+		list, err := rp.loadMetas(ctx)
+		if err != nil {
+			return nil, err
+		}
+		metaList = list
+	}
+
+	hasMatch := place.CreateFilterFunc(f)
+	res := make([]*domain.Meta, 0, len(metaList))
+	for _, meta := range metaList {
+		if hasMatch(meta) {
+			res = append(res, meta)
+		}
+	}
+	if rp.next != nil {
+		other, err := rp.next.SelectMeta(ctx, f, nil)
+		if err != nil {
+			return nil, err
+		}
+		return place.MergeSorted(place.ApplySorter(res, nil), other, s), nil
+	}
+	return place.ApplySorter(res, s), nil
+}
+
+// filterQuery returns the query parameters that select the zettel of the
+// given filter on the remote Zettelstore. If some part of the filter cannot
+// be expressed, false is returned.
+func filterQuery(f *place.Filter) (url.Values, bool) {
+	query := url.Values{"_format": {"json"}}
+	if f == nil {
+		return query, true
+	}
+	if f.MatchContent != nil && len(f.ContentWords()) > 0 {
+		// Only this Zettelstore knows how content should be matched.
+		return query, false
+	}
+	for key, values := range f.Expr {
+		if !domain.KeyIsValid(key) || domain.IsComputedKey(key) || key == "backlink" {
+			return query, false
+		}
+		query[key] = values
+	}
+	if !f.Query.IsEmpty() {
+		query.Set("_s", f.Query.String())
+	}
+	if f.Negate {
+		query.Set("_negate", "")
+	}
+	return query, true
+}
+
+// fetchList retrieves the meta data of all zettel of the remote Zettelstore
+// that match the given query parameters.
+func (rp *remotePlace) fetchList(ctx context.Context, query url.Values) ([]*domain.Meta, error) {
+	var lj struct {
+		List []metaJSON `json:"list"`
+	}
+	if err := rp.getJSON(ctx, "SelectMeta", "/z", query, domain.InvalidZettelID, &lj); err != nil {
+		return nil, err
+	}
+	result := make([]*domain.Meta, 0, len(lj.List))
+	for _, mj := range lj.List {
+		zid, err := domain.ParseZettelID(mj.ID)
+		if err != nil || zid <= maxSystemZid {
+			continue
+		}
+		result = append(result, makeMeta(zid, mj.Meta))
+	}
+	return result, nil
+}
+
+// loadMetas returns the meta data of all zettel of the remote Zettelstore.
+// If they are not cached, they are retrieved.
+func (rp *remotePlace) loadMetas(ctx context.Context) ([]*domain.Meta, error) {
+	rp.mx.RLock()
+	if rp.metas != nil {
+		metaList := make([]*domain.Meta, 0, len(rp.metas))
+		for _, meta := range rp.metas {
+			metaList = append(metaList, meta)
+		}
+		rp.mx.RUnlock()
+		return metaList, nil
+	}
+	rp.mx.RUnlock()
+
+	metaList, err := rp.fetchList(ctx, url.Values{"_format": {"json"}})
+	if err != nil {
+		return nil, err
+	}
+	metas := make(map[domain.ZettelID]*domain.Meta, len(metaList))
+	for _, meta := range metaList {
+		metas[meta.Zid] = meta
+	}
+	rp.mx.Lock()
+	if rp.started {
+		rp.metas = metas
+	}
+	rp.mx.Unlock()
+	return metaList, nil
+}
+
+func (rp *remotePlace) CanUpdateZettel(ctx context.Context, zettel domain.Zettel) bool {
+	return rp.isStarted() && zettel.Meta.Zid > maxSystemZid
+}
+
+func (rp *remotePlace) UpdateZettel(ctx context.Context, zettel domain.Zettel) error {
+	return rp.UpdateVersionedZettel(ctx, zettel, "")
+}
+
+// UpdateVersionedZettel updates a zettel, if it was not changed since the
+// given version was retrieved. An empty version updates the zettel
+// unconditionally. The version is checked by the remote Zettelstore.
+func (rp *remotePlace) UpdateVersionedZettel(ctx context.Context, zettel domain.Zettel, version string) error {
+	if !rp.isStarted() {
+		return place.ErrStopped
+	}
+	zid := zettel.Meta.Zid
+	if !zid.IsValid() {
+		return &place.ErrInvalidID{Zid: zid}
+	}
+	if zid <= maxSystemZid {
+		if rp.next == nil {
+			return &place.ErrInvalidID{Zid: zid}
+		}
+		if version == "" {
+			return rp.next.UpdateZettel(ctx, zettel)
+		}
+		return place.UpdateVersionedZettel(ctx, rp.next, zettel, version)
+	}
+	body, err := encodeZettel(zettel)
+	if err != nil {
+		return err
+	}
+	header := jsonHeader()
+	if version != "" {
+		header.Set("If-Match", "\""+version+"\"")
+	}
+	resp, err := rp.do(ctx, rp.client, http.MethodPut, "/z/"+zid.Format(), nil, header, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, "Update", zid); err != nil {
+		return err
+	}
+	rp.changed(ctx, place.ChangeInfo{Reason: place.OnUpdate, Zid: zid})
+	return nil
+}
+
+func (rp *remotePlace) CanDeleteZettel(ctx context.Context, zid domain.ZettelID) bool {
+	if !rp.isStarted() {
+		return false
+	}
+	return rp.hasZettel(ctx, zid) || (rp.next != nil && rp.next.CanDeleteZettel(ctx, zid))
+}
+
+// DeleteZettel removes the zettel from the place.
+func (rp *remotePlace) DeleteZettel(ctx context.Context, zid domain.ZettelID) error {
+	if !rp.isStarted() {
+		return place.ErrStopped
+	}
+	if !rp.hasZettel(ctx, zid) {
+		if rp.next != nil {
+			return rp.next.DeleteZettel(ctx, zid)
+		}
+		return &place.ErrUnknownID{Zid: zid}
+	}
+	resp, err := rp.do(ctx, rp.client, http.MethodDelete, "/z/"+zid.Format(), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, "Delete", zid); err != nil {
+		return err
+	}
+	rp.changed(ctx, place.ChangeInfo{Reason: place.OnDelete, Zid: zid})
+	return nil
+}
+
+func (rp *remotePlace) CanRenameZettel(ctx context.Context, zid domain.ZettelID) bool {
+	if !rp.isStarted() {
+		return false
+	}
+	return rp.hasZettel(ctx, zid) && (rp.next == nil || rp.next.CanRenameZettel(ctx, zid))
+}
+
+// Rename changes the current id to a new id.
+//
+// The web API of a Zettelstore does not support to rename a zettel, so the
+// form of the web user interface is used.
+func (rp *remotePlace) RenameZettel(ctx context.Context, curZid, newZid domain.ZettelID) error {
+	if !rp.isStarted() {
+		return place.ErrStopped
+	}
+	if curZid == newZid {
+		return nil
+	}
+	if rp.hasZettel(ctx, curZid) {
+		if newZid <= maxSystemZid {
+			return &place.ErrInvalidID{Zid: newZid}
+		}
+		form := url.Values{"curzid": {curZid.Format()}, "newzid": {newZid.Format()}}
+		resp, err := rp.do(
+			ctx, rp.client, http.MethodPost, "/r/"+curZid.Format(), nil,
+			http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, []byte(form.Encode()))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			// The form was shown again, because the zettel could not be renamed.
+			return &place.ErrInvalidID{Zid: newZid}
+		}
+		if err := checkResponse(resp, "Rename", curZid); err != nil {
+			return err
+		}
+		rp.changed(ctx, place.ChangeInfo{Reason: place.OnRename, Zid: curZid, NewZid: newZid})
+	}
+	if rp.next != nil {
+		return rp.next.RenameZettel(ctx, curZid, newZid)
+	}
+	return nil
+}
+
+// Reload clears all caches, reloads all internal data to reflect changes
+// that were possibly undetected.
+func (rp *remotePlace) Reload(ctx context.Context) error {
+	if !rp.isStarted() {
+		return place.ErrStopped
+	}
+	rp.mx.Lock()
+	rp.metas = nil
+	rp.mx.Unlock()
+	if rp.next != nil {
+		return rp.next.Reload(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package remoteplace provides a zettel place that is stored in another
+// Zettelstore, accessed via its web API.
+package remoteplace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+// fakeStore simulates the web API of a Zettelstore.
+type fakeStore struct {
+	mx     sync.Mutex
+	metas  map[string]map[string]string
+	events chan string
+}
+
+func (fs *fakeStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/a" {
+		if r.PostFormValue("username") != "user" || r.PostFormValue("password") != "pw" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"tok","token_type":"Bearer","expires_in":3600}`)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer tok" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	fs.mx.Lock()
+	defer fs.mx.Unlock()
+	switch {
+	case r.URL.Path == "/e":
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		fs.mx.Unlock()
+		defer fs.mx.Lock()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case ev := <-fs.events:
+				fmt.Fprint(w, ev)
+				w.(http.Flusher).Flush()
+			}
+		}
+	case r.URL.Path == "/z":
+		type entry struct {
+			ID   string            `json:"id"`
+			Meta map[string]string `json:"meta"`
+		}
+		var list []entry
+		for id, meta := range fs.metas {
+			if role := r.URL.Query().Get("role"); role == "" || meta["role"] == role {
+				list = append(list, entry{id, meta})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"list": list})
+	case strings.HasPrefix(r.URL.Path, "/z/"):
+		meta, ok := fs.metas[r.URL.Path[3:]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("_part") == "meta" {
+			json.NewEncoder(w).Encode(meta)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"meta": meta, "content": "Content"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestRemotePlace(t *testing.T) {
+	fs := &fakeStore{
+		metas: map[string]map[string]string{
+			"20210101000001": {"title": "One", "role": "zettel", "backward": "20210101000002"},
+			"20210101000002": {"title": "Two", "role": "user", "cred": "secret"},
+			"00000000000100": {"title": "System", "role": "configuration"},
+		},
+		events: make(chan string),
+	}
+	srv := httptest.NewServer(fs)
+	defer srv.Close()
+
+	p, err := place.Connect("zs+"+strings.Replace(srv.URL, "//", "//user:pw@", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := make(chan place.ChangeInfo, 10)
+	p.RegisterChangeObserver(func(ci place.ChangeInfo) { changed <- ci })
+	ctx := context.Background()
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop(ctx)
+
+	metaList, err := p.SelectMeta(ctx, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metaList) != 2 || metaList[0].Zid != 20210101000002 || metaList[1].Zid != 20210101000001 {
+		t.Fatalf("Two user zettel expected, but got %v", metaList)
+	}
+	if _, ok := metaList[0].Get(domain.MetaKeyCred); ok {
+		t.Error("Credential must not be retrieved")
+	}
+	if _, ok := metaList[1].Get(domain.MetaKeyBackward); ok {
+		t.Error("Computed value must not be retrieved")
+	}
+
+	filter := &place.Filter{Expr: place.FilterExpr{domain.MetaKeyRole: {"user"}}}
+	if metaList, err = p.SelectMeta(ctx, filter, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(metaList) != 1 || metaList[0].Zid != 20210101000002 {
+		t.Errorf("Only zettel 20210101000002 expected, but got %v", metaList)
+	}
+
+	if _, err := p.GetMeta(ctx, 100); err == nil {
+		t.Error("System zettel must not be retrieved")
+	}
+	zettel, err := p.GetZettel(ctx, 20210101000001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := zettel.Content.AsString(); got != "Content" {
+		t.Errorf("Content %q expected, but got %q", "Content", got)
+	}
+
+	fs.mx.Lock()
+	fs.metas["20210101000001"] = map[string]string{"title": "Changed", "role": "zettel"}
+	fs.mx.Unlock()
+	select {
+	case fs.events <- "event: update\ndata: {\"id\":\"20210101000001\"}\n\n":
+	case <-time.After(5 * time.Second):
+		t.Fatal("Event stream not connected")
+	}
+	select {
+	case ci := <-changed:
+		if ci.Reason != place.OnUpdate || ci.Zid != 20210101000001 {
+			t.Errorf("Update of 20210101000001 expected, but got %v", ci)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No change notified")
+	}
+	meta, err := p.GetMeta(ctx, 20210101000001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := meta.GetDefault(domain.MetaKeyTitle, ""); got != "Changed" {
+		t.Errorf("Title %q expected, but got %q", "Changed", got)
+	}
+}
@@ -0,0 +1,231 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package remoteplace provides a zettel place that is stored in another
+// Zettelstore, accessed via its web API.
+package remoteplace
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+var errStreamClosed = errors.New("event stream closed")
+
+// watch listens to the event stream of the remote Zettelstore to learn about
+// changed zettel. If there is no event stream, the remote Zettelstore is
+// polled regularly, and the event stream is tried again.
+func (rp *remotePlace) watch(done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	failing := false
+	for {
+		connected, err := rp.listen(ctx, failing)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			failing = false
+		}
+		if !failing {
+			log.Println("REMOTEPLACE", "ERROR", rp.base, err)
+			failing = true
+		}
+		rp.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(rp.rescan):
+		}
+	}
+}
+
+// listen processes the event stream of the remote Zettelstore, until it is
+// closed. It returns true, if the event stream was connected. If reconnect is
+// true, the remote Zettelstore was not available before.
+func (rp *remotePlace) listen(ctx context.Context, reconnect bool) (bool, error) {
+	resp, err := rp.do(ctx, rp.streamClient, http.MethodGet, "/e", nil, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, "Events", domain.InvalidZettelID); err != nil {
+		return false, err
+	}
+
+	// Changes may have been missed while not connected.
+	if !rp.poll(ctx) && reconnect {
+		rp.notifyChanged(place.ChangeInfo{Reason: place.OnReload, Zid: domain.InvalidZettelID})
+	}
+
+	event := ""
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			event = ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(line[len("event:"):])
+		case strings.HasPrefix(line, "data:"):
+			rp.handleEvent(ctx, event, strings.TrimSpace(line[len("data:"):]))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return true, err
+	}
+	return true, errStreamClosed
+}
+
+var eventReasons = map[string]place.ChangeReason{
+	place.OnReload.String(): place.OnReload,
+	place.OnCreate.String(): place.OnCreate,
+	place.OnUpdate.String(): place.OnUpdate,
+	place.OnDelete.String(): place.OnDelete,
+	place.OnRename.String(): place.OnRename,
+}
+
+func (rp *remotePlace) handleEvent(ctx context.Context, event, data string) {
+	reason, ok := eventReasons[event]
+	if !ok {
+		return
+	}
+	var ej struct {
+		ID    string `json:"id"`
+		NewID string `json:"new-id"`
+	}
+	if err := json.Unmarshal([]byte(data), &ej); err != nil {
+		return
+	}
+	ci := place.ChangeInfo{Reason: reason}
+	if reason != place.OnReload {
+		zid, err := domain.ParseZettelID(ej.ID)
+		if err != nil || zid <= maxSystemZid {
+			return
+		}
+		ci.Zid = zid
+		if reason == place.OnRename {
+			if ci.NewZid, err = domain.ParseZettelID(ej.NewID); err != nil {
+				return
+			}
+		}
+	}
+	rp.changed(ctx, ci)
+}
+
+// changed updates the cache, and notifies all observers about the change.
+func (rp *remotePlace) changed(ctx context.Context, ci place.ChangeInfo) {
+	if ci.Reason == place.OnReload {
+		rp.mx.Lock()
+		rp.metas = nil
+		rp.mx.Unlock()
+	} else {
+		rp.refresh(ctx, ci.Zid)
+		if ci.Reason == place.OnRename {
+			rp.refresh(ctx, ci.NewZid)
+		}
+	}
+	rp.notifyChanged(ci)
+}
+
+// refresh retrieves the meta data of the given zettel again, if all meta data
+// is cached.
+func (rp *remotePlace) refresh(ctx context.Context, zid domain.ZettelID) {
+	rp.mx.RLock()
+	loaded := rp.metas != nil
+	rp.mx.RUnlock()
+	if !loaded {
+		return
+	}
+	meta, err := rp.fetchMeta(ctx, zid)
+	rp.mx.Lock()
+	if rp.metas != nil {
+		if err == nil {
+			rp.metas[zid] = meta
+		} else if _, ok := err.(*place.ErrUnknownID); ok {
+			delete(rp.metas, zid)
+		} else {
+			// Unsure about the zettel, so the cache is not valid any more.
+			rp.metas = nil
+		}
+	}
+	rp.mx.Unlock()
+}
+
+// poll retrieves all meta data and compares it with the cached one. Every
+// difference is reported to the observers. It returns false, if there was
+// no cached meta data to compare with.
+func (rp *remotePlace) poll(ctx context.Context) bool {
+	rp.mx.RLock()
+	loaded := rp.metas != nil
+	rp.mx.RUnlock()
+	if !loaded {
+		return false
+	}
+	metaList, err := rp.fetchList(ctx, url.Values{"_format": {"json"}})
+	if err != nil {
+		return true
+	}
+
+	metas := make(map[domain.ZettelID]*domain.Meta, len(metaList))
+	var changes []place.ChangeInfo
+	rp.mx.Lock()
+	if rp.metas == nil {
+		rp.mx.Unlock()
+		return false
+	}
+	for _, meta := range metaList {
+		metas[meta.Zid] = meta
+		if old, ok := rp.metas[meta.Zid]; !ok {
+			changes = append(changes, place.ChangeInfo{Reason: place.OnCreate, Zid: meta.Zid})
+		} else if !old.Equal(meta) {
+			changes = append(changes, place.ChangeInfo{Reason: place.OnUpdate, Zid: meta.Zid})
+		}
+	}
+	for zid := range rp.metas {
+		if _, ok := metas[zid]; !ok {
+			changes = append(changes, place.ChangeInfo{Reason: place.OnDelete, Zid: zid})
+		}
+	}
+	rp.metas = metas
+	rp.mx.Unlock()
+
+	for _, ci := range changes {
+		rp.notifyChanged(ci)
+	}
+	return true
+}
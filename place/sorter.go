@@ -77,6 +77,19 @@ func getSortFunc(key string, descending bool, ml []*domain.Meta) sortFunc {
 			}
 			return right
 		}
+	} else if isOrderedType(keyType) {
+		if descending {
+			return func(i, j int) bool {
+				iVal, iOk := ml[i].Get(key)
+				jVal, jOk := ml[j].Get(key)
+				return (iOk && (!jOk || compareOrdered(keyType, iVal, jVal) > 0)) || !jOk
+			}
+		}
+		return func(i, j int) bool {
+			iVal, iOk := ml[i].Get(key)
+			jVal, jOk := ml[j].Get(key)
+			return (iOk && (!jOk || compareOrdered(keyType, iVal, jVal) < 0)) || !jOk
+		}
 	}
 
 	if descending {
@@ -92,3 +105,15 @@ func getSortFunc(key string, descending bool, ml []*domain.Meta) sortFunc {
 		return (iOk && (!jOk || iVal < jVal)) || !jOk
 	}
 }
+
+// compareOrdered compares two values of an ordered key type. In contrast to
+// compareValues, a less precise timestamp sorts before a more precise one
+// within its period.
+func compareOrdered(keyType byte, left, right string) int {
+	if cmp := compareValues(keyType, left, right); cmp != 0 || keyType != domain.MetaTypeTimestamp {
+		return cmp
+	}
+	l, _ := domain.NormalizeTimestamp(left)
+	r, _ := domain.NormalizeTimestamp(right)
+	return len(l) - len(r)
+}
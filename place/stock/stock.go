@@ -43,16 +43,22 @@ type Stock interface {
 	Subscribe(zid domain.ZettelID) error
 	GetZettel(zid domain.ZettelID) domain.Zettel
 	GetMeta(zid domain.ZettelID) *domain.Meta
+
+	// RegisterChangeObserver registers an observer that will be notified
+	// after a subscribed zettel was updated in the stock.
+	RegisterChangeObserver(ob place.ObserverFunc)
 }
 
 // NewStock creates a new stock that operates on the given place.
 func NewStock(place Place) Stock {
-	//RegisterChangeObserver(func(domain.ZettelID))
 	stock := &defaultStock{
-		place: place,
-		subs:  make(map[domain.ZettelID]domain.Zettel),
+		place:   place,
+		subs:    make(map[domain.ZettelID]domain.Zettel),
+		signal:  make(chan struct{}, 1),
+		pending: make(map[domain.ZettelID]bool),
 	}
 	place.RegisterChangeObserver(stock.observe)
+	go stock.worker()
 	return stock
 }
 
@@ -60,51 +66,98 @@ type defaultStock struct {
 	place  Place
 	subs   map[domain.ZettelID]domain.Zettel
 	mxSubs sync.RWMutex
+	signal chan struct{}
+
+	mxPending sync.Mutex
+	reload    bool
+	pending   map[domain.ZettelID]bool
+
+	observers  []place.ObserverFunc
+	mxObserver sync.RWMutex
 }
 
-// observe tracks all changes the place signals.
-func (s *defaultStock) observe(all bool, zid domain.ZettelID) {
-	if !all {
-		s.mxSubs.RLock()
-		defer s.mxSubs.RUnlock()
-		if _, found := s.subs[zid]; found {
-			go func() {
-				s.mxSubs.Lock()
-				defer s.mxSubs.Unlock()
-				s.update(zid)
-			}()
-		}
-		return
+// observe tracks all changes the place signals. They are processed later by
+// the worker, so that the place is not blocked.
+func (s *defaultStock) observe(ci place.ChangeInfo) {
+	s.mxPending.Lock()
+	if ci.Reason == place.OnReload {
+		s.reload = true
+	} else {
+		s.pending[ci.Zid] = true
+	}
+	s.mxPending.Unlock()
+	select {
+	case s.signal <- struct{}{}:
+	default:
 	}
+}
 
-	go func() {
-		s.mxSubs.Lock()
-		defer s.mxSubs.Unlock()
+// worker updates all changed zettel of the stock. Since there is only one
+// worker, an older version of a zettel never replaces a newer one.
+func (s *defaultStock) worker() {
+	for range s.signal {
+		s.mxPending.Lock()
+		reload, pending := s.reload, s.pending
+		s.reload = false
+		s.pending = make(map[domain.ZettelID]bool)
+		s.mxPending.Unlock()
+
+		s.mxSubs.RLock()
+		zids := make([]domain.ZettelID, 0, len(s.subs))
 		for zid := range s.subs {
+			if reload || pending[zid] {
+				zids = append(zids, zid)
+			}
+		}
+		s.mxSubs.RUnlock()
+		for _, zid := range zids {
 			s.update(zid)
 		}
-	}()
+	}
 }
 
+// update retrieves the zettel from the place. The lock is not held while
+// retrieving, because the place may need the stock, e.g. to parse meta data
+// according to the configuration.
 func (s *defaultStock) update(zid domain.ZettelID) {
-	if zettel, err := s.place.GetZettel(context.Background(), zid); err == nil {
-		s.subs[zid] = zettel
+	zettel, err := s.place.GetZettel(context.Background(), zid)
+	if err != nil {
 		return
 	}
+	s.mxSubs.Lock()
+	s.subs[zid] = zettel
+	s.mxSubs.Unlock()
+	s.mxObserver.RLock()
+	observers := s.observers
+	s.mxObserver.RUnlock()
+	for _, ob := range observers {
+		ob(place.ChangeInfo{Reason: place.OnUpdate, Zid: zid})
+	}
+}
+
+// RegisterChangeObserver registers an observer that will be notified after a
+// subscribed zettel was updated in the stock.
+func (s *defaultStock) RegisterChangeObserver(ob place.ObserverFunc) {
+	s.mxObserver.Lock()
+	s.observers = append(s.observers, ob)
+	s.mxObserver.Unlock()
 }
 
 // Subscribe adds a zettel to the stock.
 func (s *defaultStock) Subscribe(zid domain.ZettelID) error {
-	s.mxSubs.Lock()
-	defer s.mxSubs.Unlock()
-	if _, found := s.subs[zid]; found {
+	s.mxSubs.RLock()
+	_, found := s.subs[zid]
+	s.mxSubs.RUnlock()
+	if found {
 		return nil
 	}
 	zettel, err := s.place.GetZettel(context.Background(), zid)
 	if err != nil {
 		return err
 	}
+	s.mxSubs.Lock()
 	s.subs[zid] = zettel
+	s.mxSubs.Unlock()
 	return nil
 }
 
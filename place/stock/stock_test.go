@@ -0,0 +1,83 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package stock allows to get zettel without reading it from a place.
+package stock
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+// testPlace returns the current version of a zettel as its content. Older
+// versions are retrieved slower.
+type testPlace struct {
+	mx       sync.Mutex
+	version  int
+	observer place.ObserverFunc
+}
+
+func (tp *testPlace) RegisterChangeObserver(ob place.ObserverFunc) { tp.observer = ob }
+
+func (tp *testPlace) GetZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, error) {
+	tp.mx.Lock()
+	version := tp.version
+	tp.mx.Unlock()
+	time.Sleep(time.Duration(10-version%10) * time.Millisecond)
+	meta := domain.NewMeta(zid)
+	return domain.Zettel{Meta: meta, Content: domain.NewContent(strconv.Itoa(version))}, nil
+}
+
+func TestSerializedUpdates(t *testing.T) {
+	const zid = domain.ZettelID(1)
+	tp := &testPlace{}
+	s := NewStock(tp)
+	if err := s.Subscribe(zid); err != nil {
+		t.Fatal(err)
+	}
+	updated := make(chan string, 100)
+	s.RegisterChangeObserver(func(ci place.ChangeInfo) {
+		updated <- s.GetZettel(ci.Zid).Content.AsString()
+	})
+
+	const last = 9
+	for i := 1; i <= last; i++ {
+		tp.mx.Lock()
+		tp.version = i
+		tp.mx.Unlock()
+		tp.observer(place.ChangeInfo{Reason: place.OnUpdate, Zid: zid})
+	}
+	for got := ""; got != strconv.Itoa(last); {
+		select {
+		case got = <-updated:
+		case <-time.After(time.Second):
+			t.Fatalf("Version %d not updated", last)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := s.GetZettel(zid).Content.AsString(); got != strconv.Itoa(last) {
+		t.Errorf("Version %d expected, but got %v", last, got)
+	}
+}
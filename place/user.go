@@ -0,0 +1,46 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package place provides a generic interface to zettel places.
+package place
+
+import (
+	"context"
+
+	"zettelstore.de/z/domain"
+)
+
+type ctxUserKeyType struct{}
+
+var ctxUserKey ctxUserKeyType
+
+// WithUser returns a context that carries the meta data of the given user.
+// Places use it to record who made a change.
+func WithUser(ctx context.Context, user *domain.Meta) context.Context {
+	return context.WithValue(ctx, ctxUserKey, user)
+}
+
+// GetUser returns the user meta data from the context, if there is one. Else
+// return nil.
+func GetUser(ctx context.Context) *domain.Meta {
+	if user, ok := ctx.Value(ctxUserKey).(*domain.Meta); ok {
+		return user
+	}
+	return nil
+}
@@ -0,0 +1,476 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package zipplace provides a read-only zettel place, stored in a zip archive.
+package zipplace
+
+import (
+	"archive/zip"
+	"context"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"zettelstore.de/z/config"
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/input"
+	"zettelstore.de/z/place"
+	"zettelstore.de/z/place/dirplace/directory"
+)
+
+func init() {
+	place.Register("zip", func(u *url.URL, next place.Place) (place.Place, error) {
+		path := getZipPath(u)
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+		zp := zipPlace{
+			u:      u,
+			next:   next,
+			path:   path,
+			rescan: time.Duration(place.GetQueryInt(u, "rescan", 1, 10, 24*60*60)) * time.Second,
+		}
+		return &zp, nil
+	})
+}
+
+func getZipPath(u *url.URL) string {
+	if u.Opaque != "" {
+		return filepath.Clean(u.Opaque)
+	}
+	return filepath.Clean(u.Path)
+}
+
+// zipPlace uses a zip archive to read zettel. The archive is never changed.
+type zipPlace struct {
+	u      *url.URL
+	next   place.Place
+	path   string
+	rescan time.Duration
+	done   chan struct{}
+
+	mx      sync.RWMutex
+	reader  *zip.ReadCloser
+	files   map[string]*zip.File
+	entries map[domain.ZettelID]*directory.Entry
+	modTime time.Time
+	size    int64
+
+	mxObserver sync.RWMutex
+	observers  []place.ObserverFunc
+}
+
+func (zp *zipPlace) Next() place.Place { return zp.next }
+
+// Location returns some information where the place is located.
+func (zp *zipPlace) Location() string {
+	return zp.u.String()
+}
+
+// Start the place. Now all other functions of the place are allowed.
+// Starting an already started place is not allowed.
+func (zp *zipPlace) Start(ctx context.Context) error {
+	if zp.isStarted() {
+		panic("Calling zipplace.Start() twice.")
+	}
+	if zp.next != nil {
+		if err := zp.next.Start(ctx); err != nil {
+			return err
+		}
+	}
+	if err := zp.load(); err != nil {
+		return err
+	}
+	zp.done = make(chan struct{})
+	go zp.watch(zp.done)
+	return nil
+}
+
+// Stop the started place. Now only the Start() function is allowed.
+func (zp *zipPlace) Stop(ctx context.Context) error {
+	if !zp.isStarted() {
+		return place.ErrStopped
+	}
+	close(zp.done)
+	zp.mx.Lock()
+	err := zp.reader.Close()
+	zp.reader = nil
+	zp.files = nil
+	zp.entries = nil
+	zp.mx.Unlock()
+	if zp.next != nil {
+		if err1 := zp.next.Stop(ctx); err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+func (zp *zipPlace) isStarted() bool {
+	zp.mx.RLock()
+	started := zp.reader != nil
+	zp.mx.RUnlock()
+	return started
+}
+
+// load reads the directory of the zip archive and replaces the current one.
+func (zp *zipPlace) load() error {
+	info, err := os.Stat(zp.path)
+	if err != nil {
+		return err
+	}
+	reader, err := zip.OpenReader(zp.path)
+	if err != nil {
+		return err
+	}
+	files := make(map[string]*zip.File, len(reader.File))
+	entries := make(map[domain.ZettelID]*directory.Entry)
+	for _, f := range reader.File {
+		name := f.Name
+		if f.FileInfo().IsDir() || strings.ContainsRune(name, '/') {
+			continue
+		}
+		match := directory.MatchValidFileName(name)
+		if len(match) == 0 {
+			continue
+		}
+		zid, err := domain.ParseZettelID(match[1])
+		if err != nil {
+			continue
+		}
+		files[name] = f
+		entry, ok := entries[zid]
+		if !ok {
+			entry = &directory.Entry{Zid: zid}
+			entries[zid] = entry
+		}
+		entry.AddFile(name, match[3])
+	}
+
+	zp.mx.Lock()
+	oldReader := zp.reader
+	zp.reader = reader
+	zp.files = files
+	zp.entries = entries
+	zp.modTime = info.ModTime()
+	zp.size = info.Size()
+	zp.mx.Unlock()
+	if oldReader != nil {
+		oldReader.Close()
+	}
+	return nil
+}
+
+// watch checks regularly whether the archive was changed on disk.
+func (zp *zipPlace) watch(done <-chan struct{}) {
+	ticker := time.NewTicker(zp.rescan)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if zp.isChanged() {
+				zp.reload()
+			}
+		}
+	}
+}
+
+func (zp *zipPlace) isChanged() bool {
+	info, err := os.Stat(zp.path)
+	if err != nil {
+		return false
+	}
+	zp.mx.RLock()
+	changed := !info.ModTime().Equal(zp.modTime) || info.Size() != zp.size
+	zp.mx.RUnlock()
+	return changed
+}
+
+func (zp *zipPlace) reload() {
+	if err := zp.load(); err != nil {
+		log.Println("ZIPPLACE", "ERROR", zp.path, err)
+		return
+	}
+	zp.notifyChanged(place.ChangeInfo{Reason: place.OnReload, Zid: domain.InvalidZettelID})
+}
+
+func (zp *zipPlace) notifyChanged(ci place.ChangeInfo) {
+	zp.mxObserver.RLock()
+	observers := zp.observers
+	zp.mxObserver.RUnlock()
+	for _, ob := range observers {
+		ob(ci)
+	}
+}
+
+// RegisterChangeObserver registers an observer that will be notified
+// if the zip archive was changed.
+func (zp *zipPlace) RegisterChangeObserver(f place.ObserverFunc) {
+	if zp.next != nil {
+		zp.next.RegisterChangeObserver(f)
+	}
+	zp.mxObserver.Lock()
+	zp.observers = append(zp.observers, f)
+	zp.mxObserver.Unlock()
+}
+
+func (zp *zipPlace) CanCreateZettel(ctx context.Context) bool { return false }
+
+func (zp *zipPlace) CreateZettel(ctx context.Context, zettel domain.Zettel) (domain.ZettelID, error) {
+	return domain.InvalidZettelID, place.ErrReadOnly
+}
+
+// GetZettel retrieves a specific zettel.
+func (zp *zipPlace) GetZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, error) {
+	zp.mx.RLock()
+	defer zp.mx.RUnlock()
+	if zp.reader == nil {
+		return domain.Zettel{}, place.ErrStopped
+	}
+	entry, ok := zp.entries[zid]
+	if !ok {
+		if zp.next != nil {
+			return zp.next.GetZettel(ctx, zid)
+		}
+		return domain.Zettel{}, &place.ErrUnknownID{Zid: zid}
+	}
+
+	var meta *domain.Meta
+	var content string
+	var err error
+	switch entry.MetaSpec {
+	case directory.MetaSpecFile:
+		meta, err = zp.readMeta(zid, entry.MetaPath)
+		if err == nil {
+			content, err = zp.readFile(entry.ContentPath)
+		}
+	case directory.MetaSpecHeader:
+		meta, content, err = zp.readMetaContent(zid, entry.ContentPath)
+	default:
+		meta = entry.CalcMeta()
+		content, err = zp.readFile(entry.ContentPath)
+	}
+	if err != nil {
+		return domain.Zettel{}, err
+	}
+	cleanupMeta(meta, entry, zp.entryModified(entry))
+	return domain.Zettel{Meta: meta, Content: domain.NewContent(content)}, nil
+}
+
+// GetMeta retrieves just the meta data of a specific zettel.
+func (zp *zipPlace) GetMeta(ctx context.Context, zid domain.ZettelID) (*domain.Meta, error) {
+	zp.mx.RLock()
+	defer zp.mx.RUnlock()
+	if zp.reader == nil {
+		return nil, place.ErrStopped
+	}
+	entry, ok := zp.entries[zid]
+	if !ok {
+		if zp.next != nil {
+			return zp.next.GetMeta(ctx, zid)
+		}
+		return nil, &place.ErrUnknownID{Zid: zid}
+	}
+	return zp.getMeta(entry)
+}
+
+func (zp *zipPlace) getMeta(entry *directory.Entry) (*domain.Meta, error) {
+	var meta *domain.Meta
+	var err error
+	switch entry.MetaSpec {
+	case directory.MetaSpecFile:
+		meta, err = zp.readMeta(entry.Zid, entry.MetaPath)
+	case directory.MetaSpecHeader:
+		meta, _, err = zp.readMetaContent(entry.Zid, entry.ContentPath)
+	default:
+		meta = entry.CalcMeta()
+	}
+	if err != nil {
+		return nil, err
+	}
+	cleanupMeta(meta, entry, zp.entryModified(entry))
+	return meta, nil
+}
+
+// SelectMeta returns all zettel meta data that match the selection
+// criteria. The result is ordered by descending zettel id.
+func (zp *zipPlace) SelectMeta(ctx context.Context, f *place.Filter, s *place.Sorter) (res []*domain.Meta, err error) {
+	zp.mx.RLock()
+	if zp.reader == nil {
+		zp.mx.RUnlock()
+		return nil, place.ErrStopped
+	}
+	hasMatch := place.CreateFilterFunc(f)
+	res = make([]*domain.Meta, 0, len(zp.entries))
+	for _, entry := range zp.entries {
+		meta, err := zp.getMeta(entry)
+		if err != nil {
+			continue
+		}
+		if hasMatch(meta) {
+			res = append(res, meta)
+		}
+	}
+	zp.mx.RUnlock()
+	if zp.next != nil {
+		other, err := zp.next.SelectMeta(ctx, f, nil)
+		if err != nil {
+			return nil, err
+		}
+		return place.MergeSorted(place.ApplySorter(res, nil), other, s), nil
+	}
+	return place.ApplySorter(res, s), nil
+}
+
+func (zp *zipPlace) hasZettel(zid domain.ZettelID) bool {
+	zp.mx.RLock()
+	_, ok := zp.entries[zid]
+	zp.mx.RUnlock()
+	return ok
+}
+
+func (zp *zipPlace) CanUpdateZettel(ctx context.Context, zettel domain.Zettel) bool {
+	if !zp.hasZettel(zettel.Meta.Zid) && zp.next != nil {
+		return zp.next.CanUpdateZettel(ctx, zettel)
+	}
+	return false
+}
+
+func (zp *zipPlace) UpdateZettel(ctx context.Context, zettel domain.Zettel) error {
+	if !zp.hasZettel(zettel.Meta.Zid) && zp.next != nil {
+		return zp.next.UpdateZettel(ctx, zettel)
+	}
+	return place.ErrReadOnly
+}
+
+func (zp *zipPlace) CanDeleteZettel(ctx context.Context, zid domain.ZettelID) bool {
+	if !zp.hasZettel(zid) && zp.next != nil {
+		return zp.next.CanDeleteZettel(ctx, zid)
+	}
+	return false
+}
+
+// DeleteZettel removes the zettel from the place.
+func (zp *zipPlace) DeleteZettel(ctx context.Context, zid domain.ZettelID) error {
+	if !zp.hasZettel(zid) && zp.next != nil {
+		return zp.next.DeleteZettel(ctx, zid)
+	}
+	return place.ErrReadOnly
+}
+
+func (zp *zipPlace) CanRenameZettel(ctx context.Context, zid domain.ZettelID) bool {
+	if !zp.hasZettel(zid) {
+		return zp.next == nil || zp.next.CanRenameZettel(ctx, zid)
+	}
+	return false
+}
+
+// Rename changes the current id to a new id.
+func (zp *zipPlace) RenameZettel(ctx context.Context, curZid, newZid domain.ZettelID) error {
+	if !zp.hasZettel(curZid) {
+		if zp.next != nil {
+			return zp.next.RenameZettel(ctx, curZid, newZid)
+		}
+		return nil
+	}
+	return place.ErrReadOnly
+}
+
+// Reload clears all caches, reloads all internal data to reflect changes
+// that were possibly undetected.
+func (zp *zipPlace) Reload(ctx context.Context) error {
+	if !zp.isStarted() {
+		return place.ErrStopped
+	}
+	err := zp.load()
+	if zp.next != nil {
+		err1 := zp.next.Reload(ctx)
+		if err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+// readFile returns the content of a file inside the archive. The caller must
+// hold the read lock.
+func (zp *zipPlace) readFile(name string) (string, error) {
+	f, ok := zp.files[name]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (zp *zipPlace) readMeta(zid domain.ZettelID, name string) (*domain.Meta, error) {
+	src, err := zp.readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return domain.NewMetaFromInput(zid, input.NewInput(src)), nil
+}
+
+func (zp *zipPlace) readMetaContent(zid domain.ZettelID, name string) (*domain.Meta, string, error) {
+	src, err := zp.readFile(name)
+	if err != nil {
+		return nil, "", err
+	}
+	inp := input.NewInput(src)
+	meta := domain.NewMetaFromInput(zid, inp)
+	return meta, src[inp.Pos:], nil
+}
+
+// entryModified returns the latest modification time of the files of the
+// given entry. The caller must hold the read lock.
+func (zp *zipPlace) entryModified(entry *directory.Entry) time.Time {
+	var modified time.Time
+	for _, name := range []string{entry.MetaPath, entry.ContentPath} {
+		if f, ok := zp.files[name]; ok && f.Modified.After(modified) {
+			modified = f.Modified
+		}
+	}
+	return modified
+}
+
+func cleanupMeta(meta *domain.Meta, entry *directory.Entry, modified time.Time) {
+	entry.CleanupMeta(meta, modified)
+	if syntax, ok := meta.Get(domain.MetaKeySyntax); !ok || syntax == "" {
+		meta.Set(domain.MetaKeySyntax, config.GetDefaultSyntax())
+	}
+	if role, ok := meta.Get(domain.MetaKeyRole); !ok || role == "" {
+		meta.Set(domain.MetaKeyRole, config.GetDefaultRole())
+	}
+	meta.Freeze()
+}
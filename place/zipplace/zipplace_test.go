@@ -0,0 +1,125 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package zipplace provides a read-only zettel place, stored in a zip archive.
+package zipplace
+
+import (
+	"archive/zip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+func writeArchive(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZipPlace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zipplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bundle.zip")
+	writeArchive(t, path, map[string]string{
+		"20210101000001.zettel":     "title: Header\nrole: zettel\n\nFirst",
+		"20210101000002.meta":       "title: Meta file",
+		"20210101000002.md":         "Second",
+		"20210101000003 Note.txt":   "Third",
+		"sub/20210101000004.zettel": "title: Ignored\n\nFourth",
+		"readme.txt":                "Ignored",
+	})
+
+	p, err := place.Connect("zip://"+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop(ctx)
+
+	testcases := []struct {
+		zid     domain.ZettelID
+		title   string
+		syntax  string
+		content string
+	}{
+		{20210101000001, "Header", "", "First"},
+		{20210101000002, "Meta file", "md", "Second"},
+		{20210101000003, "20210101000003", "txt", "Third"},
+	}
+	for _, tc := range testcases {
+		zettel, err := p.GetZettel(ctx, tc.zid)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tc.zid, err)
+			continue
+		}
+		if got := zettel.Meta.GetDefault(domain.MetaKeyTitle, ""); got != tc.title {
+			t.Errorf("%v: title %q expected, but got %q", tc.zid, tc.title, got)
+		}
+		if got := zettel.Meta.GetDefault(domain.MetaKeySyntax, ""); tc.syntax != "" && got != tc.syntax {
+			t.Errorf("%v: syntax %q expected, but got %q", tc.zid, tc.syntax, got)
+		}
+		if got := zettel.Content.AsString(); got != tc.content {
+			t.Errorf("%v: content %q expected, but got %q", tc.zid, tc.content, got)
+		}
+	}
+
+	metaList, err := p.SelectMeta(ctx, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metaList) != len(testcases) {
+		t.Errorf("%d zettel expected, but got %d", len(testcases), len(metaList))
+	}
+	if _, err := p.GetMeta(ctx, 20210101000004); err == nil {
+		t.Error("zettel in sub directory should not be found")
+	}
+	if err := p.UpdateZettel(ctx, domain.Zettel{Meta: metaList[0]}); err == nil {
+		t.Error("zip place must be read-only")
+	}
+}
@@ -0,0 +1,51 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package usecase provides (business) use cases for the zettelstore.
+package usecase
+
+import (
+	"context"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+// GetJournalPort is the interface used by this use case.
+type GetJournalPort interface {
+	// GetJournal returns all recorded changes of the given zettel, newest
+	// first. If the zettel id is invalid, the changes of all zettel are
+	// returned.
+	GetJournal(ctx context.Context, zid domain.ZettelID) ([]place.JournalEntry, error)
+}
+
+// GetJournal is the data for this use case.
+type GetJournal struct {
+	store GetJournalPort
+}
+
+// NewGetJournal creates a new use case.
+func NewGetJournal(port GetJournalPort) GetJournal {
+	return GetJournal{store: port}
+}
+
+// Run executes the use case.
+func (uc GetJournal) Run(ctx context.Context, zid domain.ZettelID) ([]place.JournalEntry, error) {
+	return uc.store.GetJournal(ctx, zid)
+}
@@ -0,0 +1,170 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package usecase provides (business) use cases for the zettelstore.
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+// GetSchemaPort is the interface used by this use case.
+type GetSchemaPort interface {
+	// RegisterChangeObserver registers an observer that will be notified
+	// if a zettel was found to be changed.
+	RegisterChangeObserver(f place.ObserverFunc)
+
+	// GetMeta retrieves just the meta data of a specific zettel.
+	GetMeta(ctx context.Context, zid domain.ZettelID) (*domain.Meta, error)
+
+	// SelectMeta returns all zettel meta data that match the selection criteria.
+	SelectMeta(ctx context.Context, f *place.Filter, s *place.Sorter) ([]*domain.Meta, error)
+}
+
+// GetSchema is the data for this use case.
+type GetSchema struct {
+	cache *schemaCache
+}
+
+// NewGetSchema creates a new use case. Since every zettel must conform to
+// the schema of its role, regardless of the current user, the port must not
+// check any access rights.
+func NewGetSchema(port GetSchemaPort) GetSchema {
+	sc := &schemaCache{port: port}
+	port.RegisterChangeObserver(sc.observe)
+	return GetSchema{cache: sc}
+}
+
+// Run executes the use case. It returns the schema for zettel with the given
+// role, or nil if there is no schema zettel for this role.
+func (uc GetSchema) Run(ctx context.Context, role string) (*domain.Schema, error) {
+	if role == "" {
+		return nil, nil
+	}
+	metaList, err := uc.cache.get(ctx, role)
+	if err != nil || len(metaList) == 0 {
+		return nil, err
+	}
+	return domain.NewSchema(role, metaList), nil
+}
+
+// schemaCache stores the meta data of all schema zettel. They are read when
+// a schema is needed for the first time. Afterwards, only changed zettel are
+// read again.
+type schemaCache struct {
+	port    GetSchemaPort
+	mx      sync.Mutex
+	metas   map[domain.ZettelID]*domain.Meta // nil, if not read so far
+	pending map[domain.ZettelID]bool         // changed zettel
+}
+
+func (sc *schemaCache) observe(ci place.ChangeInfo) {
+	sc.mx.Lock()
+	if ci.Reason == place.OnReload {
+		sc.metas = nil
+		sc.pending = nil
+	} else if sc.metas != nil {
+		if sc.pending == nil {
+			sc.pending = make(map[domain.ZettelID]bool)
+		}
+		sc.pending[ci.Zid] = true
+		if ci.Reason == place.OnRename {
+			sc.pending[ci.NewZid] = true
+		}
+	}
+	sc.mx.Unlock()
+}
+
+// get returns the meta data of all schema zettel for the given role.
+func (sc *schemaCache) get(ctx context.Context, role string) ([]*domain.Meta, error) {
+	sc.mx.Lock()
+	defer sc.mx.Unlock()
+	if sc.metas == nil {
+		filter := place.Filter{
+			Expr: map[string][]string{domain.MetaKeyRole: []string{domain.MetaValueRoleSchema}},
+		}
+		metaList, err := sc.port.SelectMeta(ctx, &filter, nil)
+		if err != nil {
+			return nil, err
+		}
+		sc.metas = make(map[domain.ZettelID]*domain.Meta, len(metaList))
+		for _, meta := range metaList {
+			sc.metas[meta.Zid] = meta
+		}
+		sc.pending = nil
+	}
+	for zid := range sc.pending {
+		meta, err := sc.port.GetMeta(ctx, zid)
+		if err != nil {
+			if _, ok := err.(*place.ErrUnknownID); !ok {
+				return nil, err
+			}
+			meta = nil
+		}
+		if meta != nil && meta.GetDefault(domain.MetaKeyRole, "") == domain.MetaValueRoleSchema {
+			sc.metas[zid] = meta
+		} else {
+			delete(sc.metas, zid)
+		}
+		delete(sc.pending, zid)
+	}
+
+	var result []*domain.Meta
+	for _, meta := range sc.metas {
+		if strings.EqualFold(meta.GetDefault(domain.MetaKeySchemaRole, ""), role) {
+			result = append(result, meta)
+		}
+	}
+	return place.ApplySorter(result, nil), nil
+}
+
+// ErrSchemaViolation is returned, if the meta data of a zettel does not
+// conform to the schema of its role.
+type ErrSchemaViolation struct {
+	Zid      domain.ZettelID
+	Role     string
+	Problems []domain.SchemaProblem
+}
+
+func (err *ErrSchemaViolation) Error() string {
+	problems := make([]string, 0, len(err.Problems))
+	for _, p := range err.Problems {
+		problems = append(problems, p.String())
+	}
+	return fmt.Sprintf("zettel does not conform to schema of role %q: %s", err.Role, strings.Join(problems, "; "))
+}
+
+// validate checks the meta data against the schema of its role.
+func (uc GetSchema) validate(ctx context.Context, meta *domain.Meta) error {
+	role, _ := meta.Get(domain.MetaKeyRole)
+	schema, err := uc.Run(ctx, role)
+	if err != nil || schema == nil {
+		return err
+	}
+	if problems := schema.Validate(meta); len(problems) > 0 {
+		return &ErrSchemaViolation{Zid: meta.Zid, Role: role, Problems: problems}
+	}
+	return nil
+}
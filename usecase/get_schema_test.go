@@ -0,0 +1,122 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package usecase provides (business) use cases for the zettelstore.
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+type testSchemaPort struct {
+	metas    map[domain.ZettelID]*domain.Meta
+	observer place.ObserverFunc
+	selects  int
+}
+
+func (p *testSchemaPort) RegisterChangeObserver(f place.ObserverFunc) { p.observer = f }
+
+func (p *testSchemaPort) GetMeta(ctx context.Context, zid domain.ZettelID) (*domain.Meta, error) {
+	if meta, ok := p.metas[zid]; ok {
+		return meta, nil
+	}
+	return nil, &place.ErrUnknownID{Zid: zid}
+}
+
+func (p *testSchemaPort) SelectMeta(ctx context.Context, f *place.Filter, s *place.Sorter) ([]*domain.Meta, error) {
+	p.selects++
+	match := place.CreateFilterFunc(f)
+	var result []*domain.Meta
+	for _, meta := range p.metas {
+		if match(meta) {
+			result = append(result, meta)
+		}
+	}
+	return result, nil
+}
+
+// noSchema returns a use case without any schema zettel.
+func noSchema() GetSchema {
+	return NewGetSchema(&testSchemaPort{})
+}
+
+func newSchemaMeta(zid domain.ZettelID, role, required string) *domain.Meta {
+	meta := domain.NewMeta(zid)
+	meta.Set(domain.MetaKeyRole, domain.MetaValueRoleSchema)
+	meta.Set(domain.MetaKeySchemaRole, role)
+	meta.Set(domain.MetaKeySchemaRequired, required)
+	return meta
+}
+
+func TestGetSchemaCache(t *testing.T) {
+	port := &testSchemaPort{metas: map[domain.ZettelID]*domain.Meta{
+		1: newSchemaMeta(1, "book", "author"),
+		2: newSchemaMeta(2, "movie", "director"),
+	}}
+	uc := NewGetSchema(port)
+	ctx := context.Background()
+	assertRequired := func(role string, exp ...string) {
+		t.Helper()
+		schema, err := uc.Run(ctx, role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		if schema != nil {
+			got = schema.Required
+		}
+		if len(got) != len(exp) {
+			t.Errorf("Role %q: required %v expected, but got %v", role, exp, got)
+			return
+		}
+		for i, key := range exp {
+			if got[i] != key {
+				t.Errorf("Role %q: required %v expected, but got %v", role, exp, got)
+				return
+			}
+		}
+	}
+
+	assertRequired("book", "author")
+	assertRequired("movie", "director")
+	assertRequired("music")
+	if port.selects != 1 {
+		t.Errorf("Schema zettel must be selected once, but got %d", port.selects)
+	}
+
+	port.metas[3] = newSchemaMeta(3, "music", "artist")
+	port.observer(place.ChangeInfo{Reason: place.OnUpdate, Zid: 3})
+	delete(port.metas, 1)
+	port.observer(place.ChangeInfo{Reason: place.OnDelete, Zid: 1})
+	assertRequired("music", "artist")
+	assertRequired("book")
+	if port.selects != 1 {
+		t.Errorf("Changed zettel must be read without selecting, but got %d", port.selects)
+	}
+
+	port.observer(place.ChangeInfo{Reason: place.OnReload, Zid: domain.InvalidZettelID})
+	assertRequired("movie", "director")
+	if port.selects != 2 {
+		t.Errorf("Schema zettel must be selected after reload, but got %d", port.selects)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"context"
 
 	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
 )
 
 // GetZettelPort is the interface used by this use case.
@@ -46,3 +47,23 @@ func NewGetZettel(port GetZettelPort) GetZettel {
 func (uc GetZettel) Run(ctx context.Context, zid domain.ZettelID) (domain.Zettel, error) {
 	return uc.store.GetZettel(ctx, zid)
 }
+
+// RunVersioned executes the use case. In addition to the zettel, the version
+// token of its current state is returned.
+func (uc GetZettel) RunVersioned(ctx context.Context, zid domain.ZettelID) (domain.Zettel, string, error) {
+	return getVersionedZettel(ctx, uc.store, zid)
+}
+
+// getVersionedZettel retrieves a zettel, together with its version token. If
+// the port does not compute version tokens, the token is calculated from the
+// zettel data.
+func getVersionedZettel(ctx context.Context, port GetZettelPort, zid domain.ZettelID) (domain.Zettel, string, error) {
+	if v, ok := port.(place.Versioner); ok {
+		return v.GetVersionedZettel(ctx, zid)
+	}
+	zettel, err := port.GetZettel(ctx, zid)
+	if err != nil {
+		return domain.Zettel{}, "", err
+	}
+	return zettel, place.CalcVersion(zettel), nil
+}
@@ -22,6 +22,7 @@ package usecase
 
 import (
 	"context"
+	"time"
 
 	"zettelstore.de/z/config"
 	"zettelstore.de/z/domain"
@@ -35,12 +36,14 @@ type NewZettelPort interface {
 
 // NewZettel is the data for this use case.
 type NewZettel struct {
-	store NewZettelPort
+	store     NewZettelPort
+	getSchema GetSchema
 }
 
-// NewNewZettel creates a new use case.
-func NewNewZettel(port NewZettelPort) NewZettel {
-	return NewZettel{store: port}
+// NewNewZettel creates a new use case. The meta data of the zettel is
+// validated with the given use case.
+func NewNewZettel(port NewZettelPort, getSchema GetSchema) NewZettel {
+	return NewZettel{store: port, getSchema: getSchema}
 }
 
 // Run executes the use case.
@@ -60,6 +63,12 @@ func (uc NewZettel) Run(ctx context.Context, zettel domain.Zettel) (domain.Zette
 		meta.Set(domain.MetaKeySyntax, config.GetDefaultSyntax())
 	}
 	meta.YamlSep = config.GetYAMLHeader()
+	now := time.Now()
+	meta.Set(domain.MetaKeyCreated, domain.TimestampValue(now))
+	setModified(ctx, meta, now)
+	if err := uc.getSchema.validate(ctx, meta); err != nil {
+		return domain.InvalidZettelID, err
+	}
 
 	return uc.store.CreateZettel(ctx, zettel)
 }
@@ -22,27 +22,153 @@ package usecase
 
 import (
 	"context"
+	"regexp"
 
+	"zettelstore.de/z/config"
 	"zettelstore.de/z/domain"
+	"zettelstore.de/z/parser"
+	"zettelstore.de/z/place"
 )
 
 // RenameZettelPort is the interface used by this use case.
 type RenameZettelPort interface {
+	// GetMeta retrieves just the meta data of a specific zettel.
+	GetMeta(ctx context.Context, zid domain.ZettelID) (*domain.Meta, error)
+
+	// GetZettel retrieves a specific zettel.
+	GetZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, error)
+
 	// Rename changes the current id to a new id.
 	RenameZettel(ctx context.Context, curZid, newZid domain.ZettelID) error
 }
 
+// RenameIndex is the link index used by this use case.
+type RenameIndex interface {
+	// Backward returns the zettel identifier of all zettel that reference
+	// the given zettel, regardless of the current user.
+	Backward(zid domain.ZettelID) []domain.ZettelID
+}
+
 // RenameZettel is the data for this use case.
 type RenameZettel struct {
-	store RenameZettelPort
+	store  RenameZettelPort
+	index  RenameIndex
+	update UpdateZettel
 }
 
-// NewRenameZettel creates a new use case.
-func NewRenameZettel(port RenameZettelPort) RenameZettel {
-	return RenameZettel{store: port}
+// NewRenameZettel creates a new use case. References are rewritten with the
+// given use case to update a zettel. If idx is nil, the references are taken
+// from the computed meta data of the renamed zettel.
+func NewRenameZettel(port RenameZettelPort, idx RenameIndex, updateZettel UpdateZettel) RenameZettel {
+	return RenameZettel{store: port, index: idx, update: updateZettel}
 }
 
-// Run executes the use case.
-func (uc RenameZettel) Run(ctx context.Context, curID, newID domain.ZettelID) error {
-	return uc.store.RenameZettel(ctx, curID, newID)
+// RenameResult lists all zettel that reference the renamed zettel.
+type RenameResult struct {
+	Meta      *domain.Meta   // Meta data of the zettel before renaming
+	Rewritten []*domain.Meta // References were (or will be) rewritten
+	Failed    []*domain.Meta // References could not be rewritten
+	Skipped   int            // Number of referencing zettel that could not be read
+}
+
+// Run executes the use case. All references to the renamed zettel are
+// rewritten. If dryRun is true, nothing is changed, but the result lists all
+// zettel that would be affected.
+func (uc RenameZettel) Run(ctx context.Context, curID, newID domain.ZettelID, dryRun bool) (RenameResult, error) {
+	meta, err := uc.store.GetMeta(ctx, curID)
+	if err != nil {
+		return RenameResult{}, err
+	}
+	refZids := append([]domain.ZettelID{curID}, uc.backward(meta)...)
+	if !dryRun {
+		if err := uc.store.RenameZettel(ctx, curID, newID); err != nil {
+			return RenameResult{}, err
+		}
+	}
+
+	result := RenameResult{Meta: meta}
+	for _, zid := range refZids {
+		if zid == curID && !dryRun {
+			zid = newID
+		}
+		zettel, err := uc.store.GetZettel(ctx, zid)
+		if err != nil {
+			// The zettel identifier must not be revealed, if the user is
+			// not allowed to read the zettel.
+			if _, ok := err.(*place.ErrUnknownID); !ok {
+				result.Skipped++
+			}
+			continue
+		}
+		content, ok := RewriteReferences(
+			zettel.Content.AsString(), config.GetSyntax(zettel.Meta), curID, newID)
+		if !ok {
+			if zid != curID && zid != newID {
+				result.Failed = append(result.Failed, zettel.Meta)
+			}
+			continue
+		}
+		if !dryRun {
+			zettel.Content = domain.NewContent(content)
+			if err := uc.update.Run(ctx, zettel, ""); err != nil {
+				result.Failed = append(result.Failed, zettel.Meta)
+				continue
+			}
+		}
+		result.Rewritten = append(result.Rewritten, zettel.Meta)
+	}
+	return result, nil
+}
+
+// backward returns the zettel identifier of all zettel that reference the
+// given zettel.
+func (uc RenameZettel) backward(meta *domain.Meta) []domain.ZettelID {
+	if uc.index != nil {
+		return uc.index.Backward(meta.Zid)
+	}
+	var result []domain.ZettelID
+	for _, val := range meta.GetListOrNil(domain.MetaKeyBackward) {
+		if zid, err := domain.ParseZettelID(val); err == nil {
+			result = append(result, zid)
+		}
+	}
+	return result
+}
+
+var (
+	reZmkRef = regexp.MustCompile(
+		`((?:\[\[|\{\{)(?:[^|\n]*\|)? *)(\d{14})((?:#[^\]}\s]*)?(?:\]\]|\}\}))`)
+	reMarkdownRef = regexp.MustCompile(
+		`(\]\(\s*<?)(\d{14})((?:#[^\s)>]*)?>?[\s)])`)
+	reMarkdownDef = regexp.MustCompile(
+		`(?m)(^ {0,3}\[[^\]\n]+\]:[ \t]*<?)(\d{14})((?:#[^\s>]*)?>?(?:\s|$))`)
+)
+
+// RewriteReferences changes all references to the current zettel id within
+// the given content into references to the new zettel id. The content is
+// changed at the source level, to preserve its formatting. It returns false,
+// if there was no reference found or if the syntax is not supported.
+func RewriteReferences(content, syntax string, curID, newID domain.ZettelID) (string, bool) {
+	var res []*regexp.Regexp
+	switch parser.Get(syntax).Name {
+	case "zmk":
+		res = []*regexp.Regexp{reZmkRef}
+	case "markdown":
+		res = []*regexp.Regexp{reMarkdownRef, reMarkdownDef}
+	default:
+		return content, false
+	}
+	cur, repl := curID.Format(), "${1}"+newID.Format()+"${3}"
+	changed := false
+	for _, re := range res {
+		content = re.ReplaceAllStringFunc(content, func(match string) string {
+			sm := re.FindStringSubmatch(match)
+			if sm[2] != cur {
+				return match
+			}
+			changed = true
+			return re.ReplaceAllString(match, repl)
+		})
+	}
+	return content, changed
 }
@@ -0,0 +1,138 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package usecase provides (business) use cases for the zettelstore.
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+
+	_ "zettelstore.de/z/parser/markdown"
+	_ "zettelstore.de/z/parser/plain"
+	_ "zettelstore.de/z/parser/zettelmark"
+)
+
+func TestRewriteReferences(t *testing.T) {
+	const curID, newID = domain.ZettelID(20201010101000), domain.ZettelID(20201111111100)
+	testcases := []struct {
+		syntax  string
+		content string
+		exp     string
+		ok      bool
+	}{
+		{"zmk", "No link", "No link", false},
+		{"zmk", "[[20201010101000]]", "[[20201111111100]]", true},
+		{"zmk", "[[Text|20201010101000]]", "[[Text|20201111111100]]", true},
+		{"zmk", "[[ 20201010101000]]{a=b}", "[[ 20201111111100]]{a=b}", true},
+		{"zmk", "{{Image|20201010101000}}", "{{Image|20201111111100}}", true},
+		{"zmk", "[[A|20201010101001]] [[B|20201010101000]]", "[[A|20201010101001]] [[B|20201111111100]]", true},
+		{"zmk", "[[A]] and 20201010101000]]", "[[A]] and 20201010101000]]", false},
+		{"zmk", "Only 20201010101000 in text", "Only 20201010101000 in text", false},
+		{"zmk", "[[Text|20201010101000#frag]]", "[[Text|20201111111100#frag]]", true},
+		{"zmk", "{{20201010101000#frag}}", "{{20201111111100#frag}}", true},
+		{"markdown", "[Text](20201010101000)", "[Text](20201111111100)", true},
+		{"markdown", "[Text](20201010101000#frag)", "[Text](20201111111100#frag)", true},
+		{"markdown", "[ref]: <20201010101000#frag>\n", "[ref]: <20201111111100#frag>\n", true},
+		{"md", "![Img](20201010101000 \"Title\")", "![Img](20201111111100 \"Title\")", true},
+		{"markdown", "[ref]: 20201010101000\n", "[ref]: 20201111111100\n", true},
+		{"markdown", "[Text](20201010101001)", "[Text](20201010101001)", false},
+		{"txt", "[[20201010101000]]", "[[20201010101000]]", false},
+	}
+	for i, tc := range testcases {
+		got, ok := RewriteReferences(tc.content, tc.syntax, curID, newID)
+		if got != tc.exp || ok != tc.ok {
+			t.Errorf("TC=%d, syntax=%q, content=%q: exp=%q/%v, got=%q/%v", i, tc.syntax, tc.content, tc.exp, tc.ok, got, ok)
+		}
+	}
+}
+
+type testRenamePort struct {
+	zettel map[domain.ZettelID]domain.Zettel
+	secret domain.ZettelID
+}
+
+func (p *testRenamePort) GetMeta(ctx context.Context, zid domain.ZettelID) (*domain.Meta, error) {
+	zettel, err := p.GetZettel(ctx, zid)
+	return zettel.Meta, err
+}
+
+func (p *testRenamePort) GetZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, error) {
+	if zid == p.secret {
+		return domain.Zettel{}, place.NewErrNotAuthorized("GetZettel", nil, zid)
+	}
+	if zettel, ok := p.zettel[zid]; ok {
+		return zettel, nil
+	}
+	return domain.Zettel{}, &place.ErrUnknownID{Zid: zid}
+}
+
+func (p *testRenamePort) UpdateZettel(ctx context.Context, zettel domain.Zettel) error {
+	p.zettel[zettel.Meta.Zid] = zettel
+	return nil
+}
+
+func (p *testRenamePort) RenameZettel(ctx context.Context, curZid, newZid domain.ZettelID) error {
+	zettel := p.zettel[curZid]
+	delete(p.zettel, curZid)
+	zettel.Meta = zettel.Meta.Clone()
+	zettel.Meta.Zid = newZid
+	p.zettel[newZid] = zettel
+	return nil
+}
+
+type testRenameIndex []domain.ZettelID
+
+func (idx testRenameIndex) Backward(zid domain.ZettelID) []domain.ZettelID { return idx }
+
+func TestRenameZettel(t *testing.T) {
+	const curID, newID = domain.ZettelID(20201010101000), domain.ZettelID(20201111111100)
+	const refID, secretID = domain.ZettelID(20201010101001), domain.ZettelID(20201010101002)
+	newZettel := func(zid domain.ZettelID, content string) domain.Zettel {
+		meta := domain.NewMeta(zid)
+		meta.Set(domain.MetaKeySyntax, "zmk")
+		return domain.Zettel{Meta: meta, Content: domain.NewContent(content)}
+	}
+	port := &testRenamePort{
+		zettel: map[domain.ZettelID]domain.Zettel{
+			curID: newZettel(curID, "Renamed"),
+			refID: newZettel(refID, "[[Link|20201010101000#frag]]"),
+		},
+		secret: secretID,
+	}
+	uc := NewRenameZettel(port, testRenameIndex{refID, secretID}, NewUpdateZettel(port, noSchema()))
+	result, err := uc.Run(context.Background(), curID, newID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rewritten) != 1 || len(result.Failed) != 0 || result.Skipped != 1 {
+		t.Errorf("Expected 1 rewritten, 0 failed, 1 skipped, but got %d/%d/%d",
+			len(result.Rewritten), len(result.Failed), result.Skipped)
+	}
+	ref := port.zettel[refID]
+	if got, exp := ref.Content.AsString(), "[[Link|20201111111100#frag]]"; got != exp {
+		t.Errorf("Expected content %q, but got %q", exp, got)
+	}
+	if _, ok := ref.Meta.Get(domain.MetaKeyModified); !ok {
+		t.Error("Rewritten zettel has no modification time")
+	}
+}
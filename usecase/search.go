@@ -22,8 +22,10 @@ package usecase
 
 import (
 	"context"
+	"sort"
 
 	"zettelstore.de/z/domain"
+	"zettelstore.de/z/index"
 	"zettelstore.de/z/place"
 )
 
@@ -34,18 +36,72 @@ type SearchPort interface {
 	SelectMeta(ctx context.Context, f *place.Filter, s *place.Sorter) ([]*domain.Meta, error)
 }
 
+// SearchIndex is the full-text index used by this use case.
+type SearchIndex interface {
+	// SearchText returns all zettel that contain the words of the given
+	// query, ranked by their relevance.
+	SearchText(query string) []index.Hit
+}
+
 // Search is the data for this use case.
 type Search struct {
-	port SearchPort
+	port  SearchPort
+	index SearchIndex
 }
 
-// NewSearch creates a new use case.
-func NewSearch(port SearchPort) Search {
-	return Search{port: port}
+// NewSearch creates a new use case. If idx is nil, only meta data is searched.
+func NewSearch(port SearchPort, idx SearchIndex) Search {
+	return Search{port: port, index: idx}
 }
 
-// Run executes the use case.
+// Run executes the use case. Words of the filter are also matched against the
+// content of a zettel. If no sort order is given, the result is ordered by
+// the relevance of the content matches.
 func (uc Search) Run(ctx context.Context, f *place.Filter, s *place.Sorter) ([]*domain.Meta, error) {
-	// TODO: interpret f[""]. Can contain expressions for specific meta tags.
-	return uc.port.SelectMeta(ctx, f, s)
+	words := f.ContentWords()
+	if uc.index == nil || len(words) == 0 {
+		return uc.port.SelectMeta(ctx, f, s)
+	}
+
+	found := make(map[string]map[domain.ZettelID]bool, len(words))
+	scores := make(map[domain.ZettelID]float64)
+	for _, word := range words {
+		if _, ok := found[word]; ok {
+			continue
+		}
+		zids := make(map[domain.ZettelID]bool)
+		for _, hit := range uc.index.SearchText(word) {
+			zids[hit.Zid] = true
+			scores[hit.Zid] += hit.Score
+		}
+		found[word] = zids
+	}
+	cf := *f
+	cf.MatchContent = func(zid domain.ZettelID, word string) bool {
+		return found[word][zid]
+	}
+
+	if s != nil && s.Order != "" {
+		return uc.port.SelectMeta(ctx, &cf, s)
+	}
+	result, err := uc.port.SelectMeta(ctx, &cf, nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return scores[result[i].Zid] > scores[result[j].Zid]
+	})
+	if s == nil {
+		return result, nil
+	}
+	if s.Offset > 0 {
+		if s.Offset > len(result) {
+			return nil, nil
+		}
+		result = result[s.Offset:]
+	}
+	if s.Limit > 0 && s.Limit < len(result) {
+		result = result[:s.Limit]
+	}
+	return result, nil
 }
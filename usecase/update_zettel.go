@@ -22,8 +22,10 @@ package usecase
 
 import (
 	"context"
+	"time"
 
 	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
 )
 
 // UpdateZettelPort is the interface used by this use case.
@@ -37,21 +39,28 @@ type UpdateZettelPort interface {
 
 // UpdateZettel is the data for this use case.
 type UpdateZettel struct {
-	store UpdateZettelPort
+	store     UpdateZettelPort
+	getSchema GetSchema
 }
 
-// NewUpdateZettel creates a new use case.
-func NewUpdateZettel(port UpdateZettelPort) UpdateZettel {
-	return UpdateZettel{store: port}
+// NewUpdateZettel creates a new use case. The meta data of the zettel is
+// validated with the given use case.
+func NewUpdateZettel(port UpdateZettelPort, getSchema GetSchema) UpdateZettel {
+	return UpdateZettel{store: port, getSchema: getSchema}
 }
 
-// Run executes the use case.
-func (uc UpdateZettel) Run(ctx context.Context, zettel domain.Zettel) error {
+// Run executes the use case. If version is not empty, the zettel is only
+// updated if its current version matches. Otherwise a
+// place.ErrVersionConflict is returned.
+func (uc UpdateZettel) Run(ctx context.Context, zettel domain.Zettel, version string) error {
 	meta := zettel.Meta
-	oldZettel, err := uc.store.GetZettel(ctx, meta.Zid)
+	oldZettel, curVersion, err := getVersionedZettel(ctx, uc.store, meta.Zid)
 	if err != nil {
 		return err
 	}
+	if version != "" && version != curVersion {
+		return &place.ErrVersionConflict{Zid: meta.Zid, Version: curVersion}
+	}
 	if zettel.Equal(oldZettel) {
 		return nil
 	}
@@ -59,5 +68,29 @@ func (uc UpdateZettel) Run(ctx context.Context, zettel domain.Zettel) error {
 	if meta.Zid == domain.ConfigurationID {
 		meta.Set(domain.MetaKeySyntax, "meta")
 	}
+	if created, ok := oldZettel.Meta.Get(domain.MetaKeyCreated); ok {
+		meta.Set(domain.MetaKeyCreated, created)
+	} else {
+		meta.Delete(domain.MetaKeyCreated)
+	}
+	setModified(ctx, meta, time.Now())
+	if err := uc.getSchema.validate(ctx, meta); err != nil {
+		return err
+	}
+	if v, ok := uc.store.(place.Versioner); ok && version != "" {
+		// The zettel may have been changed while it was checked.
+		return v.UpdateVersionedZettel(ctx, zettel, version)
+	}
 	return uc.store.UpdateZettel(ctx, zettel)
 }
+
+// setModified records the given time and the current user as the last
+// modification of a zettel.
+func setModified(ctx context.Context, meta *domain.Meta, now time.Time) {
+	meta.Set(domain.MetaKeyModified, domain.TimestampValue(now))
+	if user := place.GetUser(ctx); user != nil {
+		meta.Set(domain.MetaKeyEditor, user.Zid.Format())
+	} else {
+		meta.Delete(domain.MetaKeyEditor)
+	}
+}
@@ -0,0 +1,120 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package usecase provides (business) use cases for the zettelstore.
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+)
+
+type testUpdatePort struct {
+	zettel domain.Zettel
+}
+
+func (p *testUpdatePort) GetZettel(ctx context.Context, zid domain.ZettelID) (domain.Zettel, error) {
+	return p.zettel, nil
+}
+
+func (p *testUpdatePort) UpdateZettel(ctx context.Context, zettel domain.Zettel) error {
+	p.zettel = zettel
+	return nil
+}
+
+func TestUpdateZettelMaintainedKeys(t *testing.T) {
+	const zid = domain.ZettelID(20210101000000)
+	oldMeta := domain.NewMeta(zid)
+	oldMeta.Set(domain.MetaKeyTitle, "Old")
+	oldMeta.Set(domain.MetaKeyCreated, "20210101000000")
+	oldMeta.Set(domain.MetaKeyModified, "20210102000000")
+	port := &testUpdatePort{zettel: domain.Zettel{Meta: oldMeta, Content: domain.NewContent("Content")}}
+
+	newMeta := oldMeta.Clone()
+	newMeta.Set(domain.MetaKeyTitle, "New")
+	newMeta.Set(domain.MetaKeyCreated, "20000101")
+	newMeta.Set(domain.MetaKeyEditor, "20210101000001")
+	err := NewUpdateZettel(port, noSchema()).Run(
+		context.Background(), domain.Zettel{Meta: newMeta, Content: domain.NewContent("Content")}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := port.zettel.Meta
+	if got, _ := meta.Get(domain.MetaKeyTitle); got != "New" {
+		t.Errorf("Title not updated: %q", got)
+	}
+	if got, _ := meta.Get(domain.MetaKeyCreated); got != "20210101000000" {
+		t.Errorf("Creation time changed to %q", got)
+	}
+	if got, _ := meta.Get(domain.MetaKeyModified); got <= "20210102000000" {
+		t.Errorf("Modification time not updated: %q", got)
+	}
+	if got, ok := meta.Get(domain.MetaKeyEditor); ok {
+		t.Errorf("Editor without user: %q", got)
+	}
+}
+
+func TestUpdateZettelEditor(t *testing.T) {
+	const zid = domain.ZettelID(20210101000000)
+	oldMeta := domain.NewMeta(zid)
+	oldMeta.Set(domain.MetaKeyTitle, "Old")
+	port := &testUpdatePort{zettel: domain.Zettel{Meta: oldMeta, Content: domain.NewContent("Content")}}
+
+	newMeta := oldMeta.Clone()
+	newMeta.Set(domain.MetaKeyTitle, "New")
+	user := domain.NewMeta(20210101000001)
+	ctx := place.WithUser(context.Background(), user)
+	if err := NewUpdateZettel(port, noSchema()).Run(ctx, domain.Zettel{Meta: newMeta, Content: domain.NewContent("Content")}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := port.zettel.Meta.Get(domain.MetaKeyEditor); got != "20210101000001" {
+		t.Errorf("Editor not set to user: %q", got)
+	}
+}
+
+func TestUpdateZettelVersionConflict(t *testing.T) {
+	const zid = domain.ZettelID(20210101000000)
+	oldMeta := domain.NewMeta(zid)
+	oldMeta.Set(domain.MetaKeyTitle, "Old")
+	oldZettel := domain.Zettel{Meta: oldMeta, Content: domain.NewContent("Content")}
+	port := &testUpdatePort{zettel: oldZettel}
+
+	newMeta := oldMeta.Clone()
+	newMeta.Set(domain.MetaKeyTitle, "New")
+	uc := NewUpdateZettel(port, noSchema())
+	err := uc.Run(context.Background(), domain.Zettel{Meta: newMeta, Content: domain.NewContent("Content")}, "stale")
+	if _, ok := err.(*place.ErrVersionConflict); !ok {
+		t.Fatalf("Version conflict expected, but got %v", err)
+	}
+	if got, _ := port.zettel.Meta.Get(domain.MetaKeyTitle); got != "Old" {
+		t.Errorf("Zettel was updated despite a conflict: %q", got)
+	}
+
+	err = uc.Run(context.Background(), domain.Zettel{Meta: newMeta, Content: domain.NewContent("Content")},
+		place.CalcVersion(oldZettel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := port.zettel.Meta.Get(domain.MetaKeyTitle); got != "New" {
+		t.Errorf("Zettel was not updated with current version: %q", got)
+	}
+}
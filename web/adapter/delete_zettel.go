@@ -0,0 +1,45 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package adapter provides handlers for web requests.
+package adapter
+
+import (
+	"net/http"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/usecase"
+)
+
+// MakeDeleteZettelHandler creates a new HTTP handler to delete a zettel.
+func MakeDeleteZettelHandler(deleteZettel usecase.DeleteZettel) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zid, err := domain.ParseZettelID(r.URL.Path[1:])
+		if err != nil {
+			writeJSONError(w, http.StatusNotFound, "Not a zettel identifier")
+			return
+		}
+
+		if err := deleteZettel.Run(r.Context(), zid); err != nil {
+			checkUsecaseErrorJSON(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,46 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package adapter provides handlers for web requests.
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"zettelstore.de/z/usecase"
+)
+
+func TestDeleteZettel(t *testing.T) {
+	p, pp := startAPIPlace(t)
+	defer p.Stop(context.Background())
+	h := MakeDeleteZettelHandler(usecase.NewDeleteZettel(pp))
+	zid := createEventZettel(t, p, "Public")
+
+	checkStatus(t, serveAPI(h, http.MethodDelete, "/"+zid.Format(), ""), http.StatusNoContent)
+	if _, err := p.GetMeta(context.Background(), zid); err == nil {
+		t.Errorf("Zettel %v was not deleted", zid)
+	}
+	checkStatus(t, serveAPI(h, http.MethodDelete, "/"+zid.Format(), ""), http.StatusNotFound)
+	checkStatus(t, serveAPI(h, http.MethodDelete, "/19000101000000", ""), http.StatusNotFound)
+
+	protected := createEventZettel(t, p, "Protected")
+	checkStatus(t, serveAPI(h, http.MethodDelete, "/"+protected.Format(), ""), http.StatusForbidden)
+}
@@ -0,0 +1,260 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package adapter provides handlers for web requests.
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"zettelstore.de/z/auth/policy"
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+	"zettelstore.de/z/web/session"
+)
+
+// eventBufferSize is the number of events that can be queued for a client.
+// If a client does not read its events fast enough, it is disconnected.
+// It should reconnect and reload all zettel it is interested in.
+const eventBufferSize = 64
+
+// eventPingTime is the duration after an idle event stream is pinged.
+const eventPingTime = 30 * time.Second
+
+type eventPlace interface {
+	// GetMeta retrieves just the meta data of a specific zettel.
+	GetMeta(ctx context.Context, zid domain.ZettelID) (*domain.Meta, error)
+
+	// SelectMeta returns all zettel meta data that match the selection criteria.
+	SelectMeta(ctx context.Context, f *place.Filter, s *place.Sorter) ([]*domain.Meta, error)
+}
+
+type eventClient struct {
+	user   *domain.Meta
+	events chan []byte
+}
+
+// EventHub distributes all changes of zettel to connected clients.
+type EventHub struct {
+	place  eventPlace
+	policy policy.Policy
+
+	signal    chan struct{}
+	mxPending sync.Mutex
+	pending   []place.ChangeInfo
+
+	mx      sync.Mutex
+	loaded  bool
+	metas   map[domain.ZettelID]*domain.Meta
+	clients map[*eventClient]bool
+}
+
+// NewEventHub creates a new hub for the changes of the given place.
+func NewEventHub(p place.Place, pol policy.Policy) *EventHub {
+	eh := &EventHub{
+		place:   p,
+		policy:  pol,
+		signal:  make(chan struct{}, 1),
+		clients: make(map[*eventClient]bool),
+	}
+	p.RegisterChangeObserver(eh.observe)
+	go eh.worker()
+	return eh
+}
+
+// observe records all changes of the place. They are distributed later by
+// the worker, because a place may call the observer while it is locked.
+func (eh *EventHub) observe(ci place.ChangeInfo) {
+	eh.mxPending.Lock()
+	eh.pending = append(eh.pending, ci)
+	eh.mxPending.Unlock()
+	select {
+	case eh.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (eh *EventHub) worker() {
+	for range eh.signal {
+		eh.mxPending.Lock()
+		pending := eh.pending
+		eh.pending = nil
+		eh.mxPending.Unlock()
+
+		ctx := context.Background()
+		for _, ci := range pending {
+			eh.dispatch(ctx, ci)
+		}
+	}
+}
+
+func (eh *EventHub) dispatch(ctx context.Context, ci place.ChangeInfo) {
+	eh.mx.Lock()
+	defer eh.mx.Unlock()
+	if !eh.loaded {
+		// No client was ever connected, so there is nobody to notify.
+		return
+	}
+	if ci.Reason == place.OnReload {
+		eh.loadMetas(ctx)
+	}
+
+	// A deleted zettel can only be checked with its last known meta data.
+	var meta *domain.Meta
+	switch ci.Reason {
+	case place.OnCreate, place.OnUpdate:
+		meta = eh.fetchMeta(ctx, ci.Zid)
+	case place.OnDelete:
+		meta = eh.metas[ci.Zid]
+		delete(eh.metas, ci.Zid)
+	case place.OnRename:
+		delete(eh.metas, ci.Zid)
+		meta = eh.fetchMeta(ctx, ci.NewZid)
+	}
+	if meta == nil && ci.Reason != place.OnReload {
+		return
+	}
+
+	ev := formatEvent(ci)
+	for client := range eh.clients {
+		if meta != nil && !eh.policy.CanRead(client.user, meta) {
+			continue
+		}
+		select {
+		case client.events <- ev:
+		default:
+			delete(eh.clients, client)
+			close(client.events)
+		}
+	}
+}
+
+func (eh *EventHub) loadMetas(ctx context.Context) {
+	metaList, err := eh.place.SelectMeta(ctx, nil, nil)
+	if err != nil {
+		log.Println("EVENT", "ERROR", err)
+		return
+	}
+	eh.metas = make(map[domain.ZettelID]*domain.Meta, len(metaList))
+	for _, meta := range metaList {
+		eh.metas[meta.Zid] = meta
+	}
+	eh.loaded = true
+}
+
+func (eh *EventHub) fetchMeta(ctx context.Context, zid domain.ZettelID) *domain.Meta {
+	meta, err := eh.place.GetMeta(ctx, zid)
+	if err != nil {
+		return nil
+	}
+	if eh.metas != nil {
+		eh.metas[zid] = meta
+	}
+	return meta
+}
+
+func formatEvent(ci place.ChangeInfo) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("event: ")
+	buf.WriteString(ci.Reason.String())
+	buf.WriteString("\ndata: {")
+	if ci.Reason != place.OnReload {
+		buf.WriteString("\"id\":\"")
+		buf.WriteString(ci.Zid.Format())
+		buf.WriteByte('"')
+		if ci.Reason == place.OnRename {
+			buf.WriteString(",\"new-id\":\"")
+			buf.WriteString(ci.NewZid.Format())
+			buf.WriteByte('"')
+		}
+	}
+	buf.WriteString("}\n\n")
+	return buf.Bytes()
+}
+
+func (eh *EventHub) subscribe(user *domain.Meta) *eventClient {
+	client := &eventClient{
+		user:   user,
+		events: make(chan []byte, eventBufferSize),
+	}
+	eh.mx.Lock()
+	if !eh.loaded {
+		eh.loadMetas(context.Background())
+	}
+	eh.clients[client] = true
+	eh.mx.Unlock()
+	return client
+}
+
+func (eh *EventHub) unsubscribe(client *eventClient) {
+	eh.mx.Lock()
+	if eh.clients[client] {
+		delete(eh.clients, client)
+		close(client.events)
+	}
+	eh.mx.Unlock()
+}
+
+// MakeGetEventsHandler creates a new HTTP handler that streams all changes of
+// zettel, which the current user is allowed to read, as server-sent events.
+func MakeGetEventsHandler(hub *EventHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Event streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		ctx := r.Context()
+		client := hub.subscribe(session.GetUser(ctx))
+		defer hub.unsubscribe(client)
+
+		h := w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		ticker := time.NewTicker(eventPingTime)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-client.events:
+				if !ok {
+					return
+				}
+				if _, err := w.Write(ev); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,164 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package adapter provides handlers for web requests.
+package adapter
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+	_ "zettelstore.de/z/place/memplace"
+)
+
+// eventPolicy allows to read zettel with a title "Secret" only for known
+// users. Everything else is allowed.
+type eventPolicy struct{}
+
+func (p eventPolicy) CanReload(user *domain.Meta) bool                       { return true }
+func (p eventPolicy) CanCreate(user *domain.Meta, newMeta *domain.Meta) bool { return true }
+func (p eventPolicy) CanRead(user *domain.Meta, meta *domain.Meta) bool {
+	return user != nil || meta.GetDefault(domain.MetaKeyTitle, "") != "Secret"
+}
+func (p eventPolicy) CanWrite(user *domain.Meta, oldMeta, newMeta *domain.Meta) bool { return true }
+func (p eventPolicy) CanRename(user *domain.Meta, meta *domain.Meta) bool            { return true }
+func (p eventPolicy) CanDelete(user *domain.Meta, meta *domain.Meta) bool            { return true }
+func (p eventPolicy) CanTrash(user *domain.Meta, meta *domain.Meta) bool             { return true }
+
+func startEventPlace(t *testing.T) place.Place {
+	t.Helper()
+	p, err := place.Connect("mem:", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func createEventZettel(t *testing.T, p place.Place, title string) domain.ZettelID {
+	t.Helper()
+	meta := domain.NewMeta(domain.InvalidZettelID)
+	meta.Set(domain.MetaKeyTitle, title)
+	zid, err := p.CreateZettel(context.Background(), domain.Zettel{Meta: meta, Content: domain.NewContent("")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zid
+}
+
+// receiveEvents returns all events a client received until no more events
+// arrive.
+func receiveEvents(client *eventClient) []string {
+	var result []string
+	for {
+		select {
+		case ev := <-client.events:
+			result = append(result, string(ev))
+		case <-time.After(100 * time.Millisecond):
+			return result
+		}
+	}
+}
+
+func TestEventHubCanRead(t *testing.T) {
+	p := startEventPlace(t)
+	defer p.Stop(context.Background())
+	hub := NewEventHub(p, eventPolicy{})
+	anon := hub.subscribe(nil)
+	defer hub.unsubscribe(anon)
+	user := hub.subscribe(domain.NewMeta(domain.ZettelID(20210101000000)))
+	defer hub.unsubscribe(user)
+
+	public := createEventZettel(t, p, "Public")
+	secret := createEventZettel(t, p, "Secret")
+	publicEvent := string(formatEvent(place.ChangeInfo{Reason: place.OnCreate, Zid: public}))
+	secretEvent := string(formatEvent(place.ChangeInfo{Reason: place.OnCreate, Zid: secret}))
+	checkEvents(t, "Anonymous user", receiveEvents(anon), publicEvent)
+	checkEvents(t, "User", receiveEvents(user), publicEvent, secretEvent)
+
+	// A deleted zettel is checked with its last known meta data.
+	if err := p.DeleteZettel(context.Background(), secret); err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, "Anonymous user", receiveEvents(anon))
+	checkEvents(t, "User", receiveEvents(user),
+		string(formatEvent(place.ChangeInfo{Reason: place.OnDelete, Zid: secret})))
+}
+
+func checkEvents(t *testing.T, who string, got []string, exp ...string) {
+	t.Helper()
+	if strings.Join(got, "") != strings.Join(exp, "") {
+		t.Errorf("%s: events %q expected, but got %q", who, exp, got)
+	}
+}
+
+func TestGetEventsHandler(t *testing.T) {
+	p := startEventPlace(t)
+	defer p.Stop(context.Background())
+	hub := NewEventHub(p, eventPolicy{})
+	srv := httptest.NewServer(MakeGetEventsHandler(hub))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content type %q expected, but got %q", "text/event-stream", ct)
+	}
+
+	createEventZettel(t, p, "Secret")
+	zid := createEventZettel(t, p, "Public")
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	var got []string
+	for len(got) < 2 {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("Event stream closed after %q", got)
+			}
+			if line != "" {
+				got = append(got, line)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout after %q", got)
+		}
+	}
+	exp := []string{"event: create", "data: {\"id\":\"" + zid.Format() + "\"}"}
+	if got[0] != exp[0] || got[1] != exp[1] {
+		t.Errorf("Events %q expected, but got %q", exp, got)
+	}
+}
@@ -45,11 +45,12 @@ func MakeGetZettelHandler(
 		}
 
 		ctx := r.Context()
-		zettel, err := getZettel.Run(ctx, zid)
+		zettel, version, err := getZettel.RunVersioned(ctx, zid)
 		if err != nil {
 			checkUsecaseError(w, err)
 			return
 		}
+		setETag(w, version)
 		syntax := r.URL.Query().Get("syntax")
 		z, meta := parser.ParseZettel(zettel, syntax)
 
@@ -36,14 +36,22 @@ import (
 // MakeListMetaHandler creates a new HTTP handler for the use case "list some zettel".
 func MakeListMetaHandler(te *TemplateEngine, listMeta usecase.ListMeta) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		filter, sorter := getFilterSorter(r)
+		format := getFormat(r, encoder.GetDefaultFormat())
+		filter, sorter, err := getFilterSorter(r)
+		if err != nil {
+			if format == "json" || format == "djson" {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		metaList, err := listMeta.Run(r.Context(), filter, sorter)
 		if err != nil {
 			checkUsecaseError(w, err)
 			return
 		}
 
-		format := getFormat(r, encoder.GetDefaultFormat())
 		w.Header().Set("Content-Type", format2ContentType(format))
 		switch format {
 		case "html":
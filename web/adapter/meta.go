@@ -76,8 +76,16 @@ func (m metaWrapper) Pairs() []domain.MetaPair {
 }
 
 // PairsRest return a list of all key/value paris except the four basic ones.
+// Computed values are not included, because they cannot be changed.
 func (m metaWrapper) PairsRest() []domain.MetaPair {
-	return m.original.PairsRest()
+	pairs := m.original.PairsRest()
+	result := pairs[:0]
+	for _, p := range pairs {
+		if !domain.IsComputedKey(p.Key) {
+			result = append(result, p)
+		}
+	}
+	return result
 }
 
 // userWrapper is a wrapper around a user meta object.
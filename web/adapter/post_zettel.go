@@ -0,0 +1,54 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package adapter provides handlers for web requests.
+package adapter
+
+import (
+	"net/http"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/encoder"
+	"zettelstore.de/z/usecase"
+)
+
+// MakePostCreateZettelHandler creates a new HTTP handler to store a new zettel,
+// which is sent as a JSON object.
+func MakePostCreateZettelHandler(newZettel usecase.NewZettel) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zettel, err := parseZettelJSON(r, domain.InvalidZettelID)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Unable to read zettel: "+err.Error())
+			return
+		}
+
+		newZid, err := newZettel.Run(r.Context(), zettel)
+		if err != nil {
+			checkUsecaseErrorJSON(w, err)
+			return
+		}
+		u := urlForZettel('z', newZid)
+		w.Header().Set("Content-Type", format2ContentType("json"))
+		w.Header().Set("Location", u)
+		w.WriteHeader(http.StatusCreated)
+		buf := encoder.NewBufWriter(w)
+		buf.WriteStrings("{\"id\":\"", newZid.Format(), "\",\"url\":\"", u, "\"}")
+		buf.Flush()
+	}
+}
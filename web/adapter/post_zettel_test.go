@@ -0,0 +1,129 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package adapter provides handlers for web requests.
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"zettelstore.de/z/auth/policy"
+	"zettelstore.de/z/config"
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+	_ "zettelstore.de/z/place/constplace"
+	_ "zettelstore.de/z/place/memplace"
+	"zettelstore.de/z/usecase"
+)
+
+// apiPolicy allows everything, except changing zettel with a title
+// "Protected".
+type apiPolicy struct{ eventPolicy }
+
+func (p apiPolicy) CanCreate(user *domain.Meta, newMeta *domain.Meta) bool {
+	return !isProtected(newMeta)
+}
+func (p apiPolicy) CanWrite(user *domain.Meta, oldMeta, newMeta *domain.Meta) bool {
+	return !isProtected(oldMeta)
+}
+func (p apiPolicy) CanDelete(user *domain.Meta, meta *domain.Meta) bool { return !isProtected(meta) }
+func (p apiPolicy) CanTrash(user *domain.Meta, meta *domain.Meta) bool  { return false }
+
+func isProtected(meta *domain.Meta) bool {
+	return meta.GetDefault(domain.MetaKeyTitle, "") == "Protected"
+}
+
+var setupConfig sync.Once
+
+// startAPIPlace returns a started place that checks the API policy. The
+// configuration is read from the predefined zettel.
+func startAPIPlace(t *testing.T) (place.Place, place.Place) {
+	t.Helper()
+	setupConfig.Do(func() {
+		cp, err := place.Connect("globals:", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cp.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		config.SetupConfiguration(cp)
+	})
+	p := startEventPlace(t)
+	return p, policy.NewPlace(p, apiPolicy{}, nil)
+}
+
+// serveAPI sends a request with the given body to the handler on behalf of
+// a known user.
+func serveAPI(h http.HandlerFunc, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	user := domain.NewMeta(domain.ZettelID(20210101000000))
+	r = r.WithContext(place.WithUser(r.Context(), user))
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func checkStatus(t *testing.T, w *httptest.ResponseRecorder, code int) {
+	t.Helper()
+	if w.Code != code {
+		t.Errorf("Status %d expected, but got %d: %s", code, w.Code, w.Body.String())
+	}
+}
+
+func TestPostCreateZettel(t *testing.T) {
+	p, pp := startAPIPlace(t)
+	defer p.Stop(context.Background())
+	h := MakePostCreateZettelHandler(usecase.NewNewZettel(pp, usecase.NewGetSchema(p)))
+
+	w := serveAPI(h, http.MethodPost, "/", `{"meta":{"title":"New"},"content":"Text"}`)
+	checkStatus(t, w, http.StatusCreated)
+	var res struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	zid, err := domain.ParseZettelID(res.ID)
+	if err != nil {
+		t.Fatalf("No valid zettel id returned: %q", w.Body.String())
+	}
+	if loc := w.Header().Get("Location"); !strings.HasSuffix(loc, zid.Format()) {
+		t.Errorf("Location %q does not refer to new zettel %v", loc, zid)
+	}
+	zettel, err := p.GetZettel(context.Background(), zid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := zettel.Content.AsString(); got != "Text" {
+		t.Errorf("Content %q expected, but got %q", "Text", got)
+	}
+
+	checkStatus(t, serveAPI(h, http.MethodPost, "/", `{"meta":`), http.StatusBadRequest)
+	checkStatus(t, serveAPI(h, http.MethodPost, "/", `{"meta":{"title":"Protected"}}`), http.StatusForbidden)
+}
@@ -0,0 +1,52 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package adapter provides handlers for web requests.
+package adapter
+
+import (
+	"net/http"
+
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/usecase"
+)
+
+// MakePutUpdateZettelHandler creates a new HTTP handler to replace an existing
+// zettel with the one sent as a JSON object. If the request contains an
+// "If-Match" header, the zettel is only replaced if its version still matches.
+func MakePutUpdateZettelHandler(updateZettel usecase.UpdateZettel) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zid, err := domain.ParseZettelID(r.URL.Path[1:])
+		if err != nil {
+			writeJSONError(w, http.StatusNotFound, "Not a zettel identifier")
+			return
+		}
+		zettel, err := parseZettelJSON(r, zid)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Unable to read zettel: "+err.Error())
+			return
+		}
+
+		if err := updateZettel.Run(r.Context(), zettel, getIfMatchVersion(r)); err != nil {
+			checkUsecaseErrorJSON(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,62 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package adapter provides handlers for web requests.
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"zettelstore.de/z/place"
+	"zettelstore.de/z/usecase"
+)
+
+func TestPutUpdateZettel(t *testing.T) {
+	p, pp := startAPIPlace(t)
+	defer p.Stop(context.Background())
+	h := MakePutUpdateZettelHandler(usecase.NewUpdateZettel(pp, usecase.NewGetSchema(p)))
+	zid := createEventZettel(t, p, "Public")
+	path := "/" + zid.Format()
+	_, version, err := place.GetVersionedZettel(context.Background(), p, zid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkStatus(t, serveAPI(h, http.MethodPut, path, `{"meta":{"title":"New"`), http.StatusBadRequest)
+	checkStatus(t,
+		serveAPI(h, http.MethodPut, path, `{"meta":{"title":"New"},"content":"Text"}`, "If-Match", `"`+version+`"`),
+		http.StatusNoContent)
+	zettel, err := p.GetZettel(context.Background(), zid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := zettel.Content.AsString(); got != "Text" {
+		t.Errorf("Content %q expected, but got %q", "Text", got)
+	}
+
+	// The version is stale, because the zettel was updated.
+	checkStatus(t,
+		serveAPI(h, http.MethodPut, path, `{"meta":{"title":"Newer"}}`, "If-Match", `"`+version+`"`),
+		http.StatusPreconditionFailed)
+
+	protected := "/" + createEventZettel(t, p, "Protected").Format()
+	checkStatus(t, serveAPI(h, http.MethodPut, protected, `{"meta":{"title":"Public"}}`), http.StatusForbidden)
+}
@@ -21,6 +21,8 @@
 package adapter
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -54,7 +56,7 @@ func getOneFormat(r *http.Request, key string) (string, bool) {
 	return "", false
 }
 
-func getFilterSorter(r *http.Request) (filter *place.Filter, sorter *place.Sorter) {
+func getFilterSorter(r *http.Request) (filter *place.Filter, sorter *place.Sorter, err error) {
 	for key, values := range r.URL.Query() {
 		switch key {
 		case "_sort":
@@ -89,16 +91,18 @@ func getFilterSorter(r *http.Request) (filter *place.Filter, sorter *place.Sorte
 			filter = ensureFilter(filter)
 			filter.Negate = true
 		case "_s":
-			cleanedValues := make([]string, 0, len(values))
-			for _, val := range values {
-				if len(val) > 0 {
-					cleanedValues = append(cleanedValues, val)
-				}
+			query, err := getQuery(values)
+			if err != nil {
+				return nil, nil, err
 			}
-			if len(cleanedValues) > 0 {
+			if !query.IsEmpty() {
 				filter = ensureFilter(filter)
-				filter.Expr[""] = cleanedValues
+				filter.Query = query
 			}
+		case "backlink":
+			// Select all zettel that reference the given zettel.
+			filter = ensureFilter(filter)
+			filter.Expr[domain.MetaKeyForward] = append(filter.Expr[domain.MetaKeyForward], values...)
 		default:
 			if domain.KeyIsValid(key) {
 				filter = ensureFilter(filter)
@@ -106,7 +110,12 @@ func getFilterSorter(r *http.Request) (filter *place.Filter, sorter *place.Sorte
 			}
 		}
 	}
-	return filter, sorter
+	return filter, sorter, nil
+}
+
+// getQuery parses the given values as one query.
+func getQuery(values []string) (*place.Query, error) {
+	return place.ParseQuery(strings.Join(values, " "))
 }
 
 func ensureFilter(filter *place.Filter) *place.Filter {
@@ -123,3 +132,56 @@ func ensureSorter(sorter *place.Sorter) *place.Sorter {
 	}
 	return sorter
 }
+
+// getIfMatchVersion returns the zettel version given in the "If-Match" header.
+// An empty string signals that any version is acceptable.
+func getIfMatchVersion(r *http.Request) string {
+	version := strings.TrimSpace(r.Header.Get("If-Match"))
+	if version == "*" {
+		return ""
+	}
+	version = strings.TrimPrefix(version, "W/")
+	return strings.Trim(version, "\"")
+}
+
+// zettelJSON is the structure of a zettel, when sent as a JSON object.
+type zettelJSON struct {
+	Meta    map[string]interface{} `json:"meta"`
+	Content string                 `json:"content"`
+}
+
+func parseZettelJSON(r *http.Request, zid domain.ZettelID) (domain.Zettel, error) {
+	var zj zettelJSON
+	if err := json.NewDecoder(r.Body).Decode(&zj); err != nil {
+		return domain.Zettel{}, err
+	}
+	meta := domain.NewMeta(zid)
+	for key, value := range zj.Meta {
+		if key == domain.MetaKeyID {
+			continue
+		}
+		if !domain.KeyIsValid(key) {
+			return domain.Zettel{}, fmt.Errorf("invalid meta key %q", key)
+		}
+		switch val := value.(type) {
+		case string:
+			meta.Set(key, strings.TrimSpace(val))
+		case []interface{}:
+			values := make([]string, 0, len(val))
+			for _, elem := range val {
+				s, ok := elem.(string)
+				if !ok {
+					return domain.Zettel{}, fmt.Errorf("invalid list value for meta key %q", key)
+				}
+				values = append(values, strings.TrimSpace(s))
+			}
+			meta.SetList(key, values)
+		default:
+			return domain.Zettel{}, fmt.Errorf("invalid value for meta key %q", key)
+		}
+	}
+	return domain.Zettel{
+		Meta:    meta,
+		Content: domain.NewContent(zj.Content),
+	}, nil
+}
@@ -24,27 +24,97 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 
+	"zettelstore.de/z/encoder"
+	"zettelstore.de/z/encoder/jsonenc"
 	"zettelstore.de/z/place"
+	"zettelstore.de/z/usecase"
 )
 
 func checkUsecaseError(w http.ResponseWriter, err error) {
-	if err, ok := err.(*place.ErrUnknownID); ok {
-		http.Error(w, fmt.Sprintf("Zettel %q not found", err.Zid.Format()), http.StatusNotFound)
+	code, text := classifyUsecaseError(err)
+	http.Error(w, text, code)
+}
+
+// checkUsecaseErrorJSON writes the error as a JSON object to the client.
+func checkUsecaseErrorJSON(w http.ResponseWriter, err error) {
+	if err, ok := err.(*usecase.ErrSchemaViolation); ok {
+		writeSchemaErrorJSON(w, err)
 		return
 	}
+	code, text := classifyUsecaseError(err)
+	writeJSONError(w, code, text)
+}
+
+func classifyUsecaseError(err error) (int, string) {
+	if err, ok := err.(*place.ErrUnknownID); ok {
+		return http.StatusNotFound, fmt.Sprintf("Zettel %q not found", err.Zid.Format())
+	}
 	if err, ok := err.(*place.ErrNotAuthorized); ok {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		return
+		if err.User != nil {
+			// An authenticated user is not allowed to perform the operation.
+			return http.StatusForbidden, err.Error()
+		}
+		return http.StatusUnauthorized, err.Error()
 	}
 	if err, ok := err.(*place.ErrInvalidID); ok {
-		http.Error(w, fmt.Sprintf("Zettel-ID %q not appropriate in this context", err.Zid.Format()), http.StatusBadRequest)
-		return
+		return http.StatusBadRequest, fmt.Sprintf("Zettel-ID %q not appropriate in this context", err.Zid.Format())
+	}
+	if err, ok := err.(*place.ErrVersionConflict); ok {
+		return http.StatusPreconditionFailed, fmt.Sprintf("Zettel %q was changed in the meantime", err.Zid.Format())
+	}
+	if err, ok := err.(*usecase.ErrSchemaViolation); ok {
+		return http.StatusUnprocessableEntity, err.Error()
+	}
+	if err == place.ErrReadOnly {
+		return http.StatusForbidden, "Zettel is stored in a read-only place"
 	}
 	if err == place.ErrStopped {
-		http.Error(w, "Zettelstore not operational", http.StatusInternalServerError)
-		return
+		return http.StatusInternalServerError, "Zettelstore not operational"
 	}
-	http.Error(w, "Unknown internal error", http.StatusInternalServerError)
 	log.Println(err)
+	return http.StatusInternalServerError, "Unknown internal error"
+}
+
+// writeJSONError writes an error message with the given status code as a JSON object.
+func writeJSONError(w http.ResponseWriter, code int, text string) {
+	w.Header().Set("Content-Type", format2ContentType("json"))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	buf := encoder.NewBufWriter(w)
+	buf.WriteStrings("{\"status\":", strconv.Itoa(code), ",\"error\":\"")
+	buf.Write(jsonenc.Escape(text))
+	buf.WriteString("\"}")
+	buf.Flush()
+}
+
+// writeSchemaErrorJSON writes a schema violation as a JSON object, which
+// lists all problems of the meta data.
+func writeSchemaErrorJSON(w http.ResponseWriter, err *usecase.ErrSchemaViolation) {
+	code := http.StatusUnprocessableEntity
+	w.Header().Set("Content-Type", format2ContentType("json"))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	buf := encoder.NewBufWriter(w)
+	buf.WriteStrings("{\"status\":", strconv.Itoa(code), ",\"error\":\"")
+	buf.Write(jsonenc.Escape(err.Error()))
+	buf.WriteString("\",\"problems\":[")
+	for i, p := range err.Problems {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString("{\"key\":\"")
+		buf.Write(jsonenc.Escape(p.Key))
+		buf.WriteString("\",\"message\":\"")
+		buf.Write(jsonenc.Escape(p.Message))
+		buf.WriteString("\"}")
+	}
+	buf.WriteString("]}")
+	buf.Flush()
+}
+
+// setETag exposes the version of a zettel as an entity tag.
+func setETag(w http.ResponseWriter, version string) {
+	w.Header().Set("ETag", "\""+version+"\"")
 }
@@ -21,17 +21,20 @@
 package adapter
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	"zettelstore.de/z/config"
 	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
 	"zettelstore.de/z/usecase"
 	"zettelstore.de/z/web/session"
 )
 
 // MakeEditGetZettelHandler creates a new HTTP handler to display the HTML edit view of a zettel.
-func MakeEditGetZettelHandler(te *TemplateEngine, getZettel usecase.GetZettel) http.HandlerFunc {
+func MakeEditGetZettelHandler(
+	te *TemplateEngine, getZettel usecase.GetZettel, getSchema usecase.GetSchema) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		zid, err := domain.ParseZettelID(r.URL.Path[1:])
 		if err != nil {
@@ -40,7 +43,7 @@ func MakeEditGetZettelHandler(te *TemplateEngine, getZettel usecase.GetZettel) h
 		}
 
 		ctx := r.Context()
-		zettel, err := getZettel.Run(ctx, zid)
+		zettel, version, err := getZettel.RunVersioned(ctx, zid)
 		if err != nil {
 			checkUsecaseError(w, err)
 			return
@@ -51,18 +54,28 @@ func MakeEditGetZettelHandler(te *TemplateEngine, getZettel usecase.GetZettel) h
 			return
 		}
 
+		_, suggestions := getSchemaData(ctx, getSchema, zettel.Meta, nil)
 		te.renderTemplate(ctx, w, domain.FormTemplateID, formZettelData{
-			Lang:    config.GetLang(zettel.Meta),
-			Title:   "Edit Zettel",
-			User:    wrapUser(session.GetUser(ctx)),
-			Meta:    wrapMeta(zettel.Meta),
-			Content: zettel.Content.AsString(),
+			Lang:        config.GetLang(zettel.Meta),
+			Title:       "Edit Zettel",
+			User:        wrapUser(session.GetUser(ctx)),
+			Meta:        wrapMeta(zettel.Meta),
+			Content:     zettel.Content.AsString(),
+			Version:     version,
+			Suggestions: suggestions,
 		})
 	}
 }
 
 // MakeEditSetZettelHandler creates a new HTTP handler to store content of an existing zettel.
-func MakeEditSetZettelHandler(updateZettel usecase.UpdateZettel) http.HandlerFunc {
+// If the zettel was changed in the meantime, a conflict view is shown instead.
+// If the zettel does not conform to the schema of its role, the form is shown
+// again, together with the problems found.
+func MakeEditSetZettelHandler(
+	te *TemplateEngine,
+	getZettel usecase.GetZettel,
+	getSchema usecase.GetSchema,
+	updateZettel usecase.UpdateZettel) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		zid, err := domain.ParseZettelID(r.URL.Path[1:])
 		if err != nil {
@@ -75,10 +88,55 @@ func MakeEditSetZettelHandler(updateZettel usecase.UpdateZettel) http.HandlerFun
 			return
 		}
 
-		if err := updateZettel.Run(r.Context(), zettel); err != nil {
+		ctx := r.Context()
+		version := r.PostFormValue("version")
+		if err := updateZettel.Run(ctx, zettel, version); err != nil {
+			if _, ok := err.(*place.ErrVersionConflict); ok {
+				renderConflictForm(ctx, w, te, getZettel, zettel)
+				return
+			}
+			if _, ok := err.(*usecase.ErrSchemaViolation); ok {
+				problems, suggestions := getSchemaData(ctx, getSchema, zettel.Meta, err)
+				te.renderTemplate(ctx, w, domain.FormTemplateID, formZettelData{
+					Lang:        config.GetLang(zettel.Meta),
+					Title:       "Edit Zettel",
+					User:        wrapUser(session.GetUser(ctx)),
+					Meta:        wrapMeta(zettel.Meta),
+					Content:     zettel.Content.AsString(),
+					Version:     version,
+					Problems:    problems,
+					Suggestions: suggestions,
+				})
+				return
+			}
 			checkUsecaseError(w, err)
 			return
 		}
 		http.Redirect(w, r, urlForZettel('h', zid), http.StatusFound)
 	}
 }
+
+func renderConflictForm(
+	ctx context.Context,
+	w http.ResponseWriter,
+	te *TemplateEngine,
+	getZettel usecase.GetZettel,
+	zettel domain.Zettel) {
+
+	curZettel, curVersion, err := getZettel.RunVersioned(ctx, zettel.Meta.Zid)
+	if err != nil {
+		checkUsecaseError(w, err)
+		return
+	}
+	te.renderTemplate(ctx, w, domain.FormTemplateID, formZettelData{
+		Lang:           config.GetLang(curZettel.Meta),
+		Title:          "Edit Zettel",
+		User:           wrapUser(session.GetUser(ctx)),
+		Meta:           wrapMeta(zettel.Meta),
+		Content:        zettel.Content.AsString(),
+		Version:        curVersion,
+		Conflict:       true,
+		CurrentMeta:    wrapMeta(curZettel.Meta),
+		CurrentContent: curZettel.Content.AsString(),
+	})
+}
@@ -21,11 +21,13 @@
 package adapter
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
 	"zettelstore.de/z/domain"
 	"zettelstore.de/z/input"
+	"zettelstore.de/z/usecase"
 )
 
 type formZettelData struct {
@@ -34,6 +36,63 @@ type formZettelData struct {
 	User    userWrapper
 	Meta    metaWrapper
 	Content string
+	Version string
+
+	// Problems of the meta data, according to the schema of its role, and
+	// keys of the schema that are not used yet.
+	Problems    []domain.SchemaProblem
+	Suggestions []schemaSuggestion
+
+	// Only used, if the zettel was changed in the meantime.
+	Conflict       bool
+	CurrentMeta    metaWrapper
+	CurrentContent string
+}
+
+// schemaSuggestion describes a key of a schema, that is not used by a zettel.
+type schemaSuggestion struct {
+	Key      string
+	Required bool
+	Type     string
+	Values   []string
+}
+
+// buildSuggestions returns all keys of the schema that have no value in the
+// given meta data.
+func buildSuggestions(schema *domain.Schema, meta *domain.Meta) []schemaSuggestion {
+	if schema == nil {
+		return nil
+	}
+	var result []schemaSuggestion
+	for _, key := range schema.Keys() {
+		if value, ok := meta.Get(key); ok && value != "" {
+			continue
+		}
+		sugg := schemaSuggestion{
+			Key:      key,
+			Required: schema.IsRequired(key),
+			Values:   schema.Values[key],
+		}
+		if keyType := schema.KeyType(key); keyType != domain.MetaTypeUnknown {
+			sugg.Type = domain.TypeName(keyType)
+		}
+		result = append(result, sugg)
+	}
+	return result
+}
+
+// getSchemaData returns the schema problems of the given error and the
+// suggested keys for the given meta data. Errors while retrieving the schema
+// are ignored, because the form is still usable without suggestions.
+func getSchemaData(
+	ctx context.Context, getSchema usecase.GetSchema, meta *domain.Meta, err error,
+) ([]domain.SchemaProblem, []schemaSuggestion) {
+	var problems []domain.SchemaProblem
+	if errSchema, ok := err.(*usecase.ErrSchemaViolation); ok {
+		problems = errSchema.Problems
+	}
+	schema, _ := getSchema.Run(ctx, meta.GetDefault(domain.MetaKeyRole, ""))
+	return problems, buildSuggestions(schema, meta)
 }
 
 func parseZettelForm(r *http.Request, zid domain.ZettelID) (domain.Zettel, error) {
@@ -84,6 +84,8 @@ func MakeGetInfoHandler(te *TemplateEngine, getZettel usecase.GetZettel, getMeta
 		}
 		links, images := collect.References(z)
 		intLinks, extLinks := splitIntExtLinks(getTitle, append(links, images...))
+		backLinks := getBackLinks(getTitle, zettel.Meta)
+		editor := getEditor(getTitle, zettel.Meta)
 
 		// Render as HTML
 		textTitle, err := formatInlines(z.Title, "text", nil, langOption)
@@ -98,8 +100,12 @@ func MakeGetInfoHandler(te *TemplateEngine, getZettel usecase.GetZettel, getMeta
 			Title     string
 			User      userWrapper
 			Meta      metaWrapper
+			Created   template.HTML
+			Modified  template.HTML
+			Editor    *internalReference
 			IntLinks  []internalReference
 			ExtLinks  []string
+			BackLinks []internalReference
 			Formats   []string
 			DefFormat string
 			Parts     []string
@@ -108,8 +114,12 @@ func MakeGetInfoHandler(te *TemplateEngine, getZettel usecase.GetZettel, getMeta
 			Title:     textTitle, // TODO: merge with site-title?
 			User:      wrapUser(session.GetUser(ctx)),
 			Meta:      wrapMeta(z.Meta),
+			Created:   htmlMetaValue(wrapMeta(zettel.Meta), domain.MetaKeyCreated),
+			Modified:  htmlMetaValue(wrapMeta(zettel.Meta), domain.MetaKeyModified),
+			Editor:    editor,
 			IntLinks:  intLinks,
 			ExtLinks:  extLinks,
+			BackLinks: backLinks,
 			Formats:   encoder.GetFormats(),
 			DefFormat: encoder.GetDefaultFormat(),
 			Parts:     []string{"zettel", "meta", "content"},
@@ -142,3 +152,41 @@ func splitIntExtLinks(getTitle func(domain.ZettelID) (string, int), links []*ast
 	}
 	return intLinks, extLinks
 }
+
+func getBackLinks(getTitle func(domain.ZettelID) (string, int), meta *domain.Meta) []internalReference {
+	values, ok := meta.GetList(domain.MetaKeyBackward)
+	if !ok || len(values) == 0 {
+		return nil
+	}
+	backLinks := make([]internalReference, 0, len(values))
+	for _, val := range values {
+		zid, err := domain.ParseZettelID(val)
+		if err != nil {
+			continue
+		}
+		title, found := getTitle(zid)
+		if found > 0 {
+			if len(title) == 0 {
+				title = val
+			}
+			backLinks = append(backLinks, internalReference{zid, true, template.HTML(title)})
+		}
+	}
+	return backLinks
+}
+
+func getEditor(getTitle func(domain.ZettelID) (string, int), meta *domain.Meta) *internalReference {
+	value, ok := meta.Get(domain.MetaKeyEditor)
+	if !ok {
+		return nil
+	}
+	zid, err := domain.ParseZettelID(value)
+	if err != nil {
+		return nil
+	}
+	title, found := getTitle(zid)
+	if len(title) == 0 {
+		title = value
+	}
+	return &internalReference{zid, found > 0, template.HTML(title)}
+}
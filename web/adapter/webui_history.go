@@ -0,0 +1,193 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package adapter provides handlers for web requests.
+package adapter
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"zettelstore.de/z/config"
+	"zettelstore.de/z/diff"
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/usecase"
+	"zettelstore.de/z/web/session"
+)
+
+type versionInfo struct {
+	Version string
+	Time    string
+	Title   string
+}
+
+type diffLine struct {
+	Kind   string
+	Prefix string
+	Text   string
+}
+
+// MakeGetHistoryHandler creates a new HTTP handler to list all prior versions
+// of a zettel. If two versions are given, their differences are shown too.
+func MakeGetHistoryHandler(
+	te *TemplateEngine,
+	getZettel usecase.GetZettel,
+	getHistory usecase.GetHistory,
+	getZettelVersion usecase.GetZettelVersion,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zid, err := domain.ParseZettelID(r.URL.Path[1:])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if format := getFormat(r, "html"); format != "html" {
+			http.Error(w, fmt.Sprintf("Zettel history not available in format %q", format), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		zettel, version, err := getZettel.RunVersioned(ctx, zid)
+		if err != nil {
+			checkUsecaseError(w, err)
+			return
+		}
+		history, err := getHistory.Run(ctx, zid)
+		if err != nil {
+			checkUsecaseError(w, err)
+			return
+		}
+		versions := make([]versionInfo, 0, len(history))
+		for _, zv := range history {
+			vi := versionInfo{
+				Version: zv.Version,
+				Time:    zv.Time.Local().Format("2006-01-02 15:04:05"),
+			}
+			if zv.Meta != nil {
+				vi.Title = zv.Meta.GetDefault(domain.MetaKeyTitle, "")
+			}
+			versions = append(versions, vi)
+		}
+
+		q := r.URL.Query()
+		from, to := q.Get("from"), q.Get("to")
+		var lines []diffLine
+		if from != "" || to != "" {
+			fromZettel, err := getVersionOrCurrent(r, getZettelVersion, zettel, from)
+			if err != nil {
+				checkUsecaseError(w, err)
+				return
+			}
+			toZettel, err := getVersionOrCurrent(r, getZettelVersion, zettel, to)
+			if err != nil {
+				checkUsecaseError(w, err)
+				return
+			}
+			lines = buildDiffLines(fromZettel, toZettel)
+		}
+
+		te.renderTemplate(ctx, w, domain.HistoryTemplateID, struct {
+			Lang     string
+			Title    string
+			User     userWrapper
+			Meta     metaWrapper
+			Token    string
+			Versions []versionInfo
+			From     string
+			To       string
+			Diff     []diffLine
+		}{
+			Lang:     config.GetLang(zettel.Meta),
+			Title:    "History of Zettel " + zid.Format(),
+			User:     wrapUser(session.GetUser(ctx)),
+			Meta:     wrapMeta(zettel.Meta),
+			Token:    version,
+			Versions: versions,
+			From:     from,
+			To:       to,
+			Diff:     lines,
+		})
+	}
+}
+
+// getVersionOrCurrent returns the zettel with the given version. If no
+// version is given, the current zettel is returned.
+func getVersionOrCurrent(
+	r *http.Request, getZettelVersion usecase.GetZettelVersion, current domain.Zettel, version string,
+) (domain.Zettel, error) {
+	if version == "" {
+		return current, nil
+	}
+	return getZettelVersion.Run(r.Context(), current.Meta.Zid, version)
+}
+
+func buildDiffLines(from, to domain.Zettel) []diffLine {
+	lines := diff.Lines(zettelText(from), zettelText(to))
+	result := make([]diffLine, 0, len(lines))
+	for _, l := range lines {
+		switch l.Op {
+		case diff.OpEqual:
+			result = append(result, diffLine{"equal", " ", l.Text})
+		case diff.OpInsert:
+			result = append(result, diffLine{"insert", "+", l.Text})
+		case diff.OpDelete:
+			result = append(result, diffLine{"delete", "-", l.Text})
+		}
+	}
+	return result
+}
+
+// zettelText returns the meta data and the content of a zettel as one text.
+func zettelText(zettel domain.Zettel) string {
+	var buf bytes.Buffer
+	zettel.Meta.Write(&buf)
+	buf.WriteByte('\n')
+	buf.WriteString(zettel.Content.AsString())
+	return buf.String()
+}
+
+// MakePostRestoreZettelHandler creates a new HTTP handler to restore a prior
+// version of a zettel.
+func MakePostRestoreZettelHandler(
+	getZettelVersion usecase.GetZettelVersion, updateZettel usecase.UpdateZettel,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zid, err := domain.ParseZettelID(r.URL.Path[1:])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Unable to read restore zettel form", http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		zettel, err := getZettelVersion.Run(ctx, zid, r.PostFormValue("restore"))
+		if err != nil {
+			checkUsecaseError(w, err)
+			return
+		}
+		if err := updateZettel.Run(ctx, zettel, r.PostFormValue("version")); err != nil {
+			checkUsecaseError(w, err)
+			return
+		}
+		http.Redirect(w, r, urlForZettel('h', zid), http.StatusFound)
+	}
+}
@@ -0,0 +1,124 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package adapter provides handlers for web requests.
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"zettelstore.de/z/config"
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
+	"zettelstore.de/z/usecase"
+	"zettelstore.de/z/web/session"
+)
+
+type journalInfo struct {
+	Time   string `json:"time"`
+	Op     string `json:"op"`
+	Zid    string `json:"id"`
+	NewZid string `json:"newid,omitempty"`
+	User   string `json:"user,omitempty"`
+	Hash   string `json:"hash,omitempty"`
+}
+
+// MakeGetJournalHandler creates a new HTTP handler to list the recorded
+// changes of all zettel, or of a specific zettel.
+func MakeGetJournalHandler(te *TemplateEngine, getJournal usecase.GetJournal) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zid := domain.InvalidZettelID
+		if sZid := r.URL.Path[1:]; sZid != "" {
+			var err error
+			if zid, err = domain.ParseZettelID(sZid); err != nil {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		format := getFormat(r, "html")
+		if format != "html" && format != "json" {
+			http.Error(w, fmt.Sprintf("Journal not available in format %q", format), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		entries, err := getJournal.Run(ctx, zid)
+		if err != nil {
+			if format == "json" {
+				checkUsecaseErrorJSON(w, err)
+			} else {
+				checkUsecaseError(w, err)
+			}
+			return
+		}
+		journal := make([]journalInfo, 0, len(entries))
+		for _, entry := range entries {
+			journal = append(journal, makeJournalInfo(entry, format))
+		}
+
+		if format == "json" {
+			w.Header().Set("Content-Type", format2ContentType(format))
+			json.NewEncoder(w).Encode(struct {
+				Journal []journalInfo `json:"journal"`
+			}{
+				Journal: journal,
+			})
+			return
+		}
+
+		title := "Journal"
+		if zid.IsValid() {
+			title = "Journal of Zettel " + zid.Format()
+		}
+		te.renderTemplate(ctx, w, domain.JournalTemplateID, struct {
+			Lang    string
+			Title   string
+			User    userWrapper
+			Journal []journalInfo
+		}{
+			Lang:    config.GetDefaultLang(),
+			Title:   title,
+			User:    wrapUser(session.GetUser(ctx)),
+			Journal: journal,
+		})
+	}
+}
+
+func makeJournalInfo(entry place.JournalEntry, format string) journalInfo {
+	ji := journalInfo{
+		Op:   entry.Op.String(),
+		Zid:  entry.Zid.Format(),
+		Hash: entry.Hash,
+	}
+	if format == "json" {
+		ji.Time = entry.Time.UTC().Format(time.RFC3339)
+	} else {
+		ji.Time = entry.Time.Local().Format("2006-01-02 15:04:05")
+	}
+	if entry.NewZid.IsValid() {
+		ji.NewZid = entry.NewZid.Format()
+	}
+	if entry.User.IsValid() {
+		ji.User = entry.User.Format()
+	}
+	return ji
+}
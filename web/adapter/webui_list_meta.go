@@ -21,8 +21,10 @@
 package adapter
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"net/url"
 
 	"zettelstore.de/z/config"
 	"zettelstore.de/z/domain"
@@ -34,31 +36,88 @@ import (
 func MakeListHTMLMetaHandler(te *TemplateEngine, listMeta usecase.ListMeta) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		filter, sorter := getFilterSorter(r)
+		filter, sorter, err := getFilterSorter(r)
+		sortLinks := buildSortLinks(urlForList('h'), r.URL.Query())
+		if err != nil {
+			renderListTemplate(ctx, w, te, sortLinks, nil, err)
+			return
+		}
 		metaList, err := listMeta.Run(ctx, filter, sorter)
 		if err != nil {
 			checkUsecaseError(w, err)
 			return
 		}
 
-		user := session.GetUser(ctx)
 		metas, err := buildHTMLMetaList(metaList)
 		if err != nil {
 			http.Error(w, "Internal error", http.StatusInternalServerError)
 			log.Println(err)
 			return
 		}
-		te.renderTemplate(r.Context(), w, domain.ListTemplateID, struct {
-			Lang  string
-			Title string
-			User  userWrapper
-			Metas []metaInfo
-		}{
-			Lang:  config.GetDefaultLang(),
-			Title: config.GetSiteName(),
-			User:  wrapUser(user),
-			Metas: metas,
-		})
+		renderListTemplate(ctx, w, te, sortLinks, metas, nil)
+	}
+}
+
+// sortLink is a link to the same list of zettel, but in another order.
+type sortLink struct {
+	Text   string
+	URL    string
+	Active bool
+}
+
+// listSortOrders are the orders a list of zettel can be sorted in the web
+// user interface. An empty order is the default order.
+var listSortOrders = []struct {
+	text  string
+	order string
+}{
+	{"Newest", ""},
+	{"Recently created", "-" + domain.MetaKeyCreated},
+	{"Recently modified", "-" + domain.MetaKeyModified},
+}
+
+// buildSortLinks returns links to the list at the given base URL, with the
+// given query values, for all sort orders.
+func buildSortLinks(base string, query url.Values) []sortLink {
+	current := query.Get("_sort")
+	links := make([]sortLink, 0, len(listSortOrders))
+	for _, so := range listSortOrders {
+		if so.order == "" {
+			query.Del("_sort")
+		} else {
+			query.Set("_sort", so.order)
+		}
+		link := base
+		if enc := query.Encode(); enc != "" {
+			link += "?" + enc
+		}
+		links = append(links, sortLink{Text: so.text, URL: link, Active: so.order == current})
+	}
+	return links
+}
 
+// renderListTemplate renders a list of zettel. If the list could not be
+// retrieved, because of an error in the request, the error is shown instead.
+func renderListTemplate(
+	ctx context.Context, w http.ResponseWriter, te *TemplateEngine,
+	sortLinks []sortLink, metas []metaInfo, listErr error) {
+	var errText string
+	if listErr != nil {
+		errText = listErr.Error()
 	}
+	te.renderTemplate(ctx, w, domain.ListTemplateID, struct {
+		Lang      string
+		Title     string
+		User      userWrapper
+		SortLinks []sortLink
+		Metas     []metaInfo
+		Error     string
+	}{
+		Lang:      config.GetDefaultLang(),
+		Title:     config.GetSiteName(),
+		User:      wrapUser(session.GetUser(ctx)),
+		SortLinks: sortLinks,
+		Metas:     metas,
+		Error:     errText,
+	})
 }
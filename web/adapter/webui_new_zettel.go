@@ -31,7 +31,8 @@ import (
 )
 
 // MakeGetNewZettelHandler creates a new HTTP handler to display the HTML edit view of a zettel.
-func MakeGetNewZettelHandler(te *TemplateEngine, getZettel usecase.GetZettel) http.HandlerFunc {
+func MakeGetNewZettelHandler(
+	te *TemplateEngine, getZettel usecase.GetZettel, getSchema usecase.GetSchema) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if format := getFormat(r, "html"); format != "html" {
 			http.Error(w, fmt.Sprintf("New zettel not possible in format %q", format), http.StatusBadRequest)
@@ -50,18 +51,23 @@ func MakeGetNewZettelHandler(te *TemplateEngine, getZettel usecase.GetZettel) ht
 		}
 		zettel := &domain.Zettel{Meta: oldZettel.Meta.Clone(), Content: oldZettel.Content}
 
+		_, suggestions := getSchemaData(ctx, getSchema, zettel.Meta, nil)
 		te.renderTemplate(r.Context(), w, domain.FormTemplateID, formZettelData{
-			Lang:    config.GetLang(zettel.Meta),
-			Title:   "New Zettel",
-			User:    wrapUser(session.GetUser(ctx)),
-			Meta:    wrapMeta(zettel.Meta),
-			Content: zettel.Content.AsString(),
+			Lang:        config.GetLang(zettel.Meta),
+			Title:       "New Zettel",
+			User:        wrapUser(session.GetUser(ctx)),
+			Meta:        wrapMeta(zettel.Meta),
+			Content:     zettel.Content.AsString(),
+			Suggestions: suggestions,
 		})
 	}
 }
 
 // MakePostNewZettelHandler creates a new HTTP handler to store content of an existing zettel.
-func MakePostNewZettelHandler(newZettel usecase.NewZettel) http.HandlerFunc {
+// If the zettel does not conform to the schema of its role, the form is shown
+// again, together with the problems found.
+func MakePostNewZettelHandler(
+	te *TemplateEngine, getSchema usecase.GetSchema, newZettel usecase.NewZettel) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		zettel, err := parseZettelForm(r, domain.InvalidZettelID)
 		if err != nil {
@@ -69,7 +75,21 @@ func MakePostNewZettelHandler(newZettel usecase.NewZettel) http.HandlerFunc {
 			return
 		}
 
-		if newZid, err := newZettel.Run(r.Context(), zettel); err != nil {
+		ctx := r.Context()
+		if newZid, err := newZettel.Run(ctx, zettel); err != nil {
+			if _, ok := err.(*usecase.ErrSchemaViolation); ok {
+				problems, suggestions := getSchemaData(ctx, getSchema, zettel.Meta, err)
+				te.renderTemplate(ctx, w, domain.FormTemplateID, formZettelData{
+					Lang:        config.GetLang(zettel.Meta),
+					Title:       "New Zettel",
+					User:        wrapUser(session.GetUser(ctx)),
+					Meta:        wrapMeta(zettel.Meta),
+					Content:     zettel.Content.AsString(),
+					Problems:    problems,
+					Suggestions: suggestions,
+				})
+				return
+			}
 			checkUsecaseError(w, err)
 		} else {
 			http.Redirect(w, r, urlForZettel('h', newZid), http.StatusFound)
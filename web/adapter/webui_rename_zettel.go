@@ -21,7 +21,9 @@
 package adapter
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 
@@ -32,7 +34,7 @@ import (
 )
 
 // MakeGetRenameZettelHandler creates a new HTTP handler to display the HTML rename view of a zettel.
-func MakeGetRenameZettelHandler(te *TemplateEngine, getMeta usecase.GetMeta) http.HandlerFunc {
+func MakeGetRenameZettelHandler(te *TemplateEngine, renameZettel usecase.RenameZettel) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		zid, err := domain.ParseZettelID(r.URL.Path[1:])
 		if err != nil {
@@ -40,34 +42,23 @@ func MakeGetRenameZettelHandler(te *TemplateEngine, getMeta usecase.GetMeta) htt
 			return
 		}
 
-		ctx := r.Context()
-		meta, err := getMeta.Run(ctx, zid)
-		if err != nil {
-			checkUsecaseError(w, err)
-			return
-		}
-
 		if format := getFormat(r, "html"); format != "html" {
 			http.Error(w, fmt.Sprintf("Rename zettel %q not possible in format %q", zid.Format(), format), http.StatusBadRequest)
 			return
 		}
 
-		te.renderTemplate(ctx, w, domain.RenameTemplateID, struct {
-			Title string
-			Lang  string
-			User  userWrapper
-			Meta  metaWrapper
-		}{
-			Title: "Rename Zettel " + zid.Format(),
-			Lang:  config.GetLang(meta),
-			User:  wrapUser(session.GetUser(ctx)),
-			Meta:  wrapMeta(meta),
-		})
+		ctx := r.Context()
+		result, err := renameZettel.Run(ctx, zid, zid, true)
+		if err != nil {
+			checkUsecaseError(w, err)
+			return
+		}
+		renderRenameForm(ctx, w, te, zid, result, false)
 	}
 }
 
 // MakePostRenameZettelHandler creates a new HTTP handler to rename an existing zettel.
-func MakePostRenameZettelHandler(renameZettel usecase.RenameZettel) http.HandlerFunc {
+func MakePostRenameZettelHandler(te *TemplateEngine, renameZettel usecase.RenameZettel) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		curZid, err := domain.ParseZettelID(r.URL.Path[1:])
 		if err != nil {
@@ -87,11 +78,64 @@ func MakePostRenameZettelHandler(renameZettel usecase.RenameZettel) http.Handler
 			http.Error(w, fmt.Sprintf("Invalid new zettel id %q", newZid.Format()), http.StatusBadRequest)
 			return
 		}
+		_, dryRun := r.PostForm["dryrun"]
 
-		if err := renameZettel.Run(r.Context(), curZid, newZid); err != nil {
+		ctx := r.Context()
+		result, err := renameZettel.Run(ctx, curZid, newZid, dryRun)
+		if err != nil {
 			checkUsecaseError(w, err)
 			return
 		}
+		if dryRun || len(result.Failed) > 0 || result.Skipped > 0 {
+			renderRenameForm(ctx, w, te, newZid, result, !dryRun)
+			return
+		}
 		http.Redirect(w, r, urlForZettel('h', newZid), http.StatusFound)
 	}
 }
+
+// renderRenameForm shows the rename form, together with a report of all
+// zettel that reference the zettel to be renamed.
+func renderRenameForm(
+	ctx context.Context,
+	w http.ResponseWriter,
+	te *TemplateEngine,
+	newZid domain.ZettelID,
+	result usecase.RenameResult,
+	renamed bool,
+) {
+	rewritten, err := buildHTMLMetaList(result.Rewritten)
+	if err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+	failed, err := buildHTMLMetaList(result.Failed)
+	if err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		log.Println(err)
+		return
+	}
+
+	te.renderTemplate(ctx, w, domain.RenameTemplateID, struct {
+		Title     string
+		Lang      string
+		User      userWrapper
+		Meta      metaWrapper
+		NewZid    domain.ZettelID
+		Renamed   bool
+		Rewritten []metaInfo
+		Failed    []metaInfo
+		Skipped   int
+	}{
+		Title:     "Rename Zettel " + result.Meta.Zid.Format(),
+		Lang:      config.GetLang(result.Meta),
+		User:      wrapUser(session.GetUser(ctx)),
+		Meta:      wrapMeta(result.Meta),
+		NewZid:    newZid,
+		Renamed:   renamed,
+		Rewritten: rewritten,
+		Failed:    failed,
+		Skipped:   result.Skipped,
+	})
+}
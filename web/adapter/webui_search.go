@@ -25,12 +25,9 @@ import (
 	"net/http"
 	"strconv"
 
-	"zettelstore.de/z/config"
-	"zettelstore.de/z/domain"
 	"zettelstore.de/z/encoder"
 	"zettelstore.de/z/place"
 	"zettelstore.de/z/usecase"
-	"zettelstore.de/z/web/session"
 )
 
 // MakeSearchHandler creates a new HTTP handler for the use case "search".
@@ -39,6 +36,7 @@ func MakeSearchHandler(te *TemplateEngine, search usecase.Search) http.HandlerFu
 		query := r.URL.Query()
 		var filter *place.Filter
 		var sorter *place.Sorter
+		var queryErr error
 		for key, values := range query {
 			switch key {
 			case "offset":
@@ -59,32 +57,43 @@ func MakeSearchHandler(te *TemplateEngine, search usecase.Search) http.HandlerFu
 				filter = ensureFilter(filter)
 				filter.Negate = true
 			case "s":
-				cleanedValues := make([]string, 0, len(values))
-				for _, val := range values {
-					if len(val) > 0 {
-						cleanedValues = append(cleanedValues, val)
-					}
+				query, err := getQuery(values)
+				if err != nil {
+					queryErr = err
+					break
 				}
-				if len(cleanedValues) > 0 {
+				if !query.IsEmpty() {
 					filter = ensureFilter(filter)
-					filter.Expr[""] = cleanedValues
+					filter.Query = query
 				}
 			}
 		}
-		if filter == nil || len(filter.Expr) == 0 {
+		ctx := r.Context()
+		format := getFormat(r, "html")
+		sortLinks := buildSortLinks(urlForList('s'), r.URL.Query())
+		if queryErr != nil {
+			switch format {
+			case "html":
+				renderListTemplate(ctx, w, te, sortLinks, nil, queryErr)
+			case "json", "djson":
+				writeJSONError(w, http.StatusBadRequest, queryErr.Error())
+			default:
+				http.Error(w, queryErr.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+		if filter == nil || filter.Query.IsEmpty() {
 			http.Redirect(w, r, urlForList('h'), http.StatusFound)
 			return
 		}
 
-		metaList, err := search.Run(r.Context(), filter, sorter)
+		metaList, err := search.Run(ctx, filter, sorter)
 		if err != nil {
 			checkUsecaseError(w, err)
 			return
 		}
 
-		ctx := r.Context()
-		user := session.GetUser(ctx)
-		if format := getFormat(r, "html"); format != "html" {
+		if format != "html" {
 			w.Header().Set("Content-Type", format2ContentType(format))
 			switch format {
 			case "json", "djson":
@@ -100,18 +109,6 @@ func MakeSearchHandler(te *TemplateEngine, search usecase.Search) http.HandlerFu
 			log.Println(err)
 			return
 		}
-		te.renderTemplate(ctx, w, domain.ListTemplateID, struct {
-			Lang  string
-			Title string
-			User  userWrapper
-			Metas []metaInfo
-			Key   byte
-		}{
-			Lang:  config.GetDefaultLang(),
-			Title: config.GetSiteName(),
-			User:  wrapUser(user),
-			Metas: metas,
-			Key:   'h',
-		})
+		renderListTemplate(ctx, w, te, sortLinks, metas, nil)
 	}
 }
@@ -61,17 +61,17 @@ func NewTemplateEngine(p place.Place, pol policy.Policy) *TemplateEngine {
 		place:  p,
 		policy: pol,
 	}
-	te.observe(true, domain.InvalidZettelID)
+	te.observe(place.ChangeInfo{Reason: place.OnReload, Zid: domain.InvalidZettelID})
 	p.RegisterChangeObserver(te.observe)
 	return te
 }
 
-func (te *TemplateEngine) observe(all bool, zid domain.ZettelID) {
+func (te *TemplateEngine) observe(ci place.ChangeInfo) {
 	te.mxCache.Lock()
-	if all || zid == domain.BaseTemplateID {
+	if ci.Reason == place.OnReload || ci.Zid == domain.BaseTemplateID {
 		te.templateCache = make(map[domain.ZettelID]*template.Template, len(te.templateCache))
 	} else {
-		delete(te.templateCache, zid)
+		delete(te.templateCache, ci.Zid)
 	}
 	te.mxCache.Unlock()
 }
@@ -127,6 +127,36 @@ func htmlMetaValue(metaW metaWrapper, key string) template.HTML {
 		}
 		return template.HTML("<a href=\"" + urlForZettel('h', zid) + "\">" + value + "</a>")
 
+	case domain.MetaTypeZettelIDSet:
+		values, _ := meta.GetList(key)
+		var b strings.Builder
+		for i, value := range values {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			zid, err := domain.ParseZettelID(value)
+			if err != nil {
+				b.WriteString(html.EscapeString(value))
+				continue
+			}
+			b.WriteString("<a href=\"" + urlForZettel('h', zid) + "\">" + value + "</a>")
+		}
+		return template.HTML(b.String())
+
+	case domain.MetaTypeNumber:
+		value, _ := meta.Get(key)
+		var b strings.Builder
+		writeLink(&b, key, value)
+		return template.HTML(b.String())
+
+	case domain.MetaTypeTimestamp:
+		value, _ := meta.Get(key)
+		if _, ok := domain.TimeValue(value); !ok {
+			return template.HTML(html.EscapeString(value))
+		}
+		iso := domain.FormatTimestamp(value)
+		return template.HTML("<time datetime=\"" + iso + "\">" + strings.Replace(iso, "T", " ", 1) + "</time>")
+
 	case domain.MetaTypeTagSet, domain.MetaTypeWordSet:
 		values, _ := meta.GetList(key)
 		var b strings.Builder
@@ -205,7 +235,7 @@ var funcMap = template.FuncMap{
 	"join":          join,
 }
 
-func createPolicyFuncs(p policy.Policy) template.FuncMap {
+func createPolicyFuncs(p policy.Policy, tp templatePlace) template.FuncMap {
 	result := template.FuncMap{
 		"CanReload": func(user userWrapper) bool {
 			return p.CanReload(user.original)
@@ -224,7 +254,12 @@ func createPolicyFuncs(p policy.Policy) template.FuncMap {
 			return p.CanRename(user.original, meta.original)
 		},
 		"CanDelete": func(user userWrapper, meta metaWrapper) bool {
-			return p.CanDelete(user.original, meta.original)
+			if p.CanDelete(user.original, meta.original) {
+				return true
+			}
+			t, ok := tp.(place.Trash)
+			return ok && p.CanTrash(user.original, meta.original) &&
+				t.CanTrashZettel(context.Background(), meta.original.Zid)
 		},
 	}
 
@@ -234,6 +269,25 @@ func createPolicyFuncs(p policy.Policy) template.FuncMap {
 	return result
 }
 
+// createPlaceFuncs returns functions to check, whether the place provides
+// some optional services.
+func createPlaceFuncs(p templatePlace) template.FuncMap {
+	return template.FuncMap{
+		"HasHistory": func() bool {
+			h, ok := p.(place.Historian)
+			return ok && h.HasHistory()
+		},
+		"HasTrash": func() bool {
+			t, ok := p.(place.Trash)
+			return ok && t.HasTrash()
+		},
+		"HasJournal": func() bool {
+			j, ok := p.(place.Journal)
+			return ok && j.HasJournal()
+		},
+	}
+}
+
 func (te *TemplateEngine) getTemplate(ctx context.Context, templateID domain.ZettelID) (*template.Template, error) {
 	if t, ok := te.cacheGetTemplate(templateID); ok {
 		return t, nil
@@ -244,7 +298,10 @@ func (te *TemplateEngine) getTemplate(ctx context.Context, templateID domain.Zet
 		if err != nil {
 			return nil, err
 		}
-		baseTemplate, err = template.New("base").Funcs(createPolicyFuncs(te.policy)).Parse(baseTemplateZettel.Content.AsString())
+		baseTemplate, err = template.New("base").
+			Funcs(createPolicyFuncs(te.policy, te.place)).
+			Funcs(createPlaceFuncs(te.place)).
+			Parse(baseTemplateZettel.Content.AsString())
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,105 @@
+//-----------------------------------------------------------------------------
+// Copyright (c) 2020 Detlef Stern
+//
+// This file is part of zettelstore.
+//
+// Zettelstore is free software: you can redistribute it and/or modify it under
+// the terms of the GNU Affero General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// Zettelstore is distributed in the hope that it will be useful, but WITHOUT
+// ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or
+// FITNESS FOR A PARTICULAR PURPOSE. See the GNU Affero General Public License
+// for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with Zettelstore. If not, see <http://www.gnu.org/licenses/>.
+//-----------------------------------------------------------------------------
+
+// Package adapter provides handlers for web requests.
+package adapter
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"zettelstore.de/z/config"
+	"zettelstore.de/z/domain"
+	"zettelstore.de/z/usecase"
+	"zettelstore.de/z/web/session"
+)
+
+type trashInfo struct {
+	metaInfo
+	Deleted   string
+	DeletedBy string
+}
+
+// MakeGetTrashHandler creates a new HTTP handler to list all deleted zettel.
+func MakeGetTrashHandler(te *TemplateEngine, getTrash usecase.GetTrash) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if format := getFormat(r, "html"); format != "html" {
+			http.Error(w, fmt.Sprintf("Trash not available in format %q", format), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		entries, err := getTrash.Run(ctx)
+		if err != nil {
+			checkUsecaseError(w, err)
+			return
+		}
+		metaList := make([]*domain.Meta, 0, len(entries))
+		for _, entry := range entries {
+			metaList = append(metaList, entry.Meta)
+		}
+		metas, err := buildHTMLMetaList(metaList)
+		if err != nil {
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			log.Println(err)
+			return
+		}
+		trash := make([]trashInfo, 0, len(entries))
+		for i, entry := range entries {
+			trash = append(trash, trashInfo{
+				metaInfo:  metas[i],
+				Deleted:   entry.Deleted.Local().Format("2006-01-02 15:04:05"),
+				DeletedBy: entry.DeletedBy,
+			})
+		}
+
+		te.renderTemplate(ctx, w, domain.TrashTemplateID, struct {
+			Lang  string
+			Title string
+			User  userWrapper
+			Trash []trashInfo
+		}{
+			Lang:  config.GetDefaultLang(),
+			Title: config.GetSiteName(),
+			User:  wrapUser(session.GetUser(ctx)),
+			Trash: trash,
+		})
+	}
+}
+
+// MakePostRestoreHandler creates a new HTTP handler to restore a deleted zettel.
+func MakePostRestoreHandler(restoreZettel usecase.RestoreZettel) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Unable to read restore form", http.StatusBadRequest)
+			return
+		}
+		zid, err := domain.ParseZettelID(r.PostFormValue("zid"))
+		if err != nil {
+			http.Error(w, "Invalid zettel id in form", http.StatusBadRequest)
+			return
+		}
+		if err := restoreZettel.Run(r.Context(), zid); err != nil {
+			checkUsecaseError(w, err)
+			return
+		}
+		http.Redirect(w, r, urlForZettel('h', zid), http.StatusFound)
+	}
+}
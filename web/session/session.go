@@ -29,6 +29,7 @@ import (
 	"zettelstore.de/z/auth/token"
 	"zettelstore.de/z/config"
 	"zettelstore.de/z/domain"
+	"zettelstore.de/z/place"
 	"zettelstore.de/z/usecase"
 )
 
@@ -104,6 +105,7 @@ func GetUser(ctx context.Context) *domain.Meta {
 }
 
 func updateContext(ctx context.Context, user *domain.Meta, data *token.Data) context.Context {
+	ctx = place.WithUser(ctx, user)
 	if data == nil {
 		return context.WithValue(ctx, ctxKey, &AuthData{User: user})
 	}